@@ -0,0 +1,220 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// Detectores aceitos em transformer.detector.
+const (
+	DetectorThreshold = "threshold" // Value * anomaly_score_multiplier comparado a anomaly_threshold
+	DetectorZScore    = "zscore"    // Desvio em relação à média da janela, em desvios padrão
+	DetectorEWMA      = "ewma"      // Desvio em relação à média móvel exponencial
+	DetectorMAD       = "mad"       // Z-score robusto baseado na mediana e no desvio absoluto mediano
+)
+
+// minSpread evita divisão por zero quando a dispersão do sensor é nula.
+const minSpread = 1e-9
+
+// madScale torna o desvio absoluto mediano comparável ao desvio padrão de uma normal.
+const madScale = 0.6745
+
+// AnomalyDetector calcula o score de anomalia de um valor de um sensor.
+// Implementações devem ser seguras para uso concorrente pelos workers do Transformer.
+type AnomalyDetector interface {
+	// Name identifica o detector em ProcessedRecord.AnomalyDetector.
+	Name() string
+	// Observe calcula o score de value em relação ao histórico de sensorID e o incorpora ao histórico.
+	Observe(sensorID string, value float64) (score float64, isAnomaly bool)
+}
+
+// NewAnomalyDetector cria o detector selecionado em cfg.Detector.
+func NewAnomalyDetector(cfg TransformerConfig) (AnomalyDetector, error) {
+	switch cfg.Detector {
+	case "", DetectorThreshold:
+		return thresholdDetector{multiplier: cfg.AnomalyScoreMultiplier, threshold: cfg.AnomalyThreshold}, nil
+	case DetectorZScore:
+		return &windowDetector{name: DetectorZScore, cfg: cfg, states: newSensorStates[windowState](), score: zScore}, nil
+	case DetectorMAD:
+		return &windowDetector{name: DetectorMAD, cfg: cfg, states: newSensorStates[windowState](), score: madScore}, nil
+	case DetectorEWMA:
+		return &ewmaDetector{cfg: cfg, states: newSensorStates[ewmaState]()}, nil
+	default:
+		return nil, fmt.Errorf("transformer.detector deve ser um de %s, %s, %s ou %s (atual: %q)",
+			DetectorThreshold, DetectorZScore, DetectorEWMA, DetectorMAD, cfg.Detector)
+	}
+}
+
+// thresholdDetector reproduz a regra original: score proporcional ao valor, sem histórico.
+type thresholdDetector struct {
+	multiplier float64
+	threshold  float64
+}
+
+func (d thresholdDetector) Name() string { return DetectorThreshold }
+
+func (d thresholdDetector) Observe(_ string, value float64) (float64, bool) {
+	score := value * d.multiplier
+	return score, score > d.threshold
+}
+
+// sensorStates guarda o estado de cada sensor, com um lock próprio por sensor
+// para que workers processando sensores diferentes não disputem o mesmo lock.
+type sensorStates[T any] struct {
+	mu     sync.Mutex
+	states map[string]*lockedState[T]
+}
+
+type lockedState[T any] struct {
+	sync.Mutex
+	state T
+}
+
+func newSensorStates[T any]() *sensorStates[T] {
+	return &sensorStates[T]{states: make(map[string]*lockedState[T])}
+}
+
+// get retorna o estado de sensorID, criando-o se necessário. O chamador deve travá-lo.
+func (s *sensorStates[T]) get(sensorID string) *lockedState[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.states[sensorID]
+	if !ok {
+		st = &lockedState[T]{}
+		s.states[sensorID] = st
+	}
+	return st
+}
+
+// windowState mantém os últimos valores de um sensor em um buffer circular.
+type windowState struct {
+	values []float64
+	next   int
+	full   bool
+}
+
+func (w *windowState) add(value float64, size int) {
+	if w.values == nil {
+		w.values = make([]float64, size)
+	}
+	w.values[w.next] = value
+	w.next = (w.next + 1) % size
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+func (w *windowState) snapshot() []float64 {
+	if w.full {
+		return w.values
+	}
+	return w.values[:w.next]
+}
+
+// windowDetector aplica score a uma janela deslizante de transformer.window_size valores por sensor.
+type windowDetector struct {
+	name   string
+	cfg    TransformerConfig
+	states *sensorStates[windowState]
+	score  func(window []float64, value float64) float64
+}
+
+func (d *windowDetector) Name() string { return d.name }
+
+func (d *windowDetector) Observe(sensorID string, value float64) (float64, bool) {
+	st := d.states.get(sensorID)
+	st.Lock()
+	defer st.Unlock()
+
+	window := st.state.snapshot()
+	var score float64
+	if len(window) >= d.cfg.MinSamples {
+		score = d.score(window, value)
+	}
+	st.state.add(value, d.cfg.WindowSize)
+	return score, len(window) >= d.cfg.MinSamples && score > d.cfg.ZThreshold
+}
+
+// zScore mede quantos desvios padrão value está da média da janela.
+func zScore(window []float64, value float64) float64 {
+	var sum float64
+	for _, v := range window {
+		sum += v
+	}
+	mean := sum / float64(len(window))
+	var sq float64
+	for _, v := range window {
+		sq += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(sq / float64(len(window)))
+	return math.Abs(value-mean) / math.Max(std, minSpread)
+}
+
+// madScore é o z-score robusto: madScale * |value - mediana| / MAD.
+func madScore(window []float64, value float64) float64 {
+	sorted := append([]float64(nil), window...)
+	sort.Float64s(sorted)
+	med := median(sorted)
+	deviations := make([]float64, len(sorted))
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - med)
+	}
+	sort.Float64s(deviations)
+	mad := median(deviations)
+	return madScale * math.Abs(value-med) / math.Max(mad, minSpread)
+}
+
+// median retorna a mediana de valores já ordenados.
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// ewmaState mantém a média e a variância exponencialmente ponderadas de um sensor.
+type ewmaState struct {
+	count    int
+	mean     float64
+	variance float64
+}
+
+// ewmaDetector compara cada valor à média móvel exponencial do sensor,
+// com peso transformer.ewma_alpha para a observação mais recente.
+type ewmaDetector struct {
+	cfg    TransformerConfig
+	states *sensorStates[ewmaState]
+}
+
+func (d *ewmaDetector) Name() string { return DetectorEWMA }
+
+func (d *ewmaDetector) Observe(sensorID string, value float64) (float64, bool) {
+	st := d.states.get(sensorID)
+	st.Lock()
+	defer st.Unlock()
+
+	s := &st.state
+	var score float64
+	warm := s.count >= d.cfg.MinSamples && s.count > 0
+	if warm {
+		score = math.Abs(value-s.mean) / math.Max(math.Sqrt(s.variance), minSpread)
+	}
+
+	if s.count == 0 {
+		s.mean = value
+	} else {
+		alpha := d.cfg.EWMAAlpha
+		diff := value - s.mean
+		s.mean += alpha * diff
+		s.variance = (1 - alpha) * (s.variance + alpha*diff*diff)
+	}
+	s.count++
+	return score, warm && score > d.cfg.ZThreshold
+}
@@ -0,0 +1,119 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func detectorConfig(detector string) TransformerConfig {
+	cfg := DefaultConfig().Transformer
+	cfg.Detector = detector
+	cfg.WindowSize = 20
+	cfg.MinSamples = 5
+	cfg.EWMAAlpha = 0.2
+	cfg.ZThreshold = 3.0
+	return cfg
+}
+
+func TestStatisticalDetectorsFlagOutliers(t *testing.T) {
+	for _, name := range []string{DetectorZScore, DetectorEWMA, DetectorMAD} {
+		t.Run(name, func(t *testing.T) {
+			detector, err := NewAnomalyDetector(detectorConfig(name))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// Warm-up: nenhum sinal antes de min_samples observações
+			baseline := []float64{10, 11, 9, 10, 12, 10, 11, 9, 10, 11}
+			for i, v := range baseline {
+				score, isAnomaly := detector.Observe("sensor-1", v)
+				if i < 5 && (score != 0 || isAnomaly) {
+					t.Errorf("Expected no score during warm-up, got %f at sample %d", score, i)
+				}
+				if isAnomaly {
+					t.Errorf("Baseline value %f should not be an anomaly", v)
+				}
+			}
+
+			if _, isAnomaly := detector.Observe("sensor-1", 10.5); isAnomaly {
+				t.Error("Value close to the baseline should not be an anomaly")
+			}
+			score, isAnomaly := detector.Observe("sensor-1", 60)
+			if !isAnomaly {
+				t.Errorf("Expected outlier to be flagged, got score %f", score)
+			}
+
+			// Outro sensor tem seu próprio histórico e ainda está em warm-up
+			if score, _ := detector.Observe("sensor-2", 60); score != 0 {
+				t.Errorf("Expected independent state per sensor, got score %f", score)
+			}
+		})
+	}
+}
+
+func TestThresholdDetectorKeepsLegacyScore(t *testing.T) {
+	detector, err := NewAnomalyDetector(DefaultConfig().Transformer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	score, isAnomaly := detector.Observe("sensor-1", 90)
+	if score != 9.0 || !isAnomaly {
+		t.Errorf("Expected score 9.0 flagged as anomaly, got %f (%t)", score, isAnomaly)
+	}
+}
+
+func TestDetectorConcurrentWorkers(t *testing.T) {
+	transformer, err := NewTransformerProcessor(detectorConfig(DetectorMAD))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				record := ProcessedRecord{DataRecord: DataRecord{
+					ID:       fmt.Sprintf("w%d-%d", w, i),
+					SensorID: fmt.Sprintf("sensor-%d", i%3),
+					Value:    float64(10 + i%5),
+					Unit:     "unit_A",
+				}}
+				result, err := transformer.Process(context.Background(), record)
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+					return
+				}
+				if result.AnomalyDetector != DetectorMAD {
+					t.Errorf("Expected detector %s, got %s", DetectorMAD, result.AnomalyDetector)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestDetectorConfigValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Transformer.Detector = "isolation_forest"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected unknown detector to be rejected")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Transformer.Detector = DetectorZScore
+	cfg.Transformer.MinSamples = 500
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected min_samples larger than window_size to be rejected")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Transformer.Detector = DetectorEWMA
+	cfg.Transformer.EWMAAlpha = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected ewma_alpha of 0 to be rejected")
+	}
+}
@@ -0,0 +1,316 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// edgeSet cria os canais entre etapas de uma execução, aplicando a cada um a política de
+// backpressure configurada (ver BackpressureConfig).
+type edgeSet struct {
+	ctx        context.Context
+	wg         *sync.WaitGroup
+	cfg        AdvancedConfig
+	bufferSize int
+	telemetry  *Telemetry
+	memory     *memoryBudget   // Compartilhado pelos canais com a política spill
+	spillRoot  string          // Diretório das filas em disco, uma por canal
+	names      map[string]bool // Canais criados, sem o sufixo de faixa
+}
+
+func newEdgeSet(ctx context.Context, wg *sync.WaitGroup, cfg Config, telemetry *Telemetry) *edgeSet {
+	// Sem spill_dir, as filas ficam ao lado da saída, para que pipelines com saídas
+	// diferentes não retomem os registros umas das outras
+	spillRoot := cfg.Advanced.Backpressure.SpillDir
+	if spillRoot == "" {
+		spillRoot = cfg.Output.ProcessedFile + ".spill"
+	}
+	return &edgeSet{
+		ctx:        ctx,
+		wg:         wg,
+		cfg:        cfg.Advanced,
+		bufferSize: cfg.Pipeline.ChannelBufferSize,
+		telemetry:  telemetry,
+		memory:     &memoryBudget{limit: int64(cfg.Advanced.MaxMemoryMB) << 20},
+		spillRoot:  spillRoot,
+		names:      make(map[string]bool),
+	}
+}
+
+// spillDir retorna o diretório da fila em disco do canal name.
+func (s *edgeSet) spillDir(name string) string {
+	return filepath.Join(s.spillRoot, name)
+}
+
+// warnUnknown registra as entradas de advanced.backpressure.edges que não correspondem a
+// nenhum canal criado, provavelmente por erro de digitação.
+func (s *edgeSet) warnUnknown() {
+	if !s.cfg.BackpressureEnabled {
+		return
+	}
+	for _, name := range sortedKeys(s.cfg.Backpressure.Edges) {
+		if !s.names[name] {
+			warnf("Pipeline: advanced.backpressure.edges.%s não corresponde a nenhum canal (canais: %v)", name, sortedKeys(s.names))
+		}
+	}
+}
+
+// edge liga os produtores de um canal entre etapas ao seu consumidor. Com a política block,
+// in e out são o mesmo canal com buffer. Com as demais, uma goroutine (run) transfere os
+// registros de in para out por uma fila de até capacity registros e aplica a política quando
+// ela está cheia; out não tem buffer, para que a fila seja o único buffer do canal.
+//
+// Com a política spill, os registros que não cabem na fila, ou que excederiam
+// advanced.max_memory_mb, vão para uma spillQueue e voltam à fila em ordem. Um registro vindo
+// do disco só é confirmado na spillQueue quando é entregue em out, de modo que os que ainda
+// estavam na fila quando a execução foi abortada permanecem em disco. Registros deixados em
+// disco por uma execução anterior são entregues antes dos novos.
+type edge[T any] struct {
+	name        string
+	policy      string
+	in          chan T // Escrito pelos produtores, que o fecham ao terminar
+	out         chan T // Lido pelo consumidor
+	capacity    int
+	sampleEvery int
+	spillDir    string
+	segmentSize int64
+	telemetry   *Telemetry
+	memory      *memoryBudget // Apenas com a política spill
+	onDrop      func(T)       // Chamado para cada registro descartado; pode ser nil
+
+	queue   []T
+	spilled []bool // Paralelo a queue: o registro veio do disco e aguarda confirmação
+	spill   *spillQueue[T]
+	queued  atomic.Int64 // len(queue) mais os registros em disco, lido pela telemetria
+	sampled int
+	dropped int
+}
+
+// newEdge cria o canal name, registra-o na telemetria e, se sua política não for block,
+// inicia a goroutine que a aplica. onDrop, se não for nil, é chamado para cada registro
+// descartado.
+func newEdge[T any](s *edgeSet, name string, onDrop func(T)) *edge[T] {
+	base := name
+	for i := range name {
+		if name[i] == '[' {
+			base = name[:i]
+			break
+		}
+	}
+	s.names[base] = true
+	e := &edge[T]{
+		name:        name,
+		policy:      s.cfg.edgePolicy(name),
+		capacity:    s.bufferSize,
+		sampleEvery: s.cfg.Backpressure.SampleEvery,
+		spillDir:    s.spillDir(name),
+		segmentSize: s.cfg.Backpressure.SegmentBytes,
+		telemetry:   s.telemetry,
+		onDrop:      onDrop,
+	}
+	if e.policy == BackpressureBlock {
+		e.in = make(chan T, s.bufferSize)
+		e.out = e.in
+		registerChannel(s.telemetry, name, e.in)
+		return e
+	}
+	if e.policy == BackpressureSpill {
+		e.memory = s.memory
+		// Abre a fila já na criação para retomar os registros de uma execução anterior
+		if err := e.openSpill(); err != nil {
+			errorf("Pipeline: %s: fila em disco indisponível: %v", name, err)
+		} else if n := e.spill.len(); n > 0 {
+			infof("Pipeline: %s: retomando %d registros gravados em disco por uma execução anterior", name, n)
+		}
+	}
+	e.in = make(chan T)
+	e.out = make(chan T)
+	s.telemetry.registerQueue(name, func() (int, int) { return e.depth(), e.capacity })
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		e.run(s.ctx)
+	}()
+	return e
+}
+
+// depth informa quantos registros aguardam no canal, incluindo os que estão em disco.
+func (e *edge[T]) depth() int {
+	if e.in == e.out {
+		return len(e.in)
+	}
+	return int(e.queued.Load())
+}
+
+// run transfere os registros de in para out até que in seja fechado e a fila esvazie, ou até
+// que ctx seja cancelado, fechando out ao terminar.
+func (e *edge[T]) run(ctx context.Context) {
+	defer close(e.out)
+	defer func() {
+		if e.spill != nil {
+			if n := e.spill.retained(); n > 0 {
+				infof("Pipeline: %s: %d registros mantidos em disco para a próxima execução", e.name, n)
+			}
+			e.spill.close()
+		}
+		if e.dropped > 0 {
+			warnf("Pipeline: %s descartou %d registros (política %s)", e.name, e.dropped, e.policy)
+		}
+	}()
+	in := e.in
+	var held *T // Registro mantido pela política sample à espera de espaço
+	for {
+		e.refill()
+		if held != nil && len(e.queue) < e.capacity {
+			e.push(*held)
+			held = nil
+		}
+		if in == nil && held == nil && len(e.queue) == 0 {
+			return
+		}
+
+		recv := in
+		if held != nil {
+			recv = nil
+		}
+		var out chan T
+		var head T
+		if len(e.queue) > 0 {
+			out, head = e.out, e.queue[0]
+		}
+		select {
+		case v, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			held = e.admit(v)
+		case out <- head:
+			e.pop()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// admit coloca v na fila ou, se ela estiver cheia, aplica a política do canal. Retorna o
+// registro que deve esperar por espaço, com a política sample.
+func (e *edge[T]) admit(v T) *T {
+	if e.policy == BackpressureSpill {
+		// Enquanto houver registros em disco, os novos vão para o fim da fila em disco
+		if len(e.queue) < e.capacity && e.spillLen() == 0 && !e.memory.full() {
+			e.push(v)
+		} else if err := e.spillRecord(v); err != nil {
+			errorf("Pipeline: %s: falha ao gravar registro em disco, descartando: %v", e.name, err)
+			e.drop(v)
+		}
+		return nil
+	}
+	if len(e.queue) < e.capacity {
+		e.push(v)
+		return nil
+	}
+	switch e.policy {
+	case BackpressureDropOldest:
+		e.drop(e.pop())
+		e.push(v)
+	case BackpressureSample:
+		e.sampled++
+		if e.sampled%e.sampleEvery == 0 {
+			return &v
+		}
+		e.drop(v)
+	default:
+		e.drop(v)
+	}
+	return nil
+}
+
+// refill move registros do disco para a fila enquanto houver espaço. Acima de
+// advanced.max_memory_mb, move apenas o próximo a entregar.
+func (e *edge[T]) refill() {
+	for e.spillLen() > 0 && len(e.queue) < e.capacity && (len(e.queue) == 0 || !e.memory.full()) {
+		v, err := e.spill.pop()
+		if err != nil {
+			n := e.spill.len()
+			errorf("Pipeline: %s: falha ao ler registros do disco, descartando %d: %v", e.name, n, err)
+			for i := 0; i < n; i++ {
+				e.telemetry.recordDrop(e.name, e.policy)
+			}
+			e.dropped += n
+			e.queued.Add(-int64(n))
+			e.spill.discard()
+			e.spill = nil
+			return
+		}
+		e.queue = append(e.queue, v)
+		e.spilled = append(e.spilled, true)
+		e.memory.add(recordBytes(v))
+	}
+}
+
+func (e *edge[T]) openSpill() error {
+	spill, err := openSpillQueue[T](e.spillDir, e.segmentSize)
+	if err != nil {
+		return err
+	}
+	e.spill = spill
+	e.queued.Add(int64(spill.len()))
+	return nil
+}
+
+func (e *edge[T]) spillLen() int {
+	if e.spill == nil {
+		return 0
+	}
+	return e.spill.len()
+}
+
+func (e *edge[T]) spillRecord(v T) error {
+	if e.spill == nil {
+		if err := e.openSpill(); err != nil {
+			return err
+		}
+	}
+	if err := e.spill.push(v); err != nil {
+		return err
+	}
+	e.queued.Add(1)
+	e.telemetry.recordSpill(e.name)
+	return nil
+}
+
+func (e *edge[T]) push(v T) {
+	e.queue = append(e.queue, v)
+	e.spilled = append(e.spilled, false)
+	e.queued.Add(1)
+	e.memory.add(recordBytes(v))
+}
+
+func (e *edge[T]) pop() T {
+	var zero T
+	v := e.queue[0]
+	e.queue[0] = zero
+	e.queue = e.queue[1:]
+	if e.spilled[0] && e.spill != nil {
+		e.spill.ack()
+	}
+	e.spilled = e.spilled[1:]
+	e.queued.Add(-1)
+	e.memory.add(-recordBytes(v))
+	return v
+}
+
+func (e *edge[T]) drop(v T) {
+	e.dropped++
+	e.telemetry.recordDrop(e.name, e.policy)
+	if e.onDrop != nil {
+		e.onDrop(v)
+	}
+}
@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testEdge cria um canal "testCh" com a política policy e capacidade capacity.
+func testEdge(t *testing.T, policy string, capacity int) (*edge[DataRecord], *Telemetry, *sync.WaitGroup) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Pipeline.ChannelBufferSize = capacity
+	cfg.Advanced.Backpressure.Edges = map[string]string{"testCh": policy}
+	cfg.Advanced.Backpressure.SampleEvery = 2
+	cfg.Advanced.Backpressure.SpillDir = t.TempDir()
+	telemetry := NewTelemetry()
+	var wg sync.WaitGroup
+	e := newEdge[DataRecord](newEdgeSet(context.Background(), &wg, cfg, telemetry), "testCh", nil)
+	return e, telemetry, &wg
+}
+
+// sendAll envia os registros de sequência 1..n em e.in e o fecha.
+func sendAll(e *edge[DataRecord], n int) {
+	for i := 1; i <= n; i++ {
+		e.in <- DataRecord{ID: fmt.Sprint(i), Seq: uint64(i)}
+	}
+	close(e.in)
+}
+
+func receivedSeqs(e *edge[DataRecord]) []uint64 {
+	var seqs []uint64
+	for record := range e.out {
+		seqs = append(seqs, record.Seq)
+	}
+	return seqs
+}
+
+func TestEdgeDropPolicies(t *testing.T) {
+	for policy, want := range map[string]string{
+		BackpressureDropNewest: "[1 2 3]",
+		BackpressureDropOldest: "[4 5 6]",
+	} {
+		e, telemetry, wg := testEdge(t, policy, 3)
+		sendAll(e, 6)
+		got := receivedSeqs(e)
+		wg.Wait()
+		if fmt.Sprint(got) != want {
+			t.Errorf("%s: expected %s, got %v", policy, want, got)
+		}
+		if dropped := telemetry.droppedRecords()["testCh"]; dropped != 3 {
+			t.Errorf("%s: expected 3 dropped records, got %d", policy, dropped)
+		}
+	}
+}
+
+func TestEdgeSampleKeepsEveryNth(t *testing.T) {
+	e, telemetry, wg := testEdge(t, BackpressureSample, 2)
+	go sendAll(e, 20)
+	time.Sleep(20 * time.Millisecond) // Deixa o canal encher antes de consumir
+	got := receivedSeqs(e)
+	wg.Wait()
+
+	dropped := telemetry.droppedRecords()["testCh"]
+	if len(got)+dropped != 20 || dropped == 0 {
+		t.Fatalf("Expected some of the 20 records dropped and the rest delivered, got %d delivered and %d dropped", len(got), dropped)
+	}
+	if got[0] != 1 || got[1] != 2 || got[2] != 4 {
+		t.Errorf("Expected 1 and 2 queued and 4 kept as the second sample, got %v", got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Fatalf("Sampled records out of order: %v", got)
+		}
+	}
+}
+
+func TestEdgeSpillPreservesOrder(t *testing.T) {
+	e, telemetry, wg := testEdge(t, BackpressureSpill, 2)
+	sendAll(e, 50)
+	// O último registro entra na fila logo após ser recebido
+	deadline := time.Now().Add(time.Second)
+	for e.depth() != 50 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if depth := e.depth(); depth != 50 {
+		t.Errorf("Expected depth to include spilled records, got %d", depth)
+	}
+	got := receivedSeqs(e)
+	wg.Wait()
+
+	if len(got) != 50 {
+		t.Fatalf("Expected all 50 records, got %d", len(got))
+	}
+	for i, seq := range got {
+		if seq != uint64(i+1) {
+			t.Fatalf("Expected records in order with Seq preserved, got %v", got)
+		}
+	}
+	if telemetry.droppedRecords() != nil {
+		t.Errorf("Expected no drops, got %v", telemetry.droppedRecords())
+	}
+	if n := telemetry.spilled["testCh"]; n != 48 {
+		t.Errorf("Expected 48 spilled records, got %d", n)
+	}
+	if entries, _ := os.ReadDir(e.spillDir); len(entries) != 0 {
+		t.Errorf("Expected spill files to be removed, found %d", len(entries))
+	}
+}
+
+// slowSink consome um registro por milissegundo.
+type slowSink struct{ received int }
+
+func (s *slowSink) Name() string { return "SlowSink" }
+
+func (s *slowSink) Consume(_ context.Context, in <-chan ProcessedRecord) {
+	for range in {
+		s.received++
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSlowSinkDoesNotStallOthers(t *testing.T) {
+	var records []DataRecord
+	for i := 0; i < 300; i++ {
+		records = append(records, DataRecord{ID: fmt.Sprintf("r-%03d", i)})
+	}
+	cfg := DefaultConfig()
+	cfg.Pipeline.ChannelBufferSize = 5
+	cfg.Advanced.Backpressure.Edges = map[string]string{"slowSinkCh": BackpressureDropNewest}
+	slow, fast := &slowSink{}, &memorySink{}
+	p, err := NewBuilder(cfg).
+		WithSource(sliceSource{records: records}).
+		AddSink(slow).
+		AddSink(fast).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	metrics, _ := p.Run(context.Background())
+
+	if metrics.ProcessedCount != 300 || len(fast.processed) != 300 {
+		t.Errorf("Expected all 300 records counted and in the other sink, got %d and %d", metrics.ProcessedCount, len(fast.processed))
+	}
+	dropped := metrics.Dropped["slowSinkCh"]
+	if dropped == 0 || slow.received+dropped != 300 {
+		t.Errorf("Expected the slow sink to lose records to the policy, got %d received and %d dropped", slow.received, dropped)
+	}
+}
+
+func TestBackpressureConfigValidation(t *testing.T) {
+	for yaml, want := range map[string]string{
+		"advanced:\n  backpressure:\n    policy: shed\n":                                       "advanced.backpressure.policy deve ser um de block, drop_newest",
+		"advanced:\n  backpressure:\n    edges:\n      loaderCh: later\n":                      "advanced.backpressure.edges.loaderCh",
+		"advanced:\n  backpressure:\n    sample_every: 0\n":                                    "advanced.backpressure.sample_every",
+		"pipeline:\n  channel_buffer_size: 0\nadvanced:\n  backpressure:\n    policy: spill\n": "channel_buffer_size >= 1",
+	} {
+		if _, err := ParseConfig([]byte(yaml)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q for %q, got %v", want, yaml, err)
+		}
+	}
+
+	cfg, err := ParseConfig([]byte("advanced:\n  backpressure_enabled: false\n  backpressure:\n    policy: drop_oldest\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if policy := cfg.Advanced.edgePolicy("loaderCh"); policy != BackpressureBlock {
+		t.Errorf("Expected policies to be ignored while backpressure is disabled, got %s", policy)
+	}
+	cfg.Advanced.BackpressureEnabled = true
+	cfg.Advanced.Backpressure.Edges = map[string]string{"transformerCh": BackpressureSpill}
+	if policy := cfg.Advanced.edgePolicy("transformerCh[2]"); policy != BackpressureSpill {
+		t.Errorf("Expected lane channels to use the edge policy, got %s", policy)
+	}
+}
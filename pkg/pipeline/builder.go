@@ -0,0 +1,335 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Source produz os registros que alimentam a pipeline.
+// Run envia registros para out e registros ilegíveis para errCh, retornando quando a fonte
+// se esgota ou ctx é cancelado. A pipeline fecha out e errCh; Run não deve fechá-los.
+type Source interface {
+	Name() string
+	Run(ctx context.Context, out chan<- DataRecord, errCh chan<- DataRecord)
+}
+
+// Processor é uma etapa intermediária aplicada a cada registro, executada por
+// pipeline.workers goroutines em paralelo. Implementações devem ser seguras para uso concorrente.
+// Retornar erro envia o registro para o caminho de erro; se record.Error estiver vazio,
+// a mensagem do erro é usada.
+type Processor interface {
+	Name() string
+	Process(ctx context.Context, record ProcessedRecord) (ProcessedRecord, error)
+}
+
+// Sink consome os registros processados com sucesso.
+// Consume retorna quando in é fechado ou ctx é cancelado, liberando seus recursos.
+type Sink interface {
+	Name() string
+	Consume(ctx context.Context, in <-chan ProcessedRecord)
+}
+
+// ErrorSink consome os registros que falharam em alguma etapa.
+// ConsumeErrors retorna quando in é fechado ou ctx é cancelado, liberando seus recursos.
+type ErrorSink interface {
+	Name() string
+	ConsumeErrors(ctx context.Context, in <-chan DataRecord)
+}
+
+// ObservedSink pode ser implementado por Sinks que gravam em lotes para reportar a latência de
+// cada escrita. Run chama ConsumeObserved no lugar de Consume; as escritas são expostas em
+// pipeline_sink_write_duration_seconds e resumidas em Metrics.SinkWrites.
+type ObservedSink interface {
+	Sink
+	ConsumeObserved(ctx context.Context, in <-chan ProcessedRecord, observe WriteObserver)
+}
+
+// WriteObserver recebe o número de registros e a duração de uma escrita concluída.
+type WriteObserver func(records int, d time.Duration)
+
+// ResumableSource é uma Source de arquivo que pode continuar a leitura a partir de um
+// checkpoint (ver CheckpointConfig). Os registros emitidos, inclusive os enviados para errCh,
+// devem ter SourceLine preenchido e em ordem crescente.
+type ResumableSource interface {
+	Source
+	// Input identifica o arquivo lido, guardado no checkpoint.
+	Input() string
+	// ResumeAfter retorna uma cópia da Source que ignora as linhas até line, inclusive, e as
+	// linhas em done, posteriores a line e também já concluídas.
+	ResumeAfter(line int, done []int) Source
+}
+
+// DurableSink pode ser implementado por Sinks que sabem quando seus registros chegaram ao
+// disco. Com source.checkpoint, Run chama ConsumeDurable no lugar de Consume e só avança o
+// checkpoint sobre registros confirmados por todos os DurableSinks e DurableErrorSinks.
+type DurableSink interface {
+	Sink
+	ConsumeDurable(ctx context.Context, in <-chan ProcessedRecord, durability Durability)
+}
+
+// DurableErrorSink é o equivalente de DurableSink para os ErrorSinks.
+type DurableErrorSink interface {
+	ErrorSink
+	ConsumeErrorsDurable(ctx context.Context, in <-chan DataRecord, durability Durability)
+}
+
+// Durability liga um DurableSink aos checkpoints da execução.
+type Durability struct {
+	Resume   bool          // A execução continua um checkpoint: acrescentar à saída anterior em vez de sobrescrevê-la
+	Interval time.Duration // Intervalo máximo entre fsyncs
+	// Commit recebe, após cada fsync, as sequências (DataRecord.Seq) dos registros gravados
+	// desde a chamada anterior.
+	Commit func(seqs []uint64)
+}
+
+// TransactionalSink pode ser implementado por Sinks de arquivo que gravam em segmentos
+// temporários e só os publicam, por renomeação atômica, no commit do checkpoint que os cobre.
+// Com source.checkpoint.exactly_once, Run chama ConsumeTransactional no lugar de Consume e uma
+// nova execução após uma falha grava cada registro exatamente uma vez nos segmentos publicados.
+type TransactionalSink interface {
+	Sink
+	ConsumeTransactional(ctx context.Context, in <-chan ProcessedRecord, txn Transaction)
+}
+
+// TransactionalErrorSink é o equivalente de TransactionalSink para os ErrorSinks.
+type TransactionalErrorSink interface {
+	ErrorSink
+	ConsumeErrorsTransactional(ctx context.Context, in <-chan DataRecord, txn Transaction)
+}
+
+// Transaction liga um TransactionalSink aos commits da execução.
+type Transaction struct {
+	Interval time.Duration // Intervalo máximo entre segmentos preparados
+	// Prepare recebe cada segmento temporário já sincronizado em disco, que o próximo commit
+	// publica. O Sink não deve mais alterá-lo.
+	Prepare func(segment PreparedSegment)
+}
+
+// PreparedSegment é um segmento temporário à espera de commit.
+type PreparedSegment struct {
+	Temp  string   // Arquivo temporário sincronizado
+	Final string   // Nome do segmento publicado
+	Seqs  []uint64 // Sequências (DataRecord.Seq) dos registros que ele contém
+}
+
+// Builder compõe uma Pipeline a partir de uma Source, Processors em sequência e Sinks.
+type Builder struct {
+	cfg        Config
+	source     Source
+	processors []Processor
+	sinks      []Sink
+	errorSinks []ErrorSink
+	lateSink   ErrorSink
+	telemetry  *Telemetry
+	err        error // Primeiro erro de composição, reportado por Build
+}
+
+// NewBuilder cria um Builder vazio; cfg fornece workers, buffers e prazo de drenagem.
+func NewBuilder(cfg Config) *Builder {
+	return &Builder{cfg: cfg}
+}
+
+// DefaultBuilder cria um Builder com as etapas padrão descritas por cfg:
+// fonte (Producer ou arquivo JSONL ou CSV), Validator, Transformer, Loader (CSVLoader ou
+// ParquetLoader conforme output.format, ou PartitionedLoader com output.partition.dir),
+// ParquetLoader adicional com output.parquet.path, PostgresLoader com output.postgres.dsn,
+// ErrorHandler e, com output.sqlite.path, SQLiteLoader e SQLiteErrorHandler.
+func DefaultBuilder(cfg Config) *Builder {
+	b := NewBuilder(cfg)
+	switch cfg.Source.Type {
+	case SourceJSONL:
+		b.WithSource(JSONLFileSource{Path: cfg.Source.Path})
+	case SourceCSV:
+		b.WithSource(CSVFileSource{Path: cfg.Source.Path, CSV: cfg.Source.CSV})
+	default:
+		b.WithSource(ProducerSource{NumRecords: cfg.Pipeline.NumRecords, Config: cfg.Producer})
+	}
+	validator, err := NewValidatorProcessor(cfg.Validator)
+	if err != nil {
+		b.err = fmt.Errorf("pipeline: regras de validação inválidas: %w", err)
+		return b
+	}
+	transformer, err := NewTransformerProcessor(cfg.Transformer)
+	if err != nil {
+		b.err = fmt.Errorf("pipeline: detector de anomalias inválido: %w", err)
+		return b
+	}
+	b.AddProcessor(validator).AddProcessor(transformer)
+	if cfg.Output.Partition.Enabled() {
+		b.AddSink(PartitionedSink{Partition: cfg.Output.Partition})
+	} else if cfg.Output.Format == FormatCSV {
+		b.AddSink(CSVSink{Path: cfg.Output.ProcessedFile, CSV: cfg.Output.CSV})
+	} else if cfg.Output.Format == FormatParquet {
+		b.AddSink(ParquetSink{Path: cfg.Output.ProcessedFile, Parquet: cfg.Output.Parquet})
+	} else {
+		b.AddSink(LoaderSink{Path: cfg.Output.ProcessedFile, Rotation: cfg.Output.Rotation})
+	}
+	if cfg.Output.Parquet.Path != "" {
+		b.AddSink(ParquetSink{Path: cfg.Output.Parquet.Path, Parquet: cfg.Output.Parquet})
+	}
+	if cfg.Output.Postgres.DSN != "" {
+		b.AddSink(PostgresSink{Postgres: cfg.Output.Postgres})
+	}
+	if cfg.Output.SQLite.Path != "" {
+		b.AddSink(SQLiteSink{SQLite: cfg.Output.SQLite}).AddErrorSink(SQLiteErrorSink{SQLite: cfg.Output.SQLite})
+	}
+	if cfg.Window.Enabled {
+		b.AddSink(WindowSink{Window: cfg.Window, Watermark: cfg.Watermark, Output: AggregateFileSink{Path: cfg.Window.OutputFile}})
+	}
+	if cfg.Session.Enabled {
+		b.AddSink(SessionSink{Session: cfg.Session, Watermark: cfg.Watermark, Output: SessionFileSink{Path: cfg.Session.OutputFile}})
+	}
+	if cfg.Watermark.Enabled && cfg.Watermark.LatePolicy == LateSideOutput {
+		b.WithLateSink(LateFileSink{Path: cfg.Watermark.LateFile})
+	}
+	return b.AddErrorSink(ErrorHandlerSink{Path: cfg.Output.FailedFile, Rotation: cfg.Output.Rotation})
+}
+
+// WithSource define a fonte de registros, substituindo a anterior.
+func (b *Builder) WithSource(source Source) *Builder {
+	b.source = source
+	return b
+}
+
+// AddProcessor acrescenta uma etapa ao final da sequência de processamento.
+func (b *Builder) AddProcessor(processor Processor) *Builder {
+	b.processors = append(b.processors, processor)
+	return b
+}
+
+// AddSink acrescenta um destino para os registros processados.
+func (b *Builder) AddSink(sink Sink) *Builder {
+	b.sinks = append(b.sinks, sink)
+	return b
+}
+
+// AddErrorSink acrescenta um destino para os registros com erro.
+func (b *Builder) AddErrorSink(sink ErrorSink) *Builder {
+	b.errorSinks = append(b.errorSinks, sink)
+	return b
+}
+
+// WithLateSink define o destino dos registros atrasados com watermark.late_policy
+// side_output, substituindo o anterior (ver WatermarkConfig).
+func (b *Builder) WithLateSink(sink ErrorSink) *Builder {
+	b.lateSink = sink
+	return b
+}
+
+// WithTelemetry define onde as métricas em tempo real da execução são acumuladas.
+// Sem ele, a Pipeline cria um Telemetry próprio, acessível por Pipeline.Telemetry.
+func (b *Builder) WithTelemetry(telemetry *Telemetry) *Builder {
+	b.telemetry = telemetry
+	return b
+}
+
+// Build valida a composição e retorna a Pipeline pronta para execução.
+func (b *Builder) Build() (*Pipeline, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.source == nil {
+		return nil, errors.New("pipeline: nenhuma Source definida")
+	}
+	for i, p := range b.processors {
+		if p == nil {
+			return nil, fmt.Errorf("pipeline: Processor %d é nil", i)
+		}
+	}
+	for i, s := range b.sinks {
+		if s == nil {
+			return nil, fmt.Errorf("pipeline: Sink %d é nil", i)
+		}
+	}
+	for i, s := range b.errorSinks {
+		if s == nil {
+			return nil, fmt.Errorf("pipeline: ErrorSink %d é nil", i)
+		}
+	}
+	if _, ok := b.source.(ResumableSource); b.cfg.Source.Checkpoint.Path != "" && !ok {
+		return nil, fmt.Errorf("pipeline: source.checkpoint não é suportado pela Source %s", b.source.Name())
+	}
+	if b.cfg.Source.Checkpoint.Path != "" && b.cfg.Advanced.usesPolicy(BackpressureSpill) {
+		// Os registros deixados na fila em disco não têm checkpoint e seriam relidos da entrada
+		return nil, fmt.Errorf("pipeline: source.checkpoint não suporta a política %q de backpressure", BackpressureSpill)
+	}
+	if b.cfg.Source.Checkpoint.ExactlyOnce {
+		// Um registro presente no segmento de um Sink e ausente no de outro seria repetido
+		transactional, transactionalErrors := 0, 0
+		for _, s := range b.sinks {
+			if _, ok := s.(TransactionalSink); ok {
+				transactional++
+			}
+		}
+		for _, s := range b.errorSinks {
+			if _, ok := s.(TransactionalErrorSink); ok {
+				transactionalErrors++
+			}
+		}
+		if transactional > 1 || transactionalErrors > 1 {
+			return nil, errors.New("pipeline: source.checkpoint.exactly_once admite um único TransactionalSink e um único TransactionalErrorSink")
+		}
+	}
+	if wm := b.cfg.Watermark; wm.Enabled && wm.LatePolicy == LateSideOutput && b.lateSink == nil {
+		return nil, errors.New("pipeline: watermark.late_policy side_output exige um LateSink (ver Builder.WithLateSink)")
+	}
+	if b.cfg.Pipeline.Workers < 1 {
+		return nil, fmt.Errorf("pipeline: pipeline.workers deve ser >= 1 (atual: %d)", b.cfg.Pipeline.Workers)
+	}
+	telemetry := b.telemetry
+	if telemetry == nil {
+		telemetry = NewTelemetry()
+	}
+	health := NewHealth(time.Duration(b.cfg.Monitoring.StallTimeout) * time.Second)
+	for _, s := range b.sinks {
+		if checker, ok := s.(HealthChecker); ok {
+			health.registerCheck(s.Name(), checker)
+		}
+	}
+	for _, s := range b.errorSinks {
+		if checker, ok := s.(HealthChecker); ok {
+			health.registerCheck(s.Name(), checker)
+		}
+	}
+	if checker, ok := b.lateSink.(HealthChecker); ok {
+		health.registerCheck(b.lateSink.Name(), checker)
+	}
+	return &Pipeline{
+		cfg:        b.cfg,
+		source:     b.source,
+		processors: append([]Processor(nil), b.processors...),
+		sinks:      append([]Sink(nil), b.sinks...),
+		errorSinks: append([]ErrorSink(nil), b.errorSinks...),
+		lateSink:   b.lateSink,
+		telemetry:  telemetry,
+		health:     health,
+	}, nil
+}
+
+// Pipeline é uma composição executável de Source, Processors e Sinks.
+type Pipeline struct {
+	cfg        Config
+	source     Source
+	processors []Processor
+	sinks      []Sink
+	errorSinks []ErrorSink
+	lateSink   ErrorSink // Saída lateral de watermark.late_policy side_output
+	telemetry  *Telemetry
+	health     *Health
+}
+
+// Health retorna o estado das etapas usado pelas sondas de liveness e readiness.
+func (p *Pipeline) Health() *Health {
+	return p.health
+}
+
+// Telemetry retorna as métricas em tempo real da Pipeline.
+func (p *Pipeline) Telemetry() *Telemetry {
+	return p.telemetry
+}
@@ -0,0 +1,229 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type sliceSource struct {
+	records []DataRecord
+}
+
+func (s sliceSource) Name() string { return "SliceSource" }
+
+func (s sliceSource) Run(ctx context.Context, out chan<- DataRecord, _ chan<- DataRecord) {
+	for _, record := range s.records {
+		if !send(ctx, out, record) {
+			return
+		}
+	}
+}
+
+type upperLocation struct{}
+
+func (upperLocation) Name() string { return "UpperLocation" }
+
+func (upperLocation) Process(_ context.Context, record ProcessedRecord) (ProcessedRecord, error) {
+	if record.Location == "" {
+		return record, errors.New("missing location")
+	}
+	record.Location = strings.ToUpper(record.Location)
+	return record, nil
+}
+
+type memorySink struct {
+	mu        sync.Mutex
+	processed []ProcessedRecord
+	failed    []DataRecord
+}
+
+func (s *memorySink) Name() string { return "MemorySink" }
+
+func (s *memorySink) Consume(_ context.Context, in <-chan ProcessedRecord) {
+	for record := range in {
+		s.mu.Lock()
+		s.processed = append(s.processed, record)
+		s.mu.Unlock()
+	}
+}
+
+func (s *memorySink) ConsumeErrors(_ context.Context, in <-chan DataRecord) {
+	for record := range in {
+		s.mu.Lock()
+		s.failed = append(s.failed, record)
+		s.mu.Unlock()
+	}
+}
+
+// memoryOutput guarda os itens recebidos pelas saídas auxiliares: é um AggregateSink com T
+// WindowAggregate e um SessionOutput com T SessionSummary.
+type memoryOutput[T any] struct {
+	mu    sync.Mutex
+	items []T
+}
+
+func (s *memoryOutput[T]) Name() string { return "MemoryOutput" }
+
+func (s *memoryOutput[T]) ConsumeAggregates(_ context.Context, in <-chan T) { s.consume(in) }
+
+func (s *memoryOutput[T]) ConsumeSessions(_ context.Context, in <-chan T) { s.consume(in) }
+
+func (s *memoryOutput[T]) consume(in <-chan T) {
+	for item := range in {
+		s.mu.Lock()
+		s.items = append(s.items, item)
+		s.mu.Unlock()
+	}
+}
+
+func (s *memoryOutput[T]) all() []T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]T(nil), s.items...)
+}
+
+func TestBuilderWithCustomStages(t *testing.T) {
+	source := sliceSource{records: []DataRecord{
+		{ID: "c-1", Location: "north"},
+		{ID: "c-2", Location: ""},
+		{ID: "c-3", Location: "south"},
+	}}
+	sink := &memorySink{}
+
+	p, err := NewBuilder(DefaultConfig()).
+		WithSource(source).
+		AddProcessor(upperLocation{}).
+		AddSink(sink).
+		AddErrorSink(sink).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+
+	metrics, reason := p.Run(context.Background())
+
+	if reason != StopCompleted {
+		t.Errorf("Expected stop reason %q, got %q", StopCompleted, reason)
+	}
+	if metrics.ProcessedCount != 2 || metrics.ErrorCount != 1 {
+		t.Errorf("Expected 2 processed and 1 error, got %d and %d", metrics.ProcessedCount, metrics.ErrorCount)
+	}
+	if len(sink.processed) != 2 {
+		t.Fatalf("Expected 2 records in sink, got %d", len(sink.processed))
+	}
+	for _, record := range sink.processed {
+		if record.Location != strings.ToUpper(record.Location) {
+			t.Errorf("Processor was not applied to %s: %s", record.ID, record.Location)
+		}
+	}
+	if len(sink.failed) != 1 || sink.failed[0].ID != "c-2" {
+		t.Fatalf("Expected c-2 in error sink, got %+v", sink.failed)
+	}
+	if sink.failed[0].Error != "missing location" || sink.failed[0].Status != "failed" {
+		t.Errorf("Expected error and status to be filled from the processor error, got %+v", sink.failed[0])
+	}
+}
+
+func TestBuilderWithoutProcessors(t *testing.T) {
+	sink := &memorySink{}
+	p, err := NewBuilder(DefaultConfig()).
+		WithSource(sliceSource{records: []DataRecord{{ID: "p-1"}, {ID: "p-2"}}}).
+		AddSink(sink).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+
+	metrics, _ := p.Run(context.Background())
+
+	if metrics.ProcessedCount != 2 || len(sink.processed) != 2 {
+		t.Errorf("Expected records to pass through unchanged, got %d in metrics and %d in sink",
+			metrics.ProcessedCount, len(sink.processed))
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	if _, err := NewBuilder(DefaultConfig()).Build(); err == nil {
+		t.Error("Expected error when no source is set")
+	}
+
+	cfg := DefaultConfig()
+	cfg.Pipeline.Workers = 0
+	if _, err := NewBuilder(cfg).WithSource(sliceSource{}).Build(); err == nil {
+		t.Error("Expected error when workers is zero")
+	}
+
+	if _, err := NewBuilder(DefaultConfig()).WithSource(sliceSource{}).AddProcessor(nil).Build(); err == nil {
+		t.Error("Expected error for nil processor")
+	}
+}
+
+// jitterProcessor espera um tempo aleatório por registro, para embaralhar a ordem entre
+// workers, e registra o maior número de registros processados ao mesmo tempo.
+type jitterProcessor struct {
+	name         string
+	active, peak *int32
+}
+
+func (p jitterProcessor) Name() string { return p.name }
+
+func (p jitterProcessor) Process(_ context.Context, record ProcessedRecord) (ProcessedRecord, error) {
+	n := atomic.AddInt32(p.active, 1)
+	defer atomic.AddInt32(p.active, -1)
+	for peak := atomic.LoadInt32(p.peak); n > peak && !atomic.CompareAndSwapInt32(p.peak, peak, n); {
+		peak = atomic.LoadInt32(p.peak)
+	}
+	time.Sleep(time.Duration(rand.Intn(300)) * time.Microsecond)
+	return record, nil
+}
+
+func TestPartitionKeyPreservesOrderPerKey(t *testing.T) {
+	var records []DataRecord
+	for i := 0; i < 50; i++ {
+		for s := 0; s < 8; s++ {
+			records = append(records, DataRecord{ID: fmt.Sprintf("sensor-%d/%02d", s, i), SensorID: fmt.Sprintf("sensor-%d", s), Value: float64(i)})
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.Pipeline.Workers = 4
+	cfg.Pipeline.ChannelBufferSize = 10
+	cfg.Pipeline.PartitionKey = "sensor_id"
+	var active, peak, active2, peak2 int32
+	sink := &memorySink{}
+	p, err := NewBuilder(cfg).
+		WithSource(sliceSource{records: records}).
+		AddProcessor(jitterProcessor{name: "First", active: &active, peak: &peak}).
+		AddProcessor(jitterProcessor{name: "Second", active: &active2, peak: &peak2}).
+		AddSink(sink).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	p.Run(context.Background())
+
+	if len(sink.processed) != len(records) {
+		t.Fatalf("Expected %d records, got %d", len(records), len(sink.processed))
+	}
+	last := map[string]float64{}
+	for _, record := range sink.processed {
+		if prev, ok := last[record.SensorID]; ok && record.Value != prev+1 {
+			t.Fatalf("Record %s arrived after value %g of the same sensor", record.ID, prev)
+		}
+		last[record.SensorID] = record.Value
+	}
+	if peak < 2 {
+		t.Errorf("Expected different keys to be processed in parallel, peak concurrency was %d", peak)
+	}
+
+	if _, err := ParseConfig([]byte("pipeline:\n  partition_key: value\n")); err == nil ||
+		!strings.Contains(err.Error(), "pipeline.partition_key deve ser um de date, day, hour, location") {
+		t.Errorf("Expected unknown partition key to be rejected, got %v", err)
+	}
+}
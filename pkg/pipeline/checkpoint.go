@@ -0,0 +1,362 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Checkpoint é o conteúdo do arquivo de source.checkpoint.path.
+type Checkpoint struct {
+	Input string `json:"input"`          // Arquivo de entrada (ver ResumableSource.Input)
+	Line  int    `json:"line"`           // Última linha até a qual todos os registros estão gravados em disco
+	Done  []int  `json:"done,omitempty"` // Linhas posteriores a Line também gravadas
+	// Publish lista os segmentos do último commit (ver TransactionalSink), renomeados de
+	// novo na recuperação se a execução parou antes de publicá-los
+	Publish   []PublishedSegment `json:"publish,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at"`
+}
+
+// PublishedSegment é um segmento temporário publicado por um commit.
+type PublishedSegment struct {
+	Temp  string `json:"temp"`
+	Final string `json:"final"`
+}
+
+// ReadCheckpoint lê o checkpoint em path. Sem arquivo, retorna um checkpoint vazio.
+func ReadCheckpoint(path string) (Checkpoint, error) {
+	var checkpoint Checkpoint
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return checkpoint, err
+	}
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return checkpoint, fmt.Errorf("checkpoint %s inválido: %w", path, err)
+	}
+	return checkpoint, nil
+}
+
+// checkpointTracker acompanha cada registro emitido pela Source até que todos os Sinks duráveis
+// de seu caminho o confirmem (ver DurableSink e TransactionalSink) e avança o checkpoint sobre
+// o maior prefixo contíguo de sequências concluídas; as concluídas fora dele vão para
+// Checkpoint.Done. Registros descartados pelo backpressure antes dos Sinks são concluídos no
+// descarte; um registro que nunca é confirmado, por falha de escrita ou aborto, é lido
+// novamente na próxima execução.
+//
+// Os segmentos preparados por TransactionalSinks aguardam em staged até o próximo save, que
+// grava o checkpoint com a lista de segmentos e só então os renomeia. O checkpoint é o ponto
+// de commit: na recuperação, os segmentos listados nele são publicados e os demais
+// temporários são descartados pelos Sinks.
+//
+// emitted, routed e commit aceitam um receptor nil, usado quando os checkpoints estão
+// desativados.
+type checkpointTracker struct {
+	path  string
+	input string
+
+	mu      sync.Mutex
+	next    uint64 // Menor sequência ainda não concluída
+	pending map[uint64]*checkpointEntry
+	line    int   // Linha do checkpoint
+	carried []int // Checkpoint.Done da execução anterior, ignoradas pela Source
+	staged  []PreparedSegment
+	changed bool // Há registros concluídos desde a última gravação
+	saved   int  // Linha gravada por último em path
+	resume  bool // A execução continua um checkpoint anterior
+}
+
+// checkpointEntry é um registro emitido e ainda não concluído.
+type checkpointEntry struct {
+	line   int
+	routed bool // Já chegou ao fan-out de seu caminho
+	acks   int  // Confirmações de Sinks duráveis ainda esperadas
+}
+
+// openCheckpoint lê o checkpoint de cfg, conclui a publicação de seus segmentos e retorna o
+// tracker e a Source que continua a leitura a partir dele. Um checkpoint de outro arquivo de
+// entrada é ignorado.
+func openCheckpoint(cfg CheckpointConfig, source ResumableSource) (*checkpointTracker, Source, error) {
+	checkpoint, err := ReadCheckpoint(cfg.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := publishSegments(checkpoint.Publish); err != nil {
+		return nil, nil, fmt.Errorf("falha ao publicar os segmentos do checkpoint %s: %w", cfg.Path, err)
+	}
+	t := &checkpointTracker{
+		path:    cfg.Path,
+		input:   source.Input(),
+		next:    1,
+		pending: make(map[uint64]*checkpointEntry),
+	}
+	if (checkpoint.Line > 0 || len(checkpoint.Done) > 0) && checkpoint.Input != t.input {
+		warnf("Pipeline: Checkpoint %s pertence a %s, não a %s; iniciando do começo", cfg.Path, checkpoint.Input, t.input)
+		return t, source, nil
+	}
+	if checkpoint.Line == 0 && len(checkpoint.Done) == 0 {
+		return t, source, nil
+	}
+	t.line, t.saved, t.carried, t.resume = checkpoint.Line, checkpoint.Line, checkpoint.Done, true
+	infof("Pipeline: Retomando %s após a linha %d e %d linhas posteriores (checkpoint de %s)",
+		t.input, checkpoint.Line, len(checkpoint.Done), checkpoint.UpdatedAt.Format(time.RFC3339))
+	return t, source.ResumeAfter(checkpoint.Line, checkpoint.Done), nil
+}
+
+// resumed reporta se a execução continua um checkpoint anterior.
+func (t *checkpointTracker) resumed() bool {
+	return t != nil && t.resume
+}
+
+// skippedLines retorna a função usada pelas Sources retomadas para ignorar as linhas já
+// concluídas: até line, inclusive, e as de done.
+func skippedLines(line int, done []int) func(int) bool {
+	set := make(map[int]bool, len(done))
+	for _, n := range done {
+		set[n] = true
+	}
+	return func(n int) bool { return n <= line || set[n] }
+}
+
+// emitted registra o registro seq, lido da linha line.
+func (t *checkpointTracker) emitted(seq uint64, line int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[seq] = &checkpointEntry{line: line}
+}
+
+// routed informa que seq chegou ao fan-out e aguarda acks confirmações; com zero, ou quando
+// o registro foi descartado antes do fan-out, ele é concluído imediatamente.
+func (t *checkpointTracker) routed(seq uint64, acks int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if entry := t.pending[seq]; entry != nil && !entry.routed {
+		entry.routed = true
+		entry.acks += acks
+		t.changed = t.changed || entry.acks <= 0
+		t.advance()
+	}
+}
+
+// commit recebe as confirmações de um Sink durável.
+func (t *checkpointTracker) commit(seqs []uint64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ack(seqs)
+}
+
+// prepared recebe um segmento de um TransactionalSink: seus registros são confirmados e ele
+// aguarda o próximo save para ser publicado.
+func (t *checkpointTracker) prepared(segment PreparedSegment) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if segment.Temp != "" {
+		t.staged = append(t.staged, segment)
+	}
+	t.ack(segment.Seqs)
+}
+
+// ack desconta uma confirmação de cada sequência. Chamado com mu travado.
+func (t *checkpointTracker) ack(seqs []uint64) {
+	for _, seq := range seqs {
+		if entry := t.pending[seq]; entry != nil {
+			entry.acks--
+			t.changed = t.changed || (entry.routed && entry.acks <= 0)
+		}
+	}
+	t.advance()
+}
+
+// advance conclui as sequências contíguas a partir de next. Chamado com mu travado.
+func (t *checkpointTracker) advance() {
+	for {
+		entry := t.pending[t.next]
+		if entry == nil || !entry.routed || entry.acks > 0 {
+			return
+		}
+		delete(t.pending, t.next)
+		t.line = entry.line
+		t.next++
+	}
+}
+
+// done lista as linhas concluídas após o checkpoint. Chamado com mu travado.
+func (t *checkpointTracker) done() []int {
+	var lines []int
+	for _, line := range t.carried {
+		if line > t.line {
+			lines = append(lines, line)
+		}
+	}
+	for _, entry := range t.pending {
+		if entry.routed && entry.acks <= 0 {
+			lines = append(lines, entry.line)
+		}
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// save grava o checkpoint em path se houve registros concluídos desde a última gravação e
+// então publica os segmentos preparados. Retorna a linha gravada.
+func (t *checkpointTracker) save() (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.changed && len(t.staged) == 0 {
+		return t.saved, nil
+	}
+	checkpoint := Checkpoint{Input: t.input, Line: t.line, Done: t.done(), UpdatedAt: time.Now().UTC()}
+	for _, segment := range t.staged {
+		checkpoint.Publish = append(checkpoint.Publish, PublishedSegment{Temp: segment.Temp, Final: segment.Final})
+	}
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return t.saved, err
+	}
+	if err := writeFileAtomic(t.path, append(data, '\n')); err != nil {
+		return t.saved, err
+	}
+	t.saved, t.changed, t.staged = t.line, false, nil
+	// Gravado o checkpoint, os segmentos estão confirmados; uma falha aqui é refeita na
+	// recuperação
+	return t.saved, publishSegments(checkpoint.Publish)
+}
+
+// publishSegments renomeia os segmentos temporários ainda não publicados e sincroniza seus
+// diretórios.
+func publishSegments(segments []PublishedSegment) error {
+	dirs := make(map[string]bool)
+	for _, segment := range segments {
+		if _, err := os.Stat(segment.Temp); errors.Is(err, os.ErrNotExist) {
+			continue // Já publicado
+		}
+		if err := os.Rename(segment.Temp, segment.Final); err != nil {
+			return err
+		}
+		dirs[filepath.Dir(segment.Final)] = true
+	}
+	for _, dir := range sortedKeys(dirs) {
+		if err := syncDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDir grava em disco as entradas do diretório dir, como renomeações.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+	return d.Sync()
+}
+
+// run grava o checkpoint a cada interval até done ser fechado.
+func (t *checkpointTracker) run(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := t.save(); err != nil {
+				errorf("Pipeline: Erro ao gravar checkpoint %s: %v", t.path, err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// sinkCommitter confirma os registros gravados por um Sink de arquivo: durableCommitter para
+// os DurableSinks e txnWriter para os TransactionalSinks.
+type sinkCommitter interface {
+	// written registra que o registro seq foi entregue ao writer.
+	written(seq uint64)
+	// ticks recebe quando é hora de chamar sync; nil se nunca.
+	ticks() <-chan time.Time
+	// sync grava em disco o que foi escrito e confirma os registros pendentes.
+	sync()
+	// closed encerra o committer após o writer ser fechado, com o erro do fechamento.
+	closed(closeErr error)
+}
+
+// durableCommitter acumula as sequências gravadas por um Sink durável e as confirma após cada
+// fsync do writer (ver Durability). Sem durability.Commit, não faz nada.
+type durableCommitter struct {
+	name       string
+	writer     recordWriter
+	durability Durability
+	pending    []uint64
+	ticker     *time.Ticker
+	tick       <-chan time.Time // Recebe a cada durability.Interval; nil sem Commit
+}
+
+func newDurableCommitter(name string, writer recordWriter, durability Durability) *durableCommitter {
+	c := &durableCommitter{name: name, writer: writer, durability: durability}
+	if durability.Commit != nil && durability.Interval > 0 {
+		c.ticker = time.NewTicker(durability.Interval)
+		c.tick = c.ticker.C
+	}
+	return c
+}
+
+func (c *durableCommitter) ticks() <-chan time.Time { return c.tick }
+
+func (c *durableCommitter) written(seq uint64) {
+	if c.durability.Commit != nil {
+		c.pending = append(c.pending, seq)
+	}
+}
+
+// sync sincroniza o writer e confirma os registros pendentes. Em caso de falha, eles continuam
+// pendentes até o próximo fsync.
+func (c *durableCommitter) sync() {
+	if len(c.pending) == 0 {
+		return
+	}
+	if err := c.writer.Sync(); err != nil {
+		errorf("%s: Erro ao sincronizar: %v", c.name, err)
+		return
+	}
+	c.flush()
+}
+
+// closed confirma os registros pendentes se o writer foi fechado com sucesso, o que inclui um
+// fsync.
+func (c *durableCommitter) closed(closeErr error) {
+	if c.ticker != nil {
+		c.ticker.Stop()
+	}
+	if closeErr == nil {
+		c.flush()
+	}
+}
+
+func (c *durableCommitter) flush() {
+	if len(c.pending) > 0 {
+		c.durability.Commit(c.pending)
+		c.pending = nil
+	}
+}
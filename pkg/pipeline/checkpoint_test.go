@@ -0,0 +1,313 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// cancelAfter cancela a execução após processar n registros.
+type cancelAfter struct {
+	n      int32
+	count  *atomic.Int32
+	cancel context.CancelFunc
+}
+
+func (c cancelAfter) Name() string { return "CancelAfter" }
+
+func (c cancelAfter) Process(_ context.Context, record ProcessedRecord) (ProcessedRecord, error) {
+	if c.count.Add(1) == c.n {
+		c.cancel()
+	}
+	return record, nil
+}
+
+// readIDs retorna os IDs dos registros JSONL em path.
+func readIDs(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	var ids []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record DataRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Invalid line in %s: %v", path, err)
+		}
+		ids = append(ids, record.ID)
+	}
+	return ids
+}
+
+// checkpointRun prepara em dir uma entrada de 100 linhas, uma em cada dez inválida, e retorna a
+// função que executa a pipeline sobre ela com o checkpoint de cfg.
+func checkpointRun(t *testing.T, dir string, cfg Config) func(context.Context, Processor) StopReason {
+	t.Helper()
+	input := filepath.Join(dir, "input.jsonl")
+	var lines []string
+	for i := 1; i <= 100; i++ {
+		if i%10 == 0 {
+			lines = append(lines, "{not json")
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(`{"id":"r-%03d","sensor_id":"sensor-1","location":"North","value":%d}`, i, i))
+	}
+	if err := os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return func(ctx context.Context, processor Processor) StopReason {
+		p, err := NewBuilder(cfg).
+			WithSource(JSONLFileSource{Path: input}).
+			AddProcessor(processor).
+			AddSink(LoaderSink{Path: filepath.Join(dir, "processed.jsonl")}).
+			AddErrorSink(ErrorHandlerSink{Path: filepath.Join(dir, "failed.jsonl")}).
+			Build()
+		if err != nil {
+			t.Fatalf("Unexpected build error: %v", err)
+		}
+		_, reason := p.Run(ctx)
+		return reason
+	}
+}
+
+// cancelledRun executa run com um Processor que cancela a execução após 20 registros.
+func cancelledRun(t *testing.T, run func(context.Context, Processor) StopReason) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if reason := run(ctx, cancelAfter{n: 20, count: new(atomic.Int32), cancel: cancel}); reason != StopCancelled {
+		t.Fatalf("Expected the first run to be cancelled, got %s", reason)
+	}
+}
+
+// checkWrittenOnce verifica que cada linha da entrada de checkpointRun aparece uma única vez
+// nos arquivos de saída.
+func checkWrittenOnce(t *testing.T, files ...string) {
+	t.Helper()
+	seen := make(map[string]int)
+	for _, file := range files {
+		for _, id := range readIDs(t, file) {
+			seen[id]++
+		}
+	}
+	for i := 1; i <= 100; i++ {
+		id := fmt.Sprintf("r-%03d", i)
+		if i%10 == 0 {
+			id = fmt.Sprintf("input.jsonl:%d", i)
+		}
+		if seen[id] != 1 {
+			t.Errorf("Expected %s to be written once across both runs, got %d", id, seen[id])
+		}
+		delete(seen, id)
+	}
+	if len(seen) > 0 {
+		t.Errorf("Unexpected records in the output: %v", seen)
+	}
+}
+
+func TestCheckpointResumesAfterCancel(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Pipeline.ChannelBufferSize = 5
+	cfg.Source.Checkpoint = CheckpointConfig{Path: filepath.Join(dir, "checkpoint.json"), Interval: 10 * time.Millisecond}
+	input := filepath.Join(dir, "input.jsonl")
+	processedFile := filepath.Join(dir, "processed.jsonl")
+	failedFile := filepath.Join(dir, "failed.jsonl")
+	run := checkpointRun(t, dir, cfg)
+
+	cancelledRun(t, run)
+	first, err := ReadCheckpoint(cfg.Source.Checkpoint.Path)
+	if err != nil {
+		t.Fatalf("Failed to read checkpoint: %v", err)
+	}
+	if first.Line < 20 || first.Line >= 100 || first.Input != input {
+		t.Fatalf("Expected a checkpoint of %s inside the file, got %+v", input, first)
+	}
+	if got := len(readIDs(t, processedFile)) + len(readIDs(t, failedFile)); got != first.Line {
+		t.Errorf("Expected the %d lines before the checkpoint to be written, found %d records", first.Line, got)
+	}
+
+	if reason := run(context.Background(), passThrough{}); reason != StopCompleted {
+		t.Fatalf("Expected the second run to complete, got %s", reason)
+	}
+	if last, _ := ReadCheckpoint(cfg.Source.Checkpoint.Path); last.Line != 100 {
+		t.Errorf("Expected the checkpoint at the last line, got %d", last.Line)
+	}
+	checkWrittenOnce(t, processedFile, failedFile)
+}
+
+func TestExactlyOnceRecoversInterruptedCommit(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Pipeline.Workers = 4
+	cfg.Pipeline.ChannelBufferSize = 5
+	cfg.Source.Checkpoint = CheckpointConfig{Path: filepath.Join(dir, "checkpoint.json"), Interval: 5 * time.Millisecond, ExactlyOnce: true}
+	run := checkpointRun(t, dir, cfg)
+	cancelledRun(t, run)
+
+	// Simula uma queda logo após gravar o checkpoint, antes de renomear seus segmentos, e um
+	// segmento preparado cujo commit não chegou a ser gravado
+	checkpoint, err := ReadCheckpoint(cfg.Source.Checkpoint.Path)
+	if err != nil || len(checkpoint.Publish) == 0 {
+		t.Fatalf("Expected the last commit to list its segments, got %+v (%v)", checkpoint, err)
+	}
+	for _, segment := range checkpoint.Publish {
+		if err := os.Rename(segment.Final, segment.Temp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale := filepath.Join(dir, "processed-000999.jsonl.tmp")
+	if err := os.WriteFile(stale, []byte(`{"id":"uncommitted"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if reason := run(context.Background(), passThrough{}); reason != StopCompleted {
+		t.Fatalf("Expected the second run to complete, got %s", reason)
+	}
+	if temps, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(temps) > 0 {
+		t.Errorf("Expected no temporary segments after the run, found %v", temps)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "processed.jsonl")); err == nil {
+		t.Error("Expected the output to be published only as segments")
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "processed-*.jsonl"))
+	failed, _ := filepath.Glob(filepath.Join(dir, "failed-*.jsonl"))
+	checkWrittenOnce(t, append(segments, failed...)...)
+}
+
+func TestCheckpointKeepsLinesDoneOutOfOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	tracker, _, err := openCheckpoint(CheckpointConfig{Path: path}, JSONLFileSource{Path: "input.jsonl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seq := uint64(1); seq <= 4; seq++ {
+		tracker.emitted(seq, int(seq)+1) // Linha 1 é o cabeçalho
+		tracker.routed(seq, 1)
+	}
+	tracker.prepared(PreparedSegment{Seqs: []uint64{1, 3, 4}})
+	if _, err := tracker.save(); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+
+	_, source, err := openCheckpoint(CheckpointConfig{Path: path}, JSONLFileSource{Path: "input.jsonl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed := source.(JSONLFileSource)
+	if resumed.SkipLines != 2 || fmt.Sprint(resumed.SkipDone) != "[4 5]" {
+		t.Errorf("Expected to skip up to line 2 and lines [4 5], got %d and %v", resumed.SkipLines, resumed.SkipDone)
+	}
+}
+
+func TestCheckpointTrackerWaitsForDurableSinks(t *testing.T) {
+	tracker := &checkpointTracker{next: 1, pending: make(map[uint64]*checkpointEntry)}
+	for seq := uint64(1); seq <= 4; seq++ {
+		tracker.emitted(seq, int(seq)*10)
+	}
+	tracker.routed(2, 0) // Descartado antes do fan-out
+	tracker.routed(1, 2) // Loader e ErrorHandler
+	tracker.routed(3, 1)
+	tracker.commit([]uint64{1, 3})
+	if tracker.line != 0 {
+		t.Fatalf("Expected no progress while record 1 waits for a sink, got line %d", tracker.line)
+	}
+	tracker.commit([]uint64{1})
+	if tracker.line != 30 {
+		t.Fatalf("Expected the checkpoint to reach line 30, got %d", tracker.line)
+	}
+	tracker.commit([]uint64{4}) // Ainda não chegou ao fan-out
+	if tracker.line != 30 || tracker.next != 4 {
+		t.Errorf("Expected record 4 to hold the checkpoint, got line %d and next %d", tracker.line, tracker.next)
+	}
+}
+
+func TestCheckpointIgnoresOtherInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := os.WriteFile(path, []byte(`{"input":"other.jsonl","line":40}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tracker, source, err := openCheckpoint(CheckpointConfig{Path: path}, JSONLFileSource{Path: "input.jsonl"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if tracker.resumed() || source.(JSONLFileSource).SkipLines != 0 {
+		t.Errorf("Expected a checkpoint of another input to be ignored, got %+v", source)
+	}
+
+	csvSource, _ := CSVFileSource{Path: "input.csv"}.ResumeAfter(3, []int{5}).(CSVFileSource)
+	if csvSource.SkipLines != 3 || len(csvSource.SkipDone) != 1 {
+		t.Errorf("Expected the CSV source to skip 3 lines and line 5, got %+v", csvSource)
+	}
+}
+
+func TestCheckpointConfigValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Source.Checkpoint.Path = "checkpoint.json"
+	cfg.Source.Checkpoint.Interval = 0
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "source.checkpoint exige source.type") ||
+		!strings.Contains(err.Error(), "source.checkpoint.interval") {
+		t.Errorf("Expected checkpoint errors, got %v", err)
+	}
+
+	if _, err := NewBuilder(cfg).WithSource(sliceSource{}).Build(); err == nil {
+		t.Error("Expected a Source without checkpoint support to be rejected")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Source.Checkpoint.ExactlyOnce = true
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "exactly_once exige source.checkpoint.path") {
+		t.Errorf("Expected exactly_once without a checkpoint path to be rejected, got %v", err)
+	}
+	cfg.Source.Checkpoint.Path = "checkpoint.json"
+	_, err = NewBuilder(cfg).
+		WithSource(JSONLFileSource{Path: "input.jsonl"}).
+		AddSink(LoaderSink{Path: "a.jsonl"}).
+		AddSink(LoaderSink{Path: "b.jsonl"}).
+		Build()
+	if err == nil {
+		t.Error("Expected two transactional sinks on the same path to be rejected")
+	}
+}
+
+// TestCheckpointRejectsSpillPolicy garante que o checkpoint não seja combinado com a fila em
+// disco: os registros que ela guarda ao abortar não têm checkpoint e seriam entregues de novo
+// junto com as linhas relidas da entrada.
+func TestCheckpointRejectsSpillPolicy(t *testing.T) {
+	dir := t.TempDir()
+	for name, configure := range map[string]func(*Config){
+		"edge":    func(cfg *Config) { cfg.Advanced.Backpressure.Edges = map[string]string{"loaderCh": BackpressureSpill} },
+		"default": func(cfg *Config) { cfg.Advanced.Backpressure.Policy = BackpressureSpill },
+	} {
+		cfg := DefaultConfig()
+		cfg.Source = SourceConfig{Type: SourceJSONL, Path: filepath.Join(dir, "input.jsonl")}
+		cfg.Source.Checkpoint = CheckpointConfig{Path: filepath.Join(dir, "checkpoint.json"), Interval: time.Second, ExactlyOnce: true}
+		configure(&cfg)
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "source.checkpoint não suporta a política") {
+			t.Errorf("%s: expected the spill policy to be rejected with a checkpoint, got %v", name, err)
+		}
+		_, err := NewBuilder(cfg).
+			WithSource(JSONLFileSource{Path: cfg.Source.Path}).
+			AddSink(LoaderSink{Path: filepath.Join(dir, "processed.jsonl")}).
+			Build()
+		if err == nil {
+			t.Errorf("%s: expected the pipeline not to be built", name)
+		}
+
+		cfg.Advanced.BackpressureEnabled = false
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("%s: expected an ignored spill policy to be accepted, got %v", name, err)
+		}
+	}
+}
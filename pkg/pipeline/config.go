@@ -0,0 +1,609 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config agrupa todas as configurações da pipeline, espelhando as seções de
+// config/config.example.yaml.
+type Config struct {
+	Pipeline    PipelineConfig    `yaml:"pipeline"`
+	Source      SourceConfig      `yaml:"source"`
+	Producer    ProducerConfig    `yaml:"producer"`
+	Validator   ValidatorConfig   `yaml:"validator"`
+	Transformer TransformerConfig `yaml:"transformer"`
+	Window      WindowConfig      `yaml:"window"`
+	Watermark   WatermarkConfig   `yaml:"watermark"`
+	Session     SessionConfig     `yaml:"session"`
+	Retry       RetryConfig       `yaml:"retry"`
+	Output      OutputConfig      `yaml:"output"`
+	Metrics     MetricsConfig     `yaml:"metrics"`
+	Monitoring  MonitoringConfig  `yaml:"monitoring"`
+	Advanced    AdvancedConfig    `yaml:"advanced"`
+}
+
+// PipelineConfig controla o dimensionamento geral da pipeline.
+type PipelineConfig struct {
+	Workers           int `yaml:"workers"`
+	NumRecords        int `yaml:"num_records"`
+	ChannelBufferSize int `yaml:"channel_buffer_size"`
+	// PartitionKey fixa os registros com o mesmo valor do campo (ex.: sensor_id) em um worker
+	// de cada etapa, preservando a ordem por chave até os Sinks; vazio distribui livremente
+	PartitionKey string `yaml:"partition_key"`
+	// Resequence devolve os registros processados à ordem da Source (ver ResequenceConfig)
+	Resequence ResequenceConfig `yaml:"resequence"`
+}
+
+// Tipos de fonte aceitos em source.type.
+const (
+	SourceSynthetic = "synthetic" // Producer com dados simulados
+	SourceJSONL     = "jsonl"     // Arquivo JSONL lido por JSONLSource
+	SourceCSV       = "csv"       // Arquivo CSV lido por CSVFileSource
+)
+
+// Formatos aceitos em output.format para processed_file.
+const (
+	FormatJSONL   = "jsonl"   // Gravado por LoaderSink
+	FormatCSV     = "csv"     // Gravado por CSVSink
+	FormatParquet = "parquet" // Gravado por ParquetSink
+)
+
+// SourceConfig define de onde vêm os registros da pipeline.
+type SourceConfig struct {
+	Type string    `yaml:"type"`
+	Path string    `yaml:"path"` // Arquivo de entrada para fontes baseadas em arquivo
+	CSV  CSVConfig `yaml:"csv"`  // Formato do arquivo quando type é csv
+
+	Checkpoint CheckpointConfig `yaml:"checkpoint"`
+}
+
+// CheckpointConfig guarda em Path a última linha da entrada cujos registros já estão gravados
+// em disco pelo Loader e pelo ErrorHandler, para que a próxima execução continue dali em vez de
+// recomeçar o arquivo (ver checkpointTracker). Com Path vazio, os checkpoints ficam desativados.
+type CheckpointConfig struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"` // Intervalo entre fsyncs dos Sinks e gravações do checkpoint
+	// ExactlyOnce publica a saída em segmentos renomeados junto com cada checkpoint, sem
+	// registros repetidos após uma falha (ver TransactionalSink)
+	ExactlyOnce bool `yaml:"exactly_once"`
+}
+
+// ProducerConfig controla a geração de dados simulados.
+type ProducerConfig struct {
+	RateLimit          float64  `yaml:"rate_limit"` // Registros por segundo, 0 para ilimitado
+	Locations          []string `yaml:"locations"`
+	NumSensors         int      `yaml:"num_sensors"`
+	ErrorInjectionRate float64  `yaml:"error_injection_rate"` // Fração de registros com erros intencionais
+}
+
+// ValidatorConfig controla as regras de validação.
+type ValidatorConfig struct {
+	MinValue       float64      `yaml:"min_value"`
+	MaxValue       float64      `yaml:"max_value"`
+	RequiredFields []string     `yaml:"required_fields"`
+	Rules          []RuleConfig `yaml:"rules"` // Regras adicionais, avaliadas após min/max e required_fields
+}
+
+// TransformerConfig controla o cálculo de anomalias e as unidades aceitas.
+type TransformerConfig struct {
+	AnomalyThreshold       float64  `yaml:"anomaly_threshold"`
+	AnomalyScoreMultiplier float64  `yaml:"anomaly_score_multiplier"`
+	ValidUnits             []string `yaml:"valid_units"` // Vazio aceita qualquer unidade
+
+	// Detector de anomalias (ver anomaly.go) e parâmetros dos detectores estatísticos
+	Detector   string  `yaml:"detector"`
+	WindowSize int     `yaml:"window_size"` // Valores por sensor na janela de zscore e mad
+	EWMAAlpha  float64 `yaml:"ewma_alpha"`  // Peso da observação mais recente no ewma
+	MinSamples int     `yaml:"min_samples"` // Observações por sensor antes de sinalizar anomalias
+	ZThreshold float64 `yaml:"z_threshold"` // Score acima do qual zscore, ewma e mad sinalizam anomalia
+}
+
+// OutputConfig define os destinos de saída e de log.
+type OutputConfig struct {
+	ProcessedFile string    `yaml:"processed_file"`
+	FailedFile    string    `yaml:"failed_file"`
+	LogFile       string    `yaml:"log_file"` // Vazio mantém os logs apenas no console
+	LogLevel      string    `yaml:"log_level"`
+	Format        string    `yaml:"format"` // jsonl, csv ou parquet, para processed_file
+	CSV           CSVConfig `yaml:"csv"`    // Formato de processed_file quando format é csv
+	// Rotation divide processed_file e failed_file em segmentos (ver RotationConfig)
+	Rotation RotationConfig `yaml:"rotation"`
+	// Partition substitui processed_file por diretórios particionados (ver PartitionConfig)
+	Partition PartitionConfig `yaml:"partition"`
+	// Parquet configura os arquivos Parquet: processed_file com format parquet e o arquivo
+	// adicional em parquet.path
+	Parquet ParquetConfig `yaml:"parquet"`
+	// SQLite grava também os registros processados e com erro em um banco SQLite (ver SQLiteConfig)
+	SQLite SQLiteConfig `yaml:"sqlite"`
+	// Postgres grava também os registros processados em uma tabela Postgres (ver PostgresConfig)
+	Postgres PostgresConfig `yaml:"postgres"`
+}
+
+// MetricsConfig controla a exportação de métricas.
+type MetricsConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	ExportInterval     int    `yaml:"export_interval"` // Segundos
+	PrometheusEndpoint string `yaml:"prometheus_endpoint"`
+}
+
+// MonitoringConfig controla os endpoints de health check e profiling.
+type MonitoringConfig struct {
+	HealthCheckEnabled bool   `yaml:"health_check_enabled"`
+	HealthCheckAddress string `yaml:"health_check_address"`
+	StallTimeout       int    `yaml:"stall_timeout"` // Segundos sem progresso com registros pendentes; 0 desativa
+	PprofEnabled       bool   `yaml:"pprof_enabled"`
+	PprofAddress       string `yaml:"pprof_address"`
+}
+
+// RetryConfig controla as novas tentativas de registros com falhas transitórias (ver Transient).
+// A espera antes da tentativa n+1 é initial_backoff * multiplier^(n-1), limitada a max_backoff
+// e variada aleatoriamente em ±jitter (fração da espera).
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"` // Inclui a primeira tentativa; 1 desativa as novas tentativas
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+	Jitter         float64       `yaml:"jitter"`
+}
+
+// AdvancedConfig agrupa ajustes finos de execução.
+type AdvancedConfig struct {
+	ShutdownTimeout     int                `yaml:"shutdown_timeout"`     // Segundos
+	BackpressureEnabled bool               `yaml:"backpressure_enabled"` // false faz todos os canais bloquearem, ignorando backpressure
+	Backpressure        BackpressureConfig `yaml:"backpressure"`
+	MaxMemoryMB         int                `yaml:"max_memory_mb"` // 0 para ilimitado
+}
+
+// Políticas aplicadas quando um canal entre etapas está cheio (ver BackpressureConfig).
+const (
+	BackpressureBlock      = "block"       // O produtor espera por espaço
+	BackpressureDropNewest = "drop_newest" // O registro que chega é descartado
+	BackpressureDropOldest = "drop_oldest" // O registro mais antigo do canal é descartado para dar lugar ao novo
+	BackpressureSample     = "sample"      // Mantém 1 a cada sample_every registros, à espera de espaço, e descarta os demais
+	BackpressureSpill      = "spill"       // Os registros excedentes vão para disco e voltam ao canal em ordem
+)
+
+// backpressurePolicies lista as políticas aceitas, na ordem usada nas mensagens de validação.
+var backpressurePolicies = []string{BackpressureBlock, BackpressureDropNewest, BackpressureDropOldest, BackpressureSample, BackpressureSpill}
+
+// BackpressureConfig escolhe a política de cada canal entre etapas. Os canais são identificados
+// pelos nomes usados na telemetria (dataCh, processedCh, errorCh, loaderCh, metricsProcessedCh, ...);
+// uma entrada para transformerCh vale para todas as suas faixas (ver PipelineConfig.PartitionKey).
+type BackpressureConfig struct {
+	Policy       string            `yaml:"policy"`        // Política dos canais sem entrada em edges
+	Edges        map[string]string `yaml:"edges"`         // Política por canal
+	SampleEvery  int               `yaml:"sample_every"`  // Usado pela política sample
+	SpillDir     string            `yaml:"spill_dir"`     // Filas da política spill, uma por canal; vazio usa <output.processed_file>.spill
+	SegmentBytes int64             `yaml:"segment_bytes"` // Tamanho máximo de cada segmento das filas em disco
+}
+
+// edgePolicy retorna a política do canal name, que pode ter o sufixo de faixa [n].
+func (a AdvancedConfig) edgePolicy(name string) string {
+	if !a.BackpressureEnabled {
+		return BackpressureBlock
+	}
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	if policy, ok := a.Backpressure.Edges[name]; ok {
+		return policy
+	}
+	return a.Backpressure.Policy
+}
+
+// usesPolicy reporta se algum canal usa a política policy.
+func (a AdvancedConfig) usesPolicy(policy string) bool {
+	if !a.BackpressureEnabled {
+		return false
+	}
+	if a.Backpressure.Policy == policy {
+		return true
+	}
+	for _, p := range a.Backpressure.Edges {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultConfig retorna a configuração padrão, equivalente a config/config.example.yaml
+// sem arquivo de log.
+func DefaultConfig() Config {
+	return Config{
+		Pipeline: PipelineConfig{
+			Workers:           3,
+			NumRecords:        100,
+			ChannelBufferSize: 100,
+			Resequence:        ResequenceConfig{MaxBuffer: 1000},
+		},
+		Source: SourceConfig{
+			Type:       SourceSynthetic,
+			CSV:        CSVConfig{Delimiter: ",", TimestampLayout: time.RFC3339Nano},
+			Checkpoint: CheckpointConfig{Interval: time.Second},
+		},
+		Producer: ProducerConfig{
+			RateLimit:          0,
+			Locations:          []string{"North", "South", "East", "West", "Center"},
+			NumSensors:         5,
+			ErrorInjectionRate: 0.2,
+		},
+		Validator: ValidatorConfig{
+			MinValue:       0,
+			MaxValue:       1000,
+			RequiredFields: []string{"id", "value", "unit", "timestamp", "sensor_id", "location"},
+		},
+		Transformer: TransformerConfig{
+			AnomalyThreshold:       8.0,
+			AnomalyScoreMultiplier: 0.1,
+			ValidUnits:             []string{"unit_A", "unit_B", "unit_C"},
+			Detector:               DetectorThreshold,
+			WindowSize:             100,
+			EWMAAlpha:              0.1,
+			MinSamples:             10,
+			ZThreshold:             3.0,
+		},
+		Window: WindowConfig{
+			Size:        time.Minute,
+			Percentiles: []float64{50, 90, 99},
+			OutputFile:  "window_aggregates.jsonl",
+		},
+		Watermark: WatermarkConfig{
+			MaxOutOfOrderness: 5 * time.Second,
+			LatePolicy:        LateError,
+			LateFile:          "late_data.jsonl",
+		},
+		Session: SessionConfig{
+			Gap:        30 * time.Second,
+			OutputFile: "sessions.jsonl",
+		},
+		Retry: RetryConfig{
+			MaxAttempts:    3,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     5 * time.Second,
+			Multiplier:     2,
+			Jitter:         0.2,
+		},
+		Output: OutputConfig{
+			ProcessedFile: "processed_data.jsonl",
+			FailedFile:    "failed_data.jsonl",
+			LogLevel:      "INFO",
+			Format:        FormatJSONL,
+			CSV:           CSVConfig{Delimiter: ",", TimestampLayout: time.RFC3339Nano},
+			Partition: PartitionConfig{
+				Template:     DefaultPartitionTemplate,
+				MaxOpenFiles: 64,
+			},
+			Parquet: ParquetConfig{
+				Compression:     CompressionSnappy,
+				RowGroupRecords: 100000,
+				RowGroupBytes:   128 << 20,
+			},
+			SQLite: SQLiteConfig{
+				ProcessedTable: "processed_records",
+				FailedTable:    "failed_records",
+				BatchSize:      500,
+				FlushInterval:  time.Second,
+				Retry: RetryConfig{
+					MaxAttempts:    5,
+					InitialBackoff: 200 * time.Millisecond,
+					MaxBackoff:     5 * time.Second,
+					Multiplier:     2,
+					Jitter:         0.2,
+				},
+			},
+			Postgres: PostgresConfig{
+				Table:         "processed_records",
+				Method:        PostgresCopy,
+				BatchSize:     1000,
+				FlushInterval: time.Second,
+				Retry: RetryConfig{
+					MaxAttempts:    5,
+					InitialBackoff: 500 * time.Millisecond,
+					MaxBackoff:     10 * time.Second,
+					Multiplier:     2,
+					Jitter:         0.2,
+				},
+			},
+		},
+		Metrics: MetricsConfig{
+			Enabled:            true,
+			ExportInterval:     10,
+			PrometheusEndpoint: ":9090/metrics",
+		},
+		Monitoring: MonitoringConfig{
+			HealthCheckAddress: ":8080/health",
+			StallTimeout:       30,
+			PprofAddress:       ":6060",
+		},
+		Advanced: AdvancedConfig{
+			ShutdownTimeout:     30,
+			BackpressureEnabled: true,
+			Backpressure: BackpressureConfig{
+				Policy:       BackpressureBlock,
+				SampleEvery:  10,
+				SegmentBytes: 4 << 20,
+			},
+		},
+	}
+}
+
+// LoadConfig lê e valida um arquivo YAML de configuração.
+// Chaves ausentes mantêm os valores de DefaultConfig; chaves desconhecidas são rejeitadas.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("config: falha ao ler %s: %w", path, err)
+	}
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// ParseConfig interpreta o conteúdo YAML de uma configuração e a valida.
+func ParseConfig(data []byte) (Config, error) {
+	cfg := DefaultConfig()
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("YAML inválido: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Validate verifica a consistência da configuração e reporta todos os problemas encontrados.
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Pipeline.Workers >= 1, "pipeline.workers deve ser >= 1 (atual: %d)", c.Pipeline.Workers)
+	check(c.Pipeline.NumRecords >= 0, "pipeline.num_records deve ser >= 0 (atual: %d)", c.Pipeline.NumRecords)
+	if key := c.Pipeline.PartitionKey; key != "" {
+		_, ok := partitionFields[key]
+		check(ok, "pipeline.partition_key deve ser um de %s (atual: %q)", strings.Join(sortedKeys(partitionFields), ", "), key)
+	}
+	check(!c.Pipeline.Resequence.Enabled || c.Pipeline.Resequence.MaxBuffer >= 1,
+		"pipeline.resequence.max_buffer deve ser >= 1 (atual: %d)", c.Pipeline.Resequence.MaxBuffer)
+	check(c.Pipeline.ChannelBufferSize >= 0, "pipeline.channel_buffer_size deve ser >= 0 (atual: %d)", c.Pipeline.ChannelBufferSize)
+
+	switch c.Source.Type {
+	case SourceSynthetic:
+	case SourceJSONL:
+		check(c.Source.Path != "", "source.path é obrigatório para source.type %q", c.Source.Type)
+	case SourceCSV:
+		check(c.Source.Path != "", "source.path é obrigatório para source.type %q", c.Source.Type)
+		err := c.Source.CSV.validate(csvRecordFields)
+		check(err == nil, "source.csv: %v", err)
+	default:
+		check(false, "source.type deve ser %q, %q ou %q (atual: %q)", SourceSynthetic, SourceJSONL, SourceCSV, c.Source.Type)
+	}
+	if cp := c.Source.Checkpoint; cp.Path != "" {
+		check(c.Source.Type == SourceJSONL || c.Source.Type == SourceCSV,
+			"source.checkpoint exige source.type %q ou %q (atual: %q)", SourceJSONL, SourceCSV, c.Source.Type)
+		check(cp.Interval > 0, "source.checkpoint.interval deve ser > 0 (atual: %s)", cp.Interval)
+		check(c.Output.Format == FormatJSONL && !c.Output.Partition.Enabled(),
+			"source.checkpoint exige output.format %q sem output.partition", FormatJSONL)
+		check(cp.Path != c.Source.Path && cp.Path != c.Output.ProcessedFile && cp.Path != c.Output.FailedFile,
+			"source.checkpoint.path deve ser diferente dos arquivos de entrada e saída (atual: %q)", cp.Path)
+		check(!cp.ExactlyOnce || !c.Output.Rotation.Enabled(),
+			"source.checkpoint.exactly_once não suporta output.rotation: a saída já é publicada em segmentos")
+		check(!c.Advanced.usesPolicy(BackpressureSpill),
+			"source.checkpoint não suporta a política %q em advanced.backpressure: os registros deixados na fila em disco seriam lidos de novo da entrada ao retomar",
+			BackpressureSpill)
+	}
+	check(c.Source.Checkpoint.Path != "" || !c.Source.Checkpoint.ExactlyOnce, "source.checkpoint.exactly_once exige source.checkpoint.path")
+
+	check(c.Producer.RateLimit >= 0, "producer.rate_limit deve ser >= 0 (atual: %g)", c.Producer.RateLimit)
+	check(len(c.Producer.Locations) > 0, "producer.locations não pode ser vazio")
+	for i, loc := range c.Producer.Locations {
+		check(strings.TrimSpace(loc) != "", "producer.locations[%d] não pode ser vazio", i)
+	}
+	check(c.Producer.NumSensors >= 1, "producer.num_sensors deve ser >= 1 (atual: %d)", c.Producer.NumSensors)
+	check(c.Producer.ErrorInjectionRate >= 0 && c.Producer.ErrorInjectionRate <= 1,
+		"producer.error_injection_rate deve estar entre 0 e 1 (atual: %g)", c.Producer.ErrorInjectionRate)
+
+	check(c.Validator.MinValue <= c.Validator.MaxValue,
+		"validator.min_value (%g) deve ser <= validator.max_value (%g)", c.Validator.MinValue, c.Validator.MaxValue)
+	if _, err := CompileRules(c.Validator); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	check(c.Transformer.AnomalyScoreMultiplier > 0,
+		"transformer.anomaly_score_multiplier deve ser > 0 (atual: %g)", c.Transformer.AnomalyScoreMultiplier)
+	if _, err := NewAnomalyDetector(c.Transformer); err != nil {
+		problems = append(problems, err.Error())
+	} else if c.Transformer.Detector != "" && c.Transformer.Detector != DetectorThreshold {
+		check(c.Transformer.MinSamples >= 1, "transformer.min_samples deve ser >= 1 (atual: %d)", c.Transformer.MinSamples)
+		check(c.Transformer.ZThreshold > 0, "transformer.z_threshold deve ser > 0 (atual: %g)", c.Transformer.ZThreshold)
+		if c.Transformer.Detector == DetectorEWMA {
+			check(c.Transformer.EWMAAlpha > 0 && c.Transformer.EWMAAlpha <= 1,
+				"transformer.ewma_alpha deve estar em (0, 1] (atual: %g)", c.Transformer.EWMAAlpha)
+		} else {
+			check(c.Transformer.WindowSize >= 2, "transformer.window_size deve ser >= 2 (atual: %d)", c.Transformer.WindowSize)
+			check(c.Transformer.MinSamples <= c.Transformer.WindowSize,
+				"transformer.min_samples (%d) deve ser <= transformer.window_size (%d)", c.Transformer.MinSamples, c.Transformer.WindowSize)
+		}
+	}
+	for i, unit := range c.Transformer.ValidUnits {
+		check(strings.TrimSpace(unit) != "", "transformer.valid_units[%d] não pode ser vazio", i)
+	}
+
+	if w := c.Window; w.Enabled {
+		check(w.Size > 0, "window.size deve ser > 0 (atual: %s)", w.Size)
+		check(w.Slide >= 0 && w.Slide <= w.Size, "window.slide deve estar entre 0 e window.size (atual: %s)", w.Slide)
+		for i, p := range w.Percentiles {
+			check(p >= 0 && p <= 100, "window.percentiles[%d] deve estar entre 0 e 100 (atual: %g)", i, p)
+		}
+		check(w.OutputFile != "", "window.output_file não pode ser vazio")
+		check(w.OutputFile != c.Output.ProcessedFile && w.OutputFile != c.Output.FailedFile,
+			"window.output_file deve ser diferente de output.processed_file e output.failed_file (atual: %q)", w.OutputFile)
+	}
+
+	// Janelas e sessões usam max_out_of_orderness mesmo sem watermark.enabled (ver WindowSink)
+	check(!(c.Watermark.Enabled || c.Window.Enabled || c.Session.Enabled) || c.Watermark.MaxOutOfOrderness >= 0,
+		"watermark.max_out_of_orderness deve ser >= 0 (atual: %s)", c.Watermark.MaxOutOfOrderness)
+	if wm := c.Watermark; wm.Enabled {
+		check(wm.AllowedLateness >= 0, "watermark.allowed_lateness deve ser >= 0 (atual: %s)", wm.AllowedLateness)
+		check(containsString(latePolicies, wm.LatePolicy),
+			"watermark.late_policy deve ser um de %s (atual: %q)", strings.Join(latePolicies, ", "), wm.LatePolicy)
+		if wm.LatePolicy == LateSideOutput {
+			check(wm.LateFile != "", "watermark.late_file não pode ser vazio com late_policy side_output")
+			check(wm.LateFile != c.Output.ProcessedFile && wm.LateFile != c.Output.FailedFile && wm.LateFile != c.Window.OutputFile,
+				"watermark.late_file deve ser diferente de output.processed_file, output.failed_file e window.output_file (atual: %q)", wm.LateFile)
+		}
+	}
+
+	if ss := c.Session; ss.Enabled {
+		check(ss.Gap > 0, "session.gap deve ser > 0 (atual: %s)", ss.Gap)
+		check(ss.OutputFile != "", "session.output_file não pode ser vazio")
+		check(ss.OutputFile != c.Output.ProcessedFile && ss.OutputFile != c.Output.FailedFile &&
+			ss.OutputFile != c.Window.OutputFile && ss.OutputFile != c.Watermark.LateFile,
+			"session.output_file deve ser diferente de output.processed_file, output.failed_file, window.output_file e watermark.late_file (atual: %q)", ss.OutputFile)
+	}
+
+	check(c.Retry.MaxAttempts >= 1, "retry.max_attempts deve ser >= 1 (atual: %d)", c.Retry.MaxAttempts)
+	check(c.Retry.InitialBackoff >= 0, "retry.initial_backoff deve ser >= 0 (atual: %s)", c.Retry.InitialBackoff)
+	check(c.Retry.MaxBackoff >= c.Retry.InitialBackoff,
+		"retry.max_backoff (%s) deve ser >= retry.initial_backoff (%s)", c.Retry.MaxBackoff, c.Retry.InitialBackoff)
+	check(c.Retry.Multiplier >= 1, "retry.multiplier deve ser >= 1 (atual: %g)", c.Retry.Multiplier)
+	check(c.Retry.Jitter >= 0 && c.Retry.Jitter <= 1, "retry.jitter deve estar entre 0 e 1 (atual: %g)", c.Retry.Jitter)
+
+	check(c.Output.ProcessedFile != "", "output.processed_file não pode ser vazio")
+	check(c.Output.FailedFile != "", "output.failed_file não pode ser vazio")
+	check(c.Output.ProcessedFile != c.Output.FailedFile,
+		"output.processed_file e output.failed_file devem ser diferentes (%s)", c.Output.ProcessedFile)
+	rot := c.Output.Rotation
+	check(rot.MaxBytes >= 0, "output.rotation.max_bytes deve ser >= 0 (atual: %d)", rot.MaxBytes)
+	check(rot.MaxRecords >= 0, "output.rotation.max_records deve ser >= 0 (atual: %d)", rot.MaxRecords)
+	check(rot.Interval >= 0, "output.rotation.interval deve ser >= 0 (atual: %s)", rot.Interval)
+	check(rot.MaxSegments >= 0, "output.rotation.max_segments deve ser >= 0 (atual: %d)", rot.MaxSegments)
+	check(rot.MaxAge >= 0, "output.rotation.max_age deve ser >= 0 (atual: %s)", rot.MaxAge)
+	check(rot.Compression == "" || containsString([]string{CompressionNone, CompressionGzip, CompressionZstd}, rot.Compression),
+		"output.rotation.compression deve ser %s, %s ou %s (atual: %q)", CompressionNone, CompressionGzip, CompressionZstd, rot.Compression)
+	check(rot.Enabled() || (rot.MaxSegments == 0 && rot.MaxAge == 0 && (rot.Compression == "" || rot.Compression == CompressionNone)),
+		"output.rotation: compression e retenção exigem max_bytes, max_records ou interval")
+	switch c.Output.Format {
+	case FormatJSONL:
+	case FormatCSV, FormatParquet:
+		if c.Output.Format == FormatCSV {
+			err := c.Output.CSV.validate(csvProcessedFields)
+			check(err == nil, "output.csv: %v", err)
+		}
+		check(!rot.Enabled() && !c.Output.Partition.Enabled(),
+			"output.format %q não suporta output.rotation nem output.partition", c.Output.Format)
+	default:
+		check(false, "output.format deve ser %q, %q ou %q (atual: %q)", FormatJSONL, FormatCSV, FormatParquet, c.Output.Format)
+	}
+	pq := c.Output.Parquet
+	_, known := parquetCodecs[pq.Compression]
+	check(known, "output.parquet.compression deve ser %s, %s, %s ou %s (atual: %q)",
+		CompressionSnappy, CompressionZstd, CompressionGzip, CompressionNone, pq.Compression)
+	check(pq.RowGroupRecords >= 0, "output.parquet.row_group_records deve ser >= 0 (atual: %d)", pq.RowGroupRecords)
+	check(pq.RowGroupBytes > 0, "output.parquet.row_group_bytes deve ser > 0 (atual: %d)", pq.RowGroupBytes)
+	check(pq.Path == "" || (pq.Path != c.Output.ProcessedFile && pq.Path != c.Output.FailedFile),
+		"output.parquet.path deve ser diferente de output.processed_file e output.failed_file (%s)", pq.Path)
+	if db := c.Output.SQLite; db.Path != "" {
+		check(db.Path != c.Output.ProcessedFile && db.Path != c.Output.FailedFile && db.Path != pq.Path,
+			"output.sqlite.path deve ser diferente dos demais arquivos de saída (%s)", db.Path)
+		check(sqlIdentifier.MatchString(db.ProcessedTable), "output.sqlite.processed_table inválido: %q", db.ProcessedTable)
+		check(sqlIdentifier.MatchString(db.FailedTable), "output.sqlite.failed_table inválido: %q", db.FailedTable)
+		check(!strings.EqualFold(db.ProcessedTable, db.FailedTable),
+			"output.sqlite.processed_table e output.sqlite.failed_table devem ser diferentes (%s)", db.FailedTable)
+		check(db.BatchSize >= 1, "output.sqlite.batch_size deve ser >= 1 (atual: %d)", db.BatchSize)
+		check(db.FlushInterval >= 0, "output.sqlite.flush_interval deve ser >= 0 (atual: %s)", db.FlushInterval)
+		r := db.Retry
+		check(r.MaxAttempts >= 1, "output.sqlite.retry.max_attempts deve ser >= 1 (atual: %d)", r.MaxAttempts)
+		check(r.InitialBackoff >= 0 && r.MaxBackoff >= r.InitialBackoff,
+			"output.sqlite.retry: initial_backoff (%s) deve ser >= 0 e <= max_backoff (%s)", r.InitialBackoff, r.MaxBackoff)
+		check(r.Multiplier >= 1, "output.sqlite.retry.multiplier deve ser >= 1 (atual: %g)", r.Multiplier)
+		check(r.Jitter >= 0 && r.Jitter <= 1, "output.sqlite.retry.jitter deve estar entre 0 e 1 (atual: %g)", r.Jitter)
+	}
+	if pg := c.Output.Postgres; pg.DSN != "" {
+		check(sqlIdentifier.MatchString(pg.Table), "output.postgres.table inválido: %q", pg.Table)
+		check(pg.Method == PostgresCopy || pg.Method == PostgresInsert,
+			"output.postgres.method deve ser %q ou %q (atual: %q)", PostgresCopy, PostgresInsert, pg.Method)
+		check(pg.BatchSize >= 1, "output.postgres.batch_size deve ser >= 1 (atual: %d)", pg.BatchSize)
+		check(pg.FlushInterval >= 0, "output.postgres.flush_interval deve ser >= 0 (atual: %s)", pg.FlushInterval)
+		r := pg.Retry
+		check(r.MaxAttempts >= 1, "output.postgres.retry.max_attempts deve ser >= 1 (atual: %d)", r.MaxAttempts)
+		check(r.InitialBackoff >= 0 && r.MaxBackoff >= r.InitialBackoff,
+			"output.postgres.retry: initial_backoff (%s) deve ser >= 0 e <= max_backoff (%s)", r.InitialBackoff, r.MaxBackoff)
+		check(r.Multiplier >= 1, "output.postgres.retry.multiplier deve ser >= 1 (atual: %g)", r.Multiplier)
+		check(r.Jitter >= 0 && r.Jitter <= 1, "output.postgres.retry.jitter deve estar entre 0 e 1 (atual: %g)", r.Jitter)
+	}
+	if part := c.Output.Partition; part.Enabled() {
+		_, err := parsePartitionTemplate(part.Template)
+		check(err == nil, "output.partition.template inválido: %v", err)
+		check(part.MaxOpenFiles >= 1, "output.partition.max_open_files deve ser >= 1 (atual: %d)", part.MaxOpenFiles)
+		check(part.MaxRecordsPerFile >= 0, "output.partition.max_records_per_file deve ser >= 0 (atual: %d)", part.MaxRecordsPerFile)
+	}
+
+	check(containsString(validLogLevels, strings.ToUpper(c.Output.LogLevel)),
+		"output.log_level deve ser um de %s (atual: %q)", strings.Join(validLogLevels, ", "), c.Output.LogLevel)
+
+	check(c.Metrics.ExportInterval >= 0, "metrics.export_interval deve ser >= 0 (atual: %d)", c.Metrics.ExportInterval)
+	check(c.Monitoring.StallTimeout >= 0, "monitoring.stall_timeout deve ser >= 0 (atual: %d)", c.Monitoring.StallTimeout)
+	check(c.Advanced.ShutdownTimeout >= 0, "advanced.shutdown_timeout deve ser >= 0 (atual: %d)", c.Advanced.ShutdownTimeout)
+	bp := c.Advanced.Backpressure
+	nonBlocking := bp.Policy != BackpressureBlock
+	check(containsString(backpressurePolicies, bp.Policy),
+		"advanced.backpressure.policy deve ser um de %s (atual: %q)", strings.Join(backpressurePolicies, ", "), bp.Policy)
+	for _, name := range sortedKeys(bp.Edges) {
+		policy := bp.Edges[name]
+		nonBlocking = nonBlocking || policy != BackpressureBlock
+		check(containsString(backpressurePolicies, policy),
+			"advanced.backpressure.edges.%s deve ser um de %s (atual: %q)", name, strings.Join(backpressurePolicies, ", "), policy)
+	}
+	check(bp.SampleEvery >= 1, "advanced.backpressure.sample_every deve ser >= 1 (atual: %d)", bp.SampleEvery)
+	check(bp.SegmentBytes >= 1, "advanced.backpressure.segment_bytes deve ser >= 1 (atual: %d)", bp.SegmentBytes)
+	check(!c.Advanced.BackpressureEnabled || !nonBlocking || c.Pipeline.ChannelBufferSize >= 1,
+		"advanced.backpressure: políticas diferentes de block exigem pipeline.channel_buffer_size >= 1")
+	check(c.Advanced.MaxMemoryMB >= 0, "advanced.max_memory_mb deve ser >= 0 (atual: %d)", c.Advanced.MaxMemoryMB)
+
+	if len(problems) > 0 {
+		return fmt.Errorf("configuração inválida:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// containsString indica se value está presente em values.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys retorna as chaves de m em ordem alfabética, para mensagens de erro estáveis.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfigExample(t *testing.T) {
+	cfg, err := LoadConfig("../../config/config.example.yaml")
+	if err != nil {
+		t.Fatalf("Example config should load, got error: %v", err)
+	}
+	if cfg.Pipeline.Workers != 3 {
+		t.Errorf("Expected 3 workers, got %d", cfg.Pipeline.Workers)
+	}
+	if cfg.Pipeline.NumRecords != 100 {
+		t.Errorf("Expected 100 records, got %d", cfg.Pipeline.NumRecords)
+	}
+	if len(cfg.Producer.Locations) != 5 {
+		t.Errorf("Expected 5 locations, got %d", len(cfg.Producer.Locations))
+	}
+	if cfg.Transformer.AnomalyThreshold != 8.0 {
+		t.Errorf("Expected anomaly threshold 8.0, got %f", cfg.Transformer.AnomalyThreshold)
+	}
+	if cfg.Retry.MaxAttempts != 3 || cfg.Retry.InitialBackoff != 100*time.Millisecond || cfg.Retry.MaxBackoff != 5*time.Second {
+		t.Errorf("Expected retry settings from the example, got %+v", cfg.Retry)
+	}
+	if cfg.Output.LogFile != "logs/pipeline.log" {
+		t.Errorf("Expected log file logs/pipeline.log, got %s", cfg.Output.LogFile)
+	}
+}
+
+func TestParseConfigDefaultsAndOverrides(t *testing.T) {
+	cfg, err := ParseConfig([]byte("pipeline:\n  workers: 7\nvalidator:\n  max_value: 50\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Pipeline.Workers != 7 {
+		t.Errorf("Expected 7 workers, got %d", cfg.Pipeline.Workers)
+	}
+	if cfg.Validator.MaxValue != 50 {
+		t.Errorf("Expected max_value 50, got %f", cfg.Validator.MaxValue)
+	}
+	if cfg.Pipeline.ChannelBufferSize != DefaultConfig().Pipeline.ChannelBufferSize {
+		t.Errorf("Missing keys should keep defaults, got buffer size %d", cfg.Pipeline.ChannelBufferSize)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"unknown key", "pipeline:\n  workerz: 3\n", "workerz"},
+		{"wrong type", "pipeline:\n  workers: many\n", "YAML inválido"},
+		{"zero workers", "pipeline:\n  workers: 0\n", "pipeline.workers"},
+		{"inverted range", "validator:\n  min_value: 10\n  max_value: 5\n", "validator.min_value"},
+		{"bad log level", "output:\n  log_level: TRACE\n", "output.log_level"},
+		{"bad injection rate", "producer:\n  error_injection_rate: 1.5\n", "producer.error_injection_rate"},
+		{"zero retry attempts", "retry:\n  max_attempts: 0\n", "retry.max_attempts"},
+		{"inverted backoff", "retry:\n  initial_backoff: 2s\n  max_backoff: 1s\n", "retry.max_backoff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConfig([]byte(tt.yaml))
+			if err == nil {
+				t.Fatalf("Expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Pipeline.Workers = 0
+	cfg.Output.ProcessedFile = ""
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	if !strings.Contains(err.Error(), "pipeline.workers") || !strings.Contains(err.Error(), "output.processed_file") {
+		t.Errorf("Expected both problems reported, got: %v", err)
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	defer func() { _ = SetLogLevel(LogInfo) }()
+
+	debugf("debug at info")
+	infof("info at info")
+	if err := SetLogLevel("debug"); err != nil {
+		t.Fatalf("Expected a lowercase level to be accepted, got: %v", err)
+	}
+	debugf("debug at debug")
+	if err := SetLogLevel(LogWarn); err != nil {
+		t.Fatal(err)
+	}
+	infof("info at warn")
+	errorf("error at warn")
+
+	got := buf.String()
+	for _, line := range []string{"info at info", "debug at debug", "error at warn"} {
+		if !strings.Contains(got, line) {
+			t.Errorf("Expected %q to be logged, got:\n%s", line, got)
+		}
+	}
+	for _, line := range []string{"debug at info", "info at warn"} {
+		if strings.Contains(got, line) {
+			t.Errorf("Expected %q to be filtered out, got:\n%s", line, got)
+		}
+	}
+	if err := SetLogLevel("TRACE"); err == nil {
+		t.Error("Expected an unknown level to be rejected")
+	}
+}
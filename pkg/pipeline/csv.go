@@ -0,0 +1,428 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Layouts especiais aceitos em csv.timestamp_layout, além dos layouts de time.Parse.
+const (
+	TimestampUnix   = "unix"    // Segundos desde a época, com fração opcional
+	TimestampUnixMs = "unix_ms" // Milissegundos desde a época
+)
+
+// csvRecordFields são as colunas de DataRecord lidas por CSVFileSource, na ordem padrão.
+var csvRecordFields = []string{"id", "timestamp", "sensor_id", "value", "unit", "location", "status"}
+
+// csvRequiredFields são as colunas que precisam existir no cabeçalho do arquivo de entrada.
+var csvRequiredFields = []string{"timestamp", "sensor_id", "value"}
+
+// csvProcessedFields são as colunas de ProcessedRecord gravadas por CSVSink.
+var csvProcessedFields = append(append([]string{}, csvRecordFields...), "anomaly_score", "is_anomaly", "processed_at")
+
+// CSVConfig descreve o formato de um arquivo CSV de entrada ou de saída.
+type CSVConfig struct {
+	Delimiter       string `yaml:"delimiter"`        // Um único caractere; padrão ","
+	TimestampLayout string `yaml:"timestamp_layout"` // Layout de time.Parse (padrão RFC 3339), unix ou unix_ms
+	// Columns mapeia campos (ex.: sensor_id) para o nome da coluna no cabeçalho, quando diferentes
+	Columns map[string]string `yaml:"columns"`
+}
+
+// validate verifica o delimitador, o layout e se Columns só usa campos de fields.
+func (c CSVConfig) validate(fields []string) error {
+	if r, size := utf8.DecodeRuneInString(c.Delimiter); c.Delimiter != "" &&
+		(size != len(c.Delimiter) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError) {
+		return fmt.Errorf("delimiter deve ser um único caractere diferente de aspas e quebra de linha (atual: %q)", c.Delimiter)
+	}
+	if c.TimestampLayout != "" && c.TimestampLayout != TimestampUnix && c.TimestampLayout != TimestampUnixMs &&
+		!strings.ContainsAny(c.TimestampLayout, "0123456789") {
+		return fmt.Errorf("timestamp_layout %q não é um layout de data válido", c.TimestampLayout)
+	}
+	for field, column := range c.Columns {
+		if !containsString(fields, field) {
+			return fmt.Errorf("columns usa o campo desconhecido %q (aceitos: %s)", field, strings.Join(fields, ", "))
+		}
+		if strings.TrimSpace(column) == "" {
+			return fmt.Errorf("columns.%s não pode ser vazio", field)
+		}
+	}
+	return nil
+}
+
+func (c CSVConfig) delimiter() rune {
+	if c.Delimiter == "" {
+		return ','
+	}
+	r, _ := utf8.DecodeRuneInString(c.Delimiter)
+	return r
+}
+
+// column retorna o nome da coluna de field no cabeçalho.
+func (c CSVConfig) column(field string) string {
+	if column, ok := c.Columns[field]; ok {
+		return column
+	}
+	return field
+}
+
+func (c CSVConfig) parseTime(value string) (time.Time, error) {
+	switch c.TimestampLayout {
+	case TimestampUnix:
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		whole, frac := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(frac*1e9)).UTC(), nil
+	case TimestampUnixMs:
+		ms, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.UnixMilli(ms).UTC(), nil
+	case "":
+		return time.Parse(time.RFC3339Nano, value)
+	default:
+		return time.Parse(c.TimestampLayout, value)
+	}
+}
+
+func (c CSVConfig) formatTime(t time.Time) string {
+	switch c.TimestampLayout {
+	case TimestampUnix:
+		return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', -1, 64)
+	case TimestampUnixMs:
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	case "":
+		return t.Format(time.RFC3339Nano)
+	default:
+		return t.Format(c.TimestampLayout)
+	}
+}
+
+// CSVFileSource é a Source que lê registros de um arquivo CSV com cabeçalho.
+// As colunas são localizadas pelo nome no cabeçalho (ver CSVConfig.Columns); colunas extras
+// são ignoradas. Linhas malformadas, com número de colunas diferente do cabeçalho ou valores
+// que não podem ser interpretados são enviadas para errCh com o número da linha.
+// Arquivos .gz e .zst são descomprimidos durante a leitura.
+type CSVFileSource struct {
+	Path      string
+	CSV       CSVConfig
+	SkipLines int   // Linhas iniciais já processadas por uma execução anterior (ver ResumeAfter)
+	SkipDone  []int // Linhas após SkipLines também já processadas
+}
+
+// Name identifica a etapa nos logs.
+func (s CSVFileSource) Name() string { return "CSVSource" }
+
+// Input identifica o arquivo lido nos checkpoints (ver ResumableSource).
+func (s CSVFileSource) Input() string { return s.Path }
+
+// ResumeAfter retorna uma cópia que ignora as linhas até line, inclusive, e as linhas em done.
+// O cabeçalho é sempre lido.
+func (s CSVFileSource) ResumeAfter(line int, done []int) Source {
+	s.SkipLines, s.SkipDone = line, done
+	return s
+}
+
+// Run lê o arquivo até o fim ou até ctx ser cancelado.
+func (s CSVFileSource) Run(ctx context.Context, out chan<- DataRecord, errCh chan<- DataRecord) {
+	path := s.Path
+	infof("CSVSource: Iniciando leitura de %s...", path)
+	if s.SkipLines > 0 {
+		infof("CSVSource: Retomando após a linha %d", s.SkipLines)
+	}
+
+	file, err := openRecordFile(path)
+	if err != nil {
+		log.Fatalf("CSVSource: Falha ao abrir arquivo de entrada: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	raw := &csvRawReader{r: file}
+	reader := csv.NewReader(raw)
+	reader.Comma = s.CSV.delimiter()
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		infof("CSVSource: %s está vazio.", path)
+		return
+	}
+	if err != nil {
+		errorf("CSVSource: Cabeçalho de %s inválido: %v", path, err)
+		return
+	}
+	columns, err := s.columnIndex(header)
+	if err != nil {
+		errorf("CSVSource: Cabeçalho de %s inválido: %v", path, err)
+		return
+	}
+	raw.take(reader.InputOffset())
+
+	name := filepath.Base(path)
+	skipped := skippedLines(s.SkipLines, s.SkipDone)
+	rows := 0
+	for ctx.Err() == nil {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		rows++
+		text := raw.take(reader.InputOffset())
+		var line int
+		var parseErr *csv.ParseError
+		switch {
+		case errors.As(err, &parseErr) && fields == nil:
+			// Aspas malformadas: a linha não foi separada em colunas
+			line = parseErr.StartLine
+		case err != nil && !errors.As(err, &parseErr):
+			errorf("CSVSource: Erro ao ler %s após %d linhas de dados: %v", path, rows-1, err)
+			return
+		default:
+			line, _ = reader.FieldPos(0)
+		}
+		if skipped(line) {
+			continue
+		}
+
+		var record DataRecord
+		if err == nil {
+			record, err = s.parseRow(fields, columns)
+		}
+		if err != nil {
+			failed := DataRecord{
+				ID:           fmt.Sprintf("%s:%d", name, line),
+				Status:       "parse_error",
+				Error:        fmt.Sprintf("Invalid CSV row at line %d: %v", line, err),
+				ErrorCodes:   []string{ErrCodeParseError},
+				SourceLine:   line,
+				Raw:          text,
+				SourceFormat: SourceCSV,
+			}
+			debugf("CSVSource: Linha %d inválida: %v", line, err)
+			if !send(ctx, errCh, failed) {
+				break
+			}
+			continue
+		}
+		if record.ID == "" {
+			record.ID = fmt.Sprintf("%s:%d", name, line)
+		}
+		record.SourceLine = line
+		if !send(ctx, out, record) {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		infof("CSVSource: Leitura interrompida após %d linhas de dados (%v)", rows, ctx.Err())
+		return
+	}
+	infof("CSVSource: Leitura de %s finalizada (%d linhas de dados).", path, rows)
+}
+
+// readHeader lê o cabeçalho de Path e localiza nele as colunas (ver columnIndex).
+func (s CSVFileSource) readHeader() (map[string]int, error) {
+	file, err := openRecordFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	reader := csv.NewReader(file)
+	reader.Comma = s.CSV.delimiter()
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("cabeçalho de %s inválido: %w", s.Path, err)
+	}
+	return s.columnIndex(header)
+}
+
+// columnIndex localiza no cabeçalho a posição de cada campo de DataRecord.
+func (s CSVFileSource) columnIndex(header []string) (map[string]int, error) {
+	positions := make(map[string]int, len(header))
+	for i, column := range header {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff") // BOM gravado por planilhas
+		}
+		positions[strings.TrimSpace(column)] = i
+	}
+	columns := make(map[string]int, len(csvRecordFields))
+	var missing []string
+	for _, field := range csvRecordFields {
+		if i, ok := positions[s.CSV.column(field)]; ok {
+			columns[field] = i
+		} else if containsString(csvRequiredFields, field) {
+			missing = append(missing, s.CSV.column(field))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("colunas obrigatórias ausentes: %s", strings.Join(missing, ", "))
+	}
+	return columns, nil
+}
+
+// parseLine converte o texto de uma linha de dados, como gravado em Raw, em DataRecord.
+func (s CSVFileSource) parseLine(text string, columns map[string]int) (DataRecord, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = s.CSV.delimiter()
+	reader.FieldsPerRecord = -1
+	fields, err := reader.Read()
+	if err != nil {
+		return DataRecord{}, err
+	}
+	for _, i := range columns {
+		if i >= len(fields) {
+			return DataRecord{}, fmt.Errorf("a linha tem %d colunas, menos que o cabeçalho", len(fields))
+		}
+	}
+	return s.parseRow(fields, columns)
+}
+
+// parseRow converte as colunas de uma linha em DataRecord.
+func (s CSVFileSource) parseRow(fields []string, columns map[string]int) (DataRecord, error) {
+	get := func(field string) string {
+		if i, ok := columns[field]; ok {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+	record := DataRecord{
+		ID:       get("id"),
+		SensorID: get("sensor_id"),
+		Unit:     get("unit"),
+		Location: get("location"),
+		Status:   get("status"),
+	}
+	value, err := strconv.ParseFloat(get("value"), 64)
+	if err != nil {
+		return record, fmt.Errorf("coluna %s: valor %q inválido", s.CSV.column("value"), get("value"))
+	}
+	record.Value = value
+	timestamp, err := s.CSV.parseTime(get("timestamp"))
+	if err != nil {
+		return record, fmt.Errorf("coluna %s: timestamp %q inválido", s.CSV.column("timestamp"), get("timestamp"))
+	}
+	record.Timestamp = timestamp
+	if record.Status == "" {
+		record.Status = "raw"
+	}
+	return record, nil
+}
+
+// csvRawReader guarda os bytes lidos por csv.Reader para recuperar o texto original de cada
+// linha, que o csv.Reader só entrega separado em colunas.
+type csvRawReader struct {
+	r    io.Reader
+	buf  []byte // Bytes lidos a partir de base
+	base int64
+}
+
+func (r *csvRawReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	return n, err
+}
+
+// take retorna o texto entre o fim da linha anterior e offset, sem a quebra de linha final,
+// e o descarta.
+func (r *csvRawReader) take(offset int64) string {
+	n := int(offset - r.base)
+	text := strings.TrimRight(string(r.buf[:n]), "\r\n")
+	r.buf = append(r.buf[:0], r.buf[n:]...)
+	r.base = offset
+	return text
+}
+
+// CSVSink é o Sink que grava os registros processados em CSV no arquivo em Path,
+// com cabeçalho e as colunas de csvProcessedFields. Cada execução sobrescreve Path.
+type CSVSink struct {
+	Path string
+	CSV  CSVConfig
+}
+
+// Name identifica a etapa nos logs.
+func (s CSVSink) Name() string { return "CSVLoader" }
+
+// CheckHealth reporta se Path pode receber escrita (ver HealthChecker).
+func (s CSVSink) CheckHealth() error { return checkWritable(s.Path) }
+
+// Consume grava cada registro de in até o canal ser fechado ou ctx ser cancelado.
+func (s CSVSink) Consume(ctx context.Context, in <-chan ProcessedRecord) {
+	path := s.Path
+	infof("CSVLoader: Iniciando carregamento de dados...")
+	writer, err := newRecordWriter("CSVLoader", path, RotationConfig{}, false)
+	if err != nil {
+		log.Fatalf("CSVLoader: Falha ao criar arquivo de saída: %v", err)
+	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			errorf("CSVLoader: Erro ao fechar %s: %v", path, err)
+		}
+	}()
+
+	var buf bytes.Buffer
+	row := csv.NewWriter(&buf)
+	row.Comma = s.CSV.delimiter()
+	writeRow := func(fields []string) error {
+		buf.Reset()
+		if err := row.Write(fields); err != nil {
+			return err
+		}
+		row.Flush()
+		if err := row.Error(); err != nil {
+			return err
+		}
+		return writer.Write(buf.Bytes())
+	}
+
+	header := make([]string, len(csvProcessedFields))
+	for i, field := range csvProcessedFields {
+		header[i] = s.CSV.column(field)
+	}
+	if err := writeRow(header); err != nil {
+		errorf("CSVLoader: Erro ao escrever cabeçalho em %s: %v", path, err)
+		return
+	}
+
+	for record := range in {
+		if ctx.Err() != nil {
+			warnf("CSVLoader: Gravação abortada (%v)", ctx.Err())
+			return
+		}
+		if err := writeRow(s.row(record)); err != nil {
+			errorf("CSVLoader: Erro ao escrever registro %s no arquivo: %v", record.ID, err)
+			continue
+		}
+		debugf("CSVLoader: Carregado %s (Anomaly: %t)", record.ID, record.IsAnomaly)
+	}
+	infof("CSVLoader: Carregamento de dados finalizado.")
+}
+
+// row retorna as colunas de record na ordem de csvProcessedFields.
+func (s CSVSink) row(record ProcessedRecord) []string {
+	return []string{
+		record.ID,
+		s.CSV.formatTime(record.Timestamp),
+		record.SensorID,
+		strconv.FormatFloat(record.Value, 'f', -1, 64),
+		record.Unit,
+		record.Location,
+		record.Status,
+		strconv.FormatFloat(record.AnomalyScore, 'f', -1, 64),
+		strconv.FormatBool(record.IsAnomaly),
+		s.CSV.formatTime(record.ProcessedAt),
+	}
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readCSV(t *testing.T, source CSVFileSource) ([]DataRecord, []DataRecord) {
+	t.Helper()
+	out := make(chan DataRecord, 20)
+	errCh := make(chan DataRecord, 20)
+	source.Run(context.Background(), out, errCh)
+	close(out)
+	close(errCh)
+	var records, failed []DataRecord
+	for record := range out {
+		records = append(records, record)
+	}
+	for record := range errCh {
+		failed = append(failed, record)
+	}
+	return records, failed
+}
+
+func TestCSVFileSource(t *testing.T) {
+	content := "\ufeffReading;Extra;Sensor;Measured At;location\n" +
+		"10.5;x;sensor-1;2025-01-15 10:30:00;North\n" +
+		"oops;x;sensor-2;2025-01-15 10:30:05;South\n" +
+		"20;x;sensor-3\n" +
+		"30;x;sensor-4;yesterday;East\n" +
+		"40;x;\"sensor-5\";2025-01-15 10:30:20;\"West; upper\"\n" +
+		"50;x;\"sensor\"6;2025-01-15 10:30:25;West\n"
+	path := filepath.Join(t.TempDir(), "vendor.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	source := CSVFileSource{Path: path, CSV: CSVConfig{
+		Delimiter:       ";",
+		TimestampLayout: "2006-01-02 15:04:05",
+		Columns:         map[string]string{"value": "Reading", "sensor_id": "Sensor", "timestamp": "Measured At"},
+	}}
+
+	records, failed := readCSV(t, source)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %+v", records)
+	}
+	first := records[0]
+	if first.ID != "vendor.csv:2" || first.Value != 10.5 || first.SensorID != "sensor-1" || first.Location != "North" ||
+		first.Status != "raw" || first.SourceLine != 2 || !first.Timestamp.Equal(time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected first record: %+v", first)
+	}
+	if records[1].Location != "West; upper" || records[1].SourceLine != 6 {
+		t.Errorf("Expected quoted delimiter to be kept, got %+v", records[1])
+	}
+
+	wantErrors := map[int]string{3: "Reading", 4: "wrong number of fields", 5: "Measured At", 7: "extraneous"}
+	if len(failed) != len(wantErrors) {
+		t.Fatalf("Expected %d malformed rows, got %+v", len(wantErrors), failed)
+	}
+	for _, record := range failed {
+		want, ok := wantErrors[record.SourceLine]
+		if !ok || record.Status != "parse_error" || !strings.Contains(record.Error, want) {
+			t.Errorf("Unexpected error record: %+v", record)
+		}
+		if !strings.Contains(record.Error, "line") || record.ErrorCodes[0] != ErrCodeParseError {
+			t.Errorf("Expected row number and parse error code, got %+v", record)
+		}
+	}
+	if failed[1].Raw != "20;x;sensor-3" || failed[1].SourceFormat != SourceCSV {
+		t.Errorf("Expected raw row to be kept, got %q (%s)", failed[1].Raw, failed[1].SourceFormat)
+	}
+	if want := `50;x;"sensor"6;2025-01-15 10:30:25;West`; failed[3].Raw != want {
+		t.Errorf("Expected the original line with its quotes, got %q", failed[3].Raw)
+	}
+}
+
+func TestCSVFileSourceMissingColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "input.csv")
+	if err := os.WriteFile(path, []byte("id,value\na,1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	records, failed := readCSV(t, CSVFileSource{Path: path})
+	if len(records) != 0 || len(failed) != 0 {
+		t.Errorf("Expected no records without the required columns, got %d and %d", len(records), len(failed))
+	}
+	columns, err := CSVFileSource{}.columnIndex([]string{"id", "value"})
+	if err == nil || !strings.Contains(err.Error(), "timestamp, sensor_id") {
+		t.Errorf("Expected missing columns to be reported, got %v (%v)", err, columns)
+	}
+}
+
+func TestCSVSinkRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "processed.csv")
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+	cfg := CSVConfig{Delimiter: "\t", TimestampLayout: TimestampUnixMs, Columns: map[string]string{"is_anomaly": "anomaly"}}
+
+	in := make(chan ProcessedRecord, 2)
+	in <- ProcessedRecord{
+		DataRecord:   DataRecord{ID: "r-1", Timestamp: ts, SensorID: "sensor-1", Value: 12.25, Unit: "unit_B", Location: "North, upper", Status: "processed"},
+		ProcessedAt:  ts.Add(time.Second),
+		AnomalyScore: 3.5,
+		IsAnomaly:    true,
+	}
+	in <- ProcessedRecord{DataRecord: DataRecord{ID: "r-2", Timestamp: ts, SensorID: "sensor-2", Location: "tab\there"}}
+	close(in)
+	CSVSink{Path: path, CSV: cfg}.Consume(context.Background(), in)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if want := "id\ttimestamp\tsensor_id\tvalue\tunit\tlocation\tstatus\tanomaly_score\tanomaly\tprocessed_at"; lines[0] != want {
+		t.Errorf("Unexpected header:\n%s\nwant:\n%s", lines[0], want)
+	}
+	if want := "r-1\t1736937000000\tsensor-1\t12.25\tunit_B\tNorth, upper\tprocessed\t3.5\ttrue\t1736937001000"; lines[1] != want {
+		t.Errorf("Unexpected row:\n%s\nwant:\n%s", lines[1], want)
+	}
+
+	records, failed := readCSV(t, CSVFileSource{Path: path, CSV: cfg})
+	if len(failed) != 0 || len(records) != 2 {
+		t.Fatalf("Expected sink output to be readable by the source, got %+v and %+v", records, failed)
+	}
+	if records[0].Value != 12.25 || !records[0].Timestamp.Equal(ts) || records[1].Location != "tab\there" {
+		t.Errorf("Round trip changed the records: %+v", records)
+	}
+}
+
+func TestCSVConfigValidation(t *testing.T) {
+	for yaml, want := range map[string]string{
+		"source:\n  type: csv\n": "source.path",
+		"source:\n  type: csv\n  path: in.csv\n  csv:\n    delimiter: \";;\"\n": "source.csv: delimiter",
+		"source:\n  type: csv\n  path: in.csv\n  csv:\n    columns: {foo: x}\n": "source.csv: columns",
+		"output:\n  format: xml\n":                                    "output.format",
+		"output:\n  format: csv\n  csv:\n    timestamp_layout: iso\n": "output.csv: timestamp_layout",
+		"output:\n  format: csv\n  rotation:\n    max_records: 10\n":  "não suporta",
+	} {
+		if _, err := ParseConfig([]byte(yaml)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got %v", want, err)
+		}
+	}
+	if _, err := ParseConfig([]byte("source:\n  type: csv\n  path: in.csv\n  csv:\n    delimiter: \"\\t\"\n    timestamp_layout: unix\noutput:\n  format: csv\n")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -5,35 +5,111 @@
 package pipeline
 
 import (
+	"context"
 	"encoding/json"
 	"log"
-	"os"
 	"time"
 )
 
-// ErrorHandler lida com registros que falharam em alguma etapa.
-func ErrorHandler(errorCh <-chan DataRecord) {
-	log.Println("ErrorHandler: Iniciando tratamento de erros...")
-	file, err := os.Create("failed_data.jsonl")
+// ErrorHandler lida com registros que falharam em alguma etapa, gravando-os em path.
+func ErrorHandler(ctx context.Context, errorCh <-chan DataRecord, path string) {
+	ErrorHandlerSink{Path: path}.ConsumeErrors(ctx, errorCh)
+}
+
+// ErrorHandlerSink é o ErrorSink padrão, que grava os registros em JSONL no arquivo em Path.
+type ErrorHandlerSink struct {
+	Path     string
+	Rotation RotationConfig // Sem critérios de rotação, cada execução sobrescreve Path
+}
+
+// Name identifica a etapa nos logs.
+func (s ErrorHandlerSink) Name() string { return "ErrorHandler" }
+
+// CheckHealth reporta se Path pode receber escrita (ver HealthChecker).
+func (s ErrorHandlerSink) CheckHealth() error { return checkWritable(s.Path) }
+
+// ConsumeErrors grava cada registro de errorCh até o canal ser fechado ou ctx ser cancelado.
+func (s ErrorHandlerSink) ConsumeErrors(ctx context.Context, errorCh <-chan DataRecord) {
+	s.ConsumeErrorsDurable(ctx, errorCh, Durability{})
+}
+
+// ConsumeErrorsDurable grava os registros como ConsumeErrors e, com durability.Commit, sincroniza o
+// arquivo a cada durability.Interval e ao terminar, confirmando os registros gravados desde o
+// fsync anterior (ver DurableErrorSink). Com durability.Resume, acrescenta ao arquivo em vez de
+// sobrescrevê-lo.
+func (s ErrorHandlerSink) ConsumeErrorsDurable(ctx context.Context, errorCh <-chan DataRecord, durability Durability) {
+	writer, err := newRecordWriter("ErrorHandler", s.Path, s.Rotation, durability.Resume)
+	if err != nil {
+		log.Fatalf("ErrorHandler: Falha ao criar arquivo de erros: %v", err)
+	}
+	s.consume(ctx, errorCh, writer, newDurableCommitter("ErrorHandler", writer, durability))
+}
+
+// ConsumeErrorsTransactional grava os registros em segmentos temporários publicados a cada commit
+// do checkpoint (ver TransactionalErrorSink).
+func (s ErrorHandlerSink) ConsumeErrorsTransactional(ctx context.Context, errorCh <-chan DataRecord, txn Transaction) {
+	writer, err := openTxnWriter("ErrorHandler", s.Path, txn)
 	if err != nil {
 		log.Fatalf("ErrorHandler: Falha ao criar arquivo de erros: %v", err)
 	}
-	defer func() { _ = file.Close() }()
+	s.consume(ctx, errorCh, writer, writer)
+}
+
+// consume grava cada registro de errorCh com writer, informando committer.
+func (s ErrorHandlerSink) consume(ctx context.Context, errorCh <-chan DataRecord, writer recordWriter, committer sinkCommitter) {
+	path := s.Path
+	infof("ErrorHandler: Iniciando tratamento de erros...")
+	defer func() {
+		// Garante que tudo o que foi escrito chegue ao disco, mesmo em caso de cancelamento
+		err := writer.Close()
+		if err != nil {
+			errorf("ErrorHandler: Erro ao fechar %s: %v", path, err)
+		}
+		committer.closed(err)
+	}()
+	tick, stopTicker := rotationTicker(s.Rotation)
+	defer stopTicker()
 
-	for record := range errorCh {
+	for {
+		var record DataRecord
+		select {
+		case r, ok := <-errorCh:
+			if !ok {
+				infof("ErrorHandler: Tratamento de erros finalizado.")
+				return
+			}
+			record = r
+		case <-tick:
+			if err := writer.Tick(); err != nil {
+				errorf("ErrorHandler: Erro ao rotacionar %s: %v", path, err)
+			}
+			continue
+		case <-committer.ticks():
+			committer.sync()
+			continue
+		}
+		if ctx.Err() != nil {
+			warnf("ErrorHandler: Gravação abortada (%v)", ctx.Err())
+			return
+		}
 		jsonBytes, err := json.Marshal(record)
 		if err != nil {
-			log.Printf("ErrorHandler: Erro ao serializar registro de erro %s: %v", record.ID, err)
+			errorf("ErrorHandler: Erro ao serializar registro de erro %s: %v", record.ID, err)
+			committer.written(record.Seq) // Não seria gravado em uma nova tentativa
 			continue
 		}
-		_, err = file.WriteString(string(jsonBytes) + "\n")
+		err = writer.Write(append(jsonBytes, '\n'))
 		if err != nil {
-			log.Printf("ErrorHandler: Erro ao escrever registro de erro %s no arquivo: %v", record.ID, err)
+			errorf("ErrorHandler: Erro ao escrever registro de erro %s no arquivo: %v", record.ID, err)
 			continue
 		}
-		log.Printf("ErrorHandler: Registro %s falhou permanentemente. Motivo: %s", record.ID, record.Error)
+		committer.written(record.Seq)
+		if record.Attempts > 1 {
+			warnf("ErrorHandler: Registro %s falhou permanentemente após %d tentativas. Motivo: %s", record.ID, record.Attempts, record.Error)
+		} else {
+			warnf("ErrorHandler: Registro %s falhou permanentemente. Motivo: %s", record.ID, record.Error)
+		}
 		time.Sleep(time.Duration(5) * time.Millisecond)
 	}
-	log.Println("ErrorHandler: Tratamento de erros finalizado.")
 }
 
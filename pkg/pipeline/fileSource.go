@@ -0,0 +1,132 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+)
+
+// JSONLSource lê registros de um arquivo JSONL linha a linha e os envia para out.
+// Fecha out ao terminar ou quando ctx é cancelado.
+func JSONLSource(ctx context.Context, path string, out chan<- DataRecord, errCh chan<- DataRecord) {
+	defer close(out)
+	JSONLFileSource{Path: path}.Run(ctx, out, errCh)
+}
+
+// JSONLFileSource é a Source que lê registros do arquivo JSONL em Path.
+// Linhas que não podem ser interpretadas são enviadas para errCh com o número da linha
+// e o texto original. O arquivo é lido em streaming, sem limite de tamanho por linha;
+// arquivos .gz e .zst são descomprimidos durante a leitura.
+type JSONLFileSource struct {
+	Path      string
+	SkipLines int   // Linhas iniciais já processadas por uma execução anterior (ver ResumeAfter)
+	SkipDone  []int // Linhas após SkipLines também já processadas
+}
+
+// Name identifica a etapa nos logs.
+func (s JSONLFileSource) Name() string { return "JSONLSource" }
+
+// Input identifica o arquivo lido nos checkpoints (ver ResumableSource).
+func (s JSONLFileSource) Input() string { return s.Path }
+
+// ResumeAfter retorna uma cópia que ignora as linhas até line, inclusive, e as linhas em done.
+func (s JSONLFileSource) ResumeAfter(line int, done []int) Source {
+	s.SkipLines, s.SkipDone = line, done
+	return s
+}
+
+// Run lê o arquivo até o fim ou até ctx ser cancelado.
+func (s JSONLFileSource) Run(ctx context.Context, out chan<- DataRecord, errCh chan<- DataRecord) {
+	path := s.Path
+	infof("JSONLSource: Iniciando leitura de %s...", path)
+	if s.SkipLines > 0 {
+		infof("JSONLSource: Retomando após a linha %d", s.SkipLines)
+	}
+
+	file, err := openRecordFile(path)
+	if err != nil {
+		log.Fatalf("JSONLSource: Falha ao abrir arquivo de entrada: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	name := filepath.Base(path)
+	skipped := skippedLines(s.SkipLines, s.SkipDone)
+	lines, err := readLines(file, func(lineNumber int, line []byte) bool {
+		if skipped(lineNumber) {
+			return ctx.Err() == nil
+		}
+		return ctx.Err() == nil && emitJSONLLine(ctx, name, lineNumber, line, out, errCh)
+	})
+	if err != nil {
+		errorf("JSONLSource: Erro ao ler %s após a linha %d: %v", path, lines, err)
+		return
+	}
+	if ctx.Err() != nil {
+		infof("JSONLSource: Leitura interrompida na linha %d (%v)", lines, ctx.Err())
+		return
+	}
+	infof("JSONLSource: Leitura de %s finalizada (%d linhas).", path, lines)
+}
+
+// readLines lê r linha a linha, sem limite de tamanho, chamando fn com o número (a partir de 1)
+// e o conteúdo de cada linha. Para quando fn retorna false, retornando o número da última
+// linha entregue.
+func readLines(r io.Reader, fn func(lineNumber int, line []byte) bool) (int, error) {
+	reader := bufio.NewReader(r)
+	lineNumber := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return lineNumber, err
+		}
+		if len(line) > 0 {
+			lineNumber++
+			if !fn(lineNumber, line) {
+				return lineNumber, nil
+			}
+		}
+		if err != nil { // io.EOF
+			return lineNumber, nil
+		}
+	}
+}
+
+// emitJSONLLine interpreta uma linha e a envia para out ou, se inválida, para errCh.
+// Linhas em branco são ignoradas. Retorna false se ctx foi cancelado durante o envio.
+func emitJSONLLine(ctx context.Context, name string, lineNumber int, line []byte, out chan<- DataRecord, errCh chan<- DataRecord) bool {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 {
+		return true
+	}
+
+	var record DataRecord
+	if err := json.Unmarshal(trimmed, &record); err != nil {
+		failed := DataRecord{
+			ID:           fmt.Sprintf("%s:%d", name, lineNumber),
+			Status:       "parse_error",
+			Error:        fmt.Sprintf("Invalid JSON at line %d: %v", lineNumber, err),
+			ErrorCodes:   []string{ErrCodeParseError},
+			SourceLine:   lineNumber,
+			Raw:          string(trimmed),
+			SourceFormat: SourceJSONL,
+		}
+		debugf("JSONLSource: Linha %d inválida: %v", lineNumber, err)
+		return send(ctx, errCh, failed)
+	}
+
+	if record.Status == "" {
+		record.Status = "raw"
+	}
+	record.SourceLine = lineNumber
+	return send(ctx, out, record)
+}
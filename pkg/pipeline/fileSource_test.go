@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONLSource(t *testing.T) {
+	longLocation := strings.Repeat("x", 200*1024) // Maior que o buffer padrão de bufio.Scanner
+	content := `{"id":"a-1","timestamp":"2025-01-15T10:30:00Z","sensor_id":"sensor-1","value":10,"unit":"unit_A","location":"North"}
+   
+{"id":"a-2", broken json
+{"id":"a-3","timestamp":"2025-01-15T10:30:10Z","sensor_id":"sensor-2","value":20,"unit":"unit_A","location":"` + longLocation + `"}
+{"id":"a-4","timestamp":"2025-01-15T10:30:15Z","sensor_id":"sensor-3","value":30,"unit":"unit_A","location":"East","status":"raw"}`
+	path := filepath.Join(t.TempDir(), "input.jsonl")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write input file: %v", err)
+	}
+
+	dataCh := make(chan DataRecord, 10)
+	errorCh := make(chan DataRecord, 10)
+	JSONLSource(context.Background(), path, dataCh, errorCh)
+	close(errorCh)
+
+	var records []DataRecord
+	for record := range dataCh {
+		records = append(records, record)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+	if records[0].ID != "a-1" || records[0].Status != "raw" || records[0].SourceLine != 1 {
+		t.Errorf("Unexpected first record: %+v", records[0])
+	}
+	if len(records[1].Location) != len(longLocation) {
+		t.Errorf("Long line was not read entirely, got location of %d bytes", len(records[1].Location))
+	}
+	if records[2].ID != "a-4" || records[2].SourceLine != 5 {
+		t.Errorf("Last line without newline should be read, got %+v", records[2])
+	}
+
+	var failed []DataRecord
+	for record := range errorCh {
+		failed = append(failed, record)
+	}
+	if len(failed) != 1 {
+		t.Fatalf("Expected 1 parse error, got %d", len(failed))
+	}
+	if failed[0].SourceLine != 3 || failed[0].Status != "parse_error" {
+		t.Errorf("Expected parse error at line 3, got %+v", failed[0])
+	}
+	if failed[0].Raw != `{"id":"a-2", broken json` {
+		t.Errorf("Expected raw text to be preserved, got %q", failed[0].Raw)
+	}
+	if !strings.Contains(failed[0].Error, "line 3") {
+		t.Errorf("Expected error to mention the line number, got %q", failed[0].Error)
+	}
+}
+
+func TestRunPipelineWithJSONLSource(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Source = SourceConfig{Type: SourceJSONL, Path: "../../data/sample_input.jsonl"}
+	cfg.Output.ProcessedFile = filepath.Join(dir, "processed.jsonl")
+	cfg.Output.FailedFile = filepath.Join(dir, "failed.jsonl")
+
+	metrics, reason := RunPipeline(context.Background(), cfg)
+
+	if reason != StopCompleted {
+		t.Errorf("Expected stop reason %q, got %q", StopCompleted, reason)
+	}
+	if metrics.ProcessedCount+metrics.ErrorCount != 10 {
+		t.Errorf("Expected all 10 sample records to be accounted for, got %d processed and %d errors",
+			metrics.ProcessedCount, metrics.ErrorCount)
+	}
+}
@@ -0,0 +1,242 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Estados de execução reportados por Health.
+const (
+	StateStarting = "starting" // Pipeline construída, Run ainda não chamado
+	StateRunning  = "running"
+	StateDraining = "draining" // Cancelamento recebido, drenando registros em trânsito
+	StateStopped  = "stopped"
+)
+
+// HealthChecker pode ser implementado por Sinks e ErrorSinks para reportar se
+// conseguem gravar. O resultado é consultado a cada requisição de /readyz.
+type HealthChecker interface {
+	CheckHealth() error
+}
+
+// Health acompanha as goroutines de cada etapa e responde às sondas de liveness e readiness.
+// Uma etapa está travada quando tem registros pendentes na entrada e nenhum progresso
+// há mais de stallTimeout, inclusive quando todos os seus workers terminaram com a pipeline
+// ainda em execução.
+type Health struct {
+	mu           sync.Mutex
+	state        string
+	stages       map[string]*stageHealth
+	order        []string
+	checks       map[string]HealthChecker
+	stallTimeout time.Duration
+	now          func() time.Time
+}
+
+// stageHealth é o estado de uma etapa.
+type stageHealth struct {
+	workers      int
+	lastActivity time.Time
+	pending      func() int
+}
+
+// StageStatus descreve uma etapa em HealthStatus.
+type StageStatus struct {
+	Name         string    `json:"name"`
+	Workers      int       `json:"workers"`
+	Pending      int       `json:"pending"`
+	LastActivity time.Time `json:"last_activity"`
+	Stalled      bool      `json:"stalled"`
+}
+
+// HealthStatus é o corpo JSON de /healthz e /readyz.
+type HealthStatus struct {
+	Status string            `json:"status"` // "ok" ou "fail"
+	State  string            `json:"state"`
+	Stages []StageStatus     `json:"stages"`
+	Sinks  map[string]string `json:"sinks,omitempty"` // "ok" ou a mensagem de erro
+}
+
+// NewHealth cria um Health no estado StateStarting. stallTimeout 0 desativa a detecção de travamento.
+func NewHealth(stallTimeout time.Duration) *Health {
+	return &Health{
+		state:        StateStarting,
+		stages:       make(map[string]*stageHealth),
+		checks:       make(map[string]HealthChecker),
+		stallTimeout: stallTimeout,
+		now:          time.Now,
+	}
+}
+
+// setState altera o estado de execução.
+func (h *Health) setState(state string) {
+	h.mu.Lock()
+	h.state = state
+	h.mu.Unlock()
+}
+
+// registerStage passa a acompanhar uma etapa; pending informa os registros aguardando
+// em sua entrada (nil quando a etapa não tem canal de entrada).
+func (h *Health) registerStage(name string, pending func() int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.stages[name]; !ok {
+		h.order = append(h.order, name)
+	}
+	h.stages[name] = &stageHealth{lastActivity: h.now(), pending: pending}
+}
+
+// registerCheck passa a consultar checker em /readyz.
+func (h *Health) registerCheck(name string, checker HealthChecker) {
+	h.mu.Lock()
+	h.checks[name] = checker
+	h.mu.Unlock()
+}
+
+// workerStarted e workerStopped contam as goroutines vivas de uma etapa.
+func (h *Health) workerStarted(name string) { h.addWorkers(name, 1) }
+
+func (h *Health) workerStopped(name string) { h.addWorkers(name, -1) }
+
+func (h *Health) addWorkers(name string, delta int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if st, ok := h.stages[name]; ok {
+		st.workers += delta
+		st.lastActivity = h.now()
+	}
+}
+
+// touch registra progresso de uma etapa.
+func (h *Health) touch(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if st, ok := h.stages[name]; ok {
+		st.lastActivity = h.now()
+	}
+}
+
+// Liveness reporta se nenhuma etapa está travada. Drenagem não afeta a liveness.
+func (h *Health) Liveness() (HealthStatus, bool) {
+	status := h.snapshot(false)
+	ok := true
+	for _, stage := range status.Stages {
+		ok = ok && !stage.Stalled
+	}
+	return status.withResult(ok), ok
+}
+
+// Readiness reporta se a pipeline está em execução, sem etapas travadas, e se todos os
+// sinks conseguem gravar. Durante a drenagem a pipeline deixa de estar pronta.
+func (h *Health) Readiness() (HealthStatus, bool) {
+	status := h.snapshot(true)
+	ok := status.State == StateRunning
+	for _, stage := range status.Stages {
+		ok = ok && !stage.Stalled
+	}
+	for _, result := range status.Sinks {
+		ok = ok && result == "ok"
+	}
+	return status.withResult(ok), ok
+}
+
+func (s HealthStatus) withResult(ok bool) HealthStatus {
+	s.Status = "fail"
+	if ok {
+		s.Status = "ok"
+	}
+	return s
+}
+
+// snapshot copia o estado atual; withChecks executa os HealthCheckers dos sinks.
+func (h *Health) snapshot(withChecks bool) HealthStatus {
+	h.mu.Lock()
+	status := HealthStatus{State: h.state, Stages: make([]StageStatus, 0, len(h.order))}
+	now := h.now()
+	for _, name := range h.order {
+		st := h.stages[name]
+		stage := StageStatus{Name: name, Workers: st.workers, LastActivity: st.lastActivity}
+		if st.pending != nil {
+			stage.Pending = st.pending()
+		}
+		// Em execução, uma etapa sem workers e com registros pendentes terminou antes de
+		// consumir sua entrada e também trava a pipeline
+		stage.Stalled = h.stallTimeout > 0 && (st.workers > 0 || h.state == StateRunning) && stage.Pending > 0 &&
+			now.Sub(st.lastActivity) > h.stallTimeout
+		status.Stages = append(status.Stages, stage)
+	}
+	checks := make(map[string]HealthChecker, len(h.checks))
+	for name, checker := range h.checks {
+		checks[name] = checker
+	}
+	h.mu.Unlock()
+
+	// Os checks podem tocar o disco; rodam fora do lock
+	if withChecks && len(checks) > 0 {
+		status.Sinks = make(map[string]string, len(checks))
+		for name, checker := range checks {
+			if err := checker.CheckHealth(); err != nil {
+				status.Sinks[name] = err.Error()
+			} else {
+				status.Sinks[name] = "ok"
+			}
+		}
+	}
+	return status
+}
+
+// probeHandler responde com o resultado de probe em JSON: 200 se saudável, 503 caso contrário.
+func probeHandler(probe func() (HealthStatus, bool)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		status, ok := probe()
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	})
+}
+
+// StartHealthServer expõe /healthz (liveness) e /readyz (readiness) para health. O caminho
+// de endpoint (ex.: ":8080/health", como em monitoring.health_check_address) também
+// responde à liveness.
+func StartHealthServer(endpoint string, health *Health) (*http.Server, error) {
+	addr, path := splitEndpoint(endpoint, "/healthz")
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", probeHandler(health.Liveness))
+	mux.Handle("/readyz", probeHandler(health.Readiness))
+	if path != "/healthz" && path != "/readyz" && path != "/" {
+		mux.Handle(path, probeHandler(health.Liveness))
+	}
+	return startServer("Health", addr, mux)
+}
+
+// checkWritable verifica se path pode receber escrita: o arquivo, se já existir, ou
+// o diretório onde será criado.
+func checkWritable(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err == nil {
+		return f.Close()
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s não é um diretório", filepath.Dir(path))
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// blockingSink só começa a consumir quando release é fechado, simulando um Loader travado.
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s blockingSink) Name() string { return "BlockingSink" }
+
+func (s blockingSink) Consume(_ context.Context, in <-chan ProcessedRecord) {
+	<-s.release
+	for range in {
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHealthLifecycle(t *testing.T) {
+	sink := &memorySink{}
+	p, err := NewBuilder(DefaultConfig()).
+		WithSource(sliceSource{records: []DataRecord{{ID: "h-1", Location: "north"}}}).
+		AddProcessor(upperLocation{}).
+		AddSink(sink).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+
+	if status, ok := p.Health().Readiness(); ok || status.State != StateStarting {
+		t.Errorf("Expected not ready before Run, got %+v", status)
+	}
+	p.Run(context.Background())
+
+	status, ok := p.Health().Liveness()
+	if !ok || status.State != StateStopped {
+		t.Errorf("Expected live and stopped after Run, got %+v", status)
+	}
+	if _, ok := p.Health().Readiness(); ok {
+		t.Error("Expected not ready after Run")
+	}
+	if len(status.Stages) != 3 {
+		t.Fatalf("Expected source, processor and sink stages, got %+v", status.Stages)
+	}
+	for _, stage := range status.Stages {
+		if stage.Workers != 0 {
+			t.Errorf("Expected all workers of %s to have stopped, got %d", stage.Name, stage.Workers)
+		}
+	}
+}
+
+func TestHealthDetectsStalledSinkAndDraining(t *testing.T) {
+	records := make([]DataRecord, 50)
+	for i := range records {
+		records[i] = DataRecord{ID: "s", Location: "north"}
+	}
+	cfg := DefaultConfig()
+	cfg.Pipeline.ChannelBufferSize = 2
+	sink := blockingSink{release: make(chan struct{})}
+	p, err := NewBuilder(cfg).
+		WithSource(sliceSource{records: records}).
+		AddProcessor(upperLocation{}).
+		AddSink(sink).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	health := p.Health()
+	health.stallTimeout = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(ctx)
+	}()
+
+	waitFor(t, "stalled sink", func() bool {
+		_, ok := health.Liveness()
+		return !ok
+	})
+	status, _ := health.Liveness()
+	var stalledSink bool
+	for _, stage := range status.Stages {
+		if stage.Name == "BlockingSink" {
+			stalledSink = stage.Stalled && stage.Workers == 1 && stage.Pending > 0
+		}
+	}
+	if !stalledSink {
+		t.Errorf("Expected BlockingSink to be reported as stalled, got %+v", status.Stages)
+	}
+	if status, ok := health.Readiness(); ok || status.State != StateRunning {
+		t.Errorf("Expected a stalled pipeline not to be ready, got %+v", status)
+	}
+
+	cancel()
+	waitFor(t, "draining state", func() bool {
+		status, _ := health.Readiness()
+		return status.State == StateDraining
+	})
+	close(sink.release)
+	<-done
+	if status, ok := health.Liveness(); !ok || status.State != StateStopped {
+		t.Errorf("Expected live and stopped after drain, got %+v", status)
+	}
+}
+
+func TestHealthDetectsStageWithoutWorkers(t *testing.T) {
+	clock := time.Now()
+	health := NewHealth(time.Second)
+	health.now = func() time.Time { return clock }
+	health.registerStage("Loader", func() int { return 3 })
+	health.setState(StateRunning)
+	health.workerStarted("Loader")
+	health.workerStopped("Loader") // O Sink retornou com registros ainda na entrada
+
+	if _, ok := health.Liveness(); !ok {
+		t.Error("Expected the stage to be live within the stall timeout")
+	}
+	clock = clock.Add(2 * time.Second)
+	status, ok := health.Liveness()
+	if ok || !status.Stages[0].Stalled || status.Stages[0].Workers != 0 {
+		t.Errorf("Expected a running stage without workers and with pending records to fail liveness, got %+v", status)
+	}
+
+	health.setState(StateDraining)
+	if _, ok := health.Liveness(); !ok {
+		t.Error("Expected stopped workers not to fail liveness while draining")
+	}
+}
+
+func TestHealthServer(t *testing.T) {
+	dir := t.TempDir()
+	p, err := NewBuilder(DefaultConfig()).
+		WithSource(sliceSource{}).
+		AddSink(LoaderSink{Path: filepath.Join(dir, "processed.jsonl")}).
+		AddErrorSink(ErrorHandlerSink{Path: filepath.Join(dir, "missing", "failed.jsonl")}).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	p.Health().setState(StateRunning)
+
+	srv, err := StartHealthServer("127.0.0.1:0/health", p.Health())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer srv.Close()
+
+	get := func(path string) (int, HealthStatus) {
+		resp, err := http.Get("http://" + srv.Addr + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var status HealthStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatalf("GET %s returned invalid JSON: %v", path, err)
+		}
+		return resp.StatusCode, status
+	}
+
+	for _, path := range []string{"/healthz", "/health"} {
+		if code, status := get(path); code != http.StatusOK || status.Status != "ok" {
+			t.Errorf("Expected %s to be healthy, got %d %+v", path, code, status)
+		}
+	}
+	code, status := get("/readyz")
+	if code != http.StatusServiceUnavailable || status.Status != "fail" {
+		t.Errorf("Expected /readyz to fail with an unwritable sink, got %d %+v", code, status)
+	}
+	if status.Sinks["Loader"] != "ok" || status.Sinks["ErrorHandler"] == "ok" {
+		t.Errorf("Expected only ErrorHandler to be unwritable, got %+v", status.Sinks)
+	}
+}
@@ -5,36 +5,141 @@
 package pipeline
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"math/rand"
-	"os"
 	"time"
 )
 
-// Loader carrega os registros processados para um destino (simulado).
-func Loader(in <-chan ProcessedRecord) {
-	log.Println("Loader: Iniciando carregamento de dados...")
-	file, err := os.Create("processed_data.jsonl")
+// Loader carrega os registros processados para o arquivo JSONL em path.
+func Loader(ctx context.Context, in <-chan ProcessedRecord, path string) {
+	LoaderSink{Path: path}.Consume(ctx, in)
+}
+
+// LoaderSink é o Sink padrão, que grava os registros em JSONL no arquivo em Path.
+type LoaderSink struct {
+	Path     string
+	Rotation RotationConfig // Sem critérios de rotação, cada execução sobrescreve Path
+}
+
+// Name identifica a etapa nos logs.
+func (s LoaderSink) Name() string { return "Loader" }
+
+// CheckHealth reporta se Path pode receber escrita (ver HealthChecker).
+func (s LoaderSink) CheckHealth() error { return checkWritable(s.Path) }
+
+// Consume grava cada registro de in até o canal ser fechado ou ctx ser cancelado.
+func (s LoaderSink) Consume(ctx context.Context, in <-chan ProcessedRecord) {
+	s.ConsumeDurable(ctx, in, Durability{})
+}
+
+// ConsumeDurable grava os registros como Consume e, com durability.Commit, sincroniza o
+// arquivo a cada durability.Interval e ao terminar, confirmando os registros gravados desde o
+// fsync anterior (ver DurableSink). Com durability.Resume, acrescenta ao arquivo em vez de
+// sobrescrevê-lo.
+func (s LoaderSink) ConsumeDurable(ctx context.Context, in <-chan ProcessedRecord, durability Durability) {
+	writer, err := newRecordWriter("Loader", s.Path, s.Rotation, durability.Resume)
 	if err != nil {
 		log.Fatalf("Loader: Falha ao criar arquivo de saída: %v", err)
 	}
-	defer func() { _ = file.Close() }()
+	s.consume(ctx, in, writer, newDurableCommitter("Loader", writer, durability))
+}
+
+// ConsumeTransactional grava os registros em segmentos temporários publicados a cada commit
+// do checkpoint (ver TransactionalSink).
+func (s LoaderSink) ConsumeTransactional(ctx context.Context, in <-chan ProcessedRecord, txn Transaction) {
+	writer, err := openTxnWriter("Loader", s.Path, txn)
+	if err != nil {
+		log.Fatalf("Loader: Falha ao criar arquivo de saída: %v", err)
+	}
+	s.consume(ctx, in, writer, writer)
+}
+
+// consume grava cada registro de in com writer, informando committer.
+func (s LoaderSink) consume(ctx context.Context, in <-chan ProcessedRecord, writer recordWriter, committer sinkCommitter) {
+	path := s.Path
+	infof("Loader: Iniciando carregamento de dados...")
+	defer func() {
+		// Garante que tudo o que foi escrito chegue ao disco, mesmo em caso de cancelamento
+		err := writer.Close()
+		if err != nil {
+			errorf("Loader: Erro ao fechar %s: %v", path, err)
+		}
+		committer.closed(err)
+	}()
+	tick, stopTicker := rotationTicker(s.Rotation)
+	defer stopTicker()
 
-	for record := range in {
+	for {
+		var record ProcessedRecord
+		select {
+		case r, ok := <-in:
+			if !ok {
+				infof("Loader: Carregamento de dados finalizado.")
+				return
+			}
+			record = r
+		case <-tick:
+			if err := writer.Tick(); err != nil {
+				errorf("Loader: Erro ao rotacionar %s: %v", path, err)
+			}
+			continue
+		case <-committer.ticks():
+			committer.sync()
+			continue
+		}
+		if ctx.Err() != nil {
+			warnf("Loader: Gravação abortada (%v)", ctx.Err())
+			return
+		}
 		jsonBytes, err := json.Marshal(record)
 		if err != nil {
-			log.Printf("Loader: Erro ao serializar registro %s: %v", record.ID, err)
+			errorf("Loader: Erro ao serializar registro %s: %v", record.ID, err)
+			committer.written(record.Seq) // Não seria gravado em uma nova tentativa
 			continue
 		}
-		_, err = file.WriteString(string(jsonBytes) + "\n")
+		err = writer.Write(append(jsonBytes, '\n'))
 		if err != nil {
-			log.Printf("Loader: Erro ao escrever registro %s no arquivo: %v", record.ID, err)
+			errorf("Loader: Erro ao escrever registro %s no arquivo: %v", record.ID, err)
 			continue
 		}
-		log.Printf("Loader: Carregado %s (Anomaly: %t)", record.ID, record.IsAnomaly)
+		committer.written(record.Seq)
+		debugf("Loader: Carregado %s (Anomaly: %t)", record.ID, record.IsAnomaly)
 		time.Sleep(time.Duration(rand.Intn(10)) * time.Millisecond)
 	}
-	log.Println("Loader: Carregamento de dados finalizado.")
 }
 
+// writeJSONL grava cada item de in como uma linha JSON no arquivo em path, sobrescrito a cada
+// execução, até o canal ser fechado ou ctx ser cancelado, e retorna quantos itens gravou. É o
+// writer das saídas auxiliares (agregados, sessões, registros atrasados), que não rotacionam
+// nem participam do checkpoint.
+func writeJSONL[T any](ctx context.Context, name, path string, in <-chan T) int {
+	writer, err := newRecordWriter(name, path, RotationConfig{}, false)
+	if err != nil {
+		log.Fatalf("%s: Falha ao criar %s: %v", name, path, err)
+	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			errorf("%s: Erro ao fechar %s: %v", name, path, err)
+		}
+	}()
+	written := 0
+	for item := range in {
+		if ctx.Err() != nil {
+			warnf("%s: Gravação abortada (%v)", name, ctx.Err())
+			return written
+		}
+		line, err := json.Marshal(item)
+		if err != nil {
+			errorf("%s: Erro ao serializar item %d: %v", name, written+1, err)
+			continue
+		}
+		if err := writer.Write(append(line, '\n')); err != nil {
+			errorf("%s: Erro ao escrever em %s: %v", name, path, err)
+			continue
+		}
+		written++
+	}
+	return written
+}
@@ -0,0 +1,57 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+)
+
+// Níveis aceitos em output.log_level, do mais ao menos detalhado. Cada nível registra também
+// as mensagens dos níveis seguintes.
+const (
+	LogDebug = "DEBUG" // Cada registro produzido, validado, transformado e gravado
+	LogInfo  = "INFO"  // Início, fim e resumo de cada etapa
+	LogWarn  = "WARN"  // Registros que falharam, foram descartados ou ignorados e etapas abortadas
+	LogError = "ERROR" // Falhas de leitura, escrita e sincronização
+)
+
+// validLogLevels lista os níveis aceitos em output.log_level.
+var validLogLevels = []string{LogDebug, LogInfo, LogWarn, LogError}
+
+// logThreshold é a posição em validLogLevels do nível mínimo registrado, menos 1: o valor
+// zero corresponde a LogInfo, o padrão de quem não chama SetLogLevel.
+var logThreshold atomic.Int32
+
+// SetLogLevel define o nível mínimo das mensagens registradas pela pipeline (um de
+// validLogLevels, sem distinção de maiúsculas).
+func SetLogLevel(level string) error {
+	for i, name := range validLogLevels {
+		if strings.EqualFold(level, name) {
+			logThreshold.Store(int32(i - 1))
+			return nil
+		}
+	}
+	return fmt.Errorf("nível de log desconhecido %q (aceitos: %s)", level, strings.Join(validLogLevels, ", "))
+}
+
+// logf registra a mensagem se level (posição em validLogLevels menos 1) atingir o nível
+// mínimo, atribuindo-a no log ao chamador de debugf, infof, warnf ou errorf.
+func logf(level int32, format string, args ...any) {
+	if level < logThreshold.Load() {
+		return
+	}
+	_ = log.Output(3, fmt.Sprintf(format, args...))
+}
+
+func debugf(format string, args ...any) { logf(-1, format, args...) }
+
+func infof(format string, args ...any) { logf(0, format, args...) }
+
+func warnf(format string, args ...any) { logf(1, format, args...) }
+
+func errorf(format string, args ...any) { logf(2, format, args...) }
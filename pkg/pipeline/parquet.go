@@ -0,0 +1,211 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// CompressionSnappy é aceita apenas em output.parquet.compression, além das compressões de rotação.
+const CompressionSnappy = "snappy"
+
+// parquetCodecs mapeia output.parquet.compression para o codec das páginas.
+var parquetCodecs = map[string]parquet.CompressionCodec{
+	CompressionNone:   parquet.CompressionCodec_UNCOMPRESSED,
+	CompressionSnappy: parquet.CompressionCodec_SNAPPY,
+	CompressionGzip:   parquet.CompressionCodec_GZIP,
+	CompressionZstd:   parquet.CompressionCodec_ZSTD,
+}
+
+// ParquetConfig controla o arquivo Parquet gravado por ParquetSink.
+type ParquetConfig struct {
+	Path            string `yaml:"path"`              // Arquivo gravado junto com processed_file; vazio desativa
+	Compression     string `yaml:"compression"`       // snappy, zstd, gzip ou none
+	RowGroupRecords int    `yaml:"row_group_records"` // Fecha o row group após N registros; 0 para limitar só por tamanho
+	RowGroupBytes   int64  `yaml:"row_group_bytes"`   // Tamanho aproximado de um row group antes da compressão
+}
+
+// parquetRecord é a linha gravada por ParquetSink: os campos de ProcessedRecord, com os mesmos
+// nomes das chaves JSON. Datas são TIMESTAMP em microssegundos UTC; colunas de baixa
+// cardinalidade usam dicionário; slices são texto JSON, vazio quando não há itens.
+type parquetRecord struct {
+	ID              string  `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Timestamp       int64   `parquet:"name=timestamp, type=INT64, convertedtype=TIMESTAMP_MICROS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MICROS"`
+	SensorID        string  `parquet:"name=sensor_id, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value           float64 `parquet:"name=value, type=DOUBLE"`
+	Unit            string  `parquet:"name=unit, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Location        string  `parquet:"name=location, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Status          string  `parquet:"name=status, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Error           string  `parquet:"name=error, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ErrorCodes      string  `parquet:"name=error_codes, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SourceLine      int64   `parquet:"name=source_line, type=INT64"`
+	Raw             string  `parquet:"name=raw, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SourceFormat    string  `parquet:"name=source_format, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+	Attempts        int32   `parquet:"name=attempts, type=INT32"`
+	ErrorHistory    string  `parquet:"name=error_history, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ReplayLine      int64   `parquet:"name=replay_line, type=INT64"`
+	ProcessedAt     int64   `parquet:"name=processed_at, type=INT64, convertedtype=TIMESTAMP_MICROS, logicaltype=TIMESTAMP, logicaltype.isadjustedtoutc=true, logicaltype.unit=MICROS"`
+	AnomalyScore    float64 `parquet:"name=anomaly_score, type=DOUBLE"`
+	IsAnomaly       bool    `parquet:"name=is_anomaly, type=BOOLEAN"`
+	AnomalyDetector string  `parquet:"name=anomaly_detector, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"`
+}
+
+func newParquetRecord(r ProcessedRecord) parquetRecord {
+	return parquetRecord{
+		ID:              r.ID,
+		Timestamp:       r.Timestamp.UnixMicro(),
+		SensorID:        r.SensorID,
+		Value:           r.Value,
+		Unit:            r.Unit,
+		Location:        r.Location,
+		Status:          r.Status,
+		Error:           r.Error,
+		ErrorCodes:      parquetJSON(r.ErrorCodes),
+		SourceLine:      int64(r.SourceLine),
+		Raw:             r.Raw,
+		SourceFormat:    r.SourceFormat,
+		Attempts:        int32(r.Attempts),
+		ErrorHistory:    parquetJSON(r.ErrorHistory),
+		ReplayLine:      int64(r.ReplayLine),
+		ProcessedAt:     r.ProcessedAt.UnixMicro(),
+		AnomalyScore:    r.AnomalyScore,
+		IsAnomaly:       r.IsAnomaly,
+		AnomalyDetector: r.AnomalyDetector,
+	}
+}
+
+// parquetJSON serializa items para uma coluna de texto JSON; sem itens, a coluna fica vazia.
+func parquetJSON[T any](items []T) string {
+	if len(items) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(items)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// ParquetSink é o Sink que grava os registros processados em um arquivo Parquet em Path.
+// O arquivo é escrito em <Path>.inprogress e só é renomeado para Path depois que o rodapé
+// foi gravado e sincronizado, de modo que Path nunca contém um arquivo Parquet incompleto.
+// Cada execução sobrescreve Path.
+type ParquetSink struct {
+	Path    string
+	Parquet ParquetConfig
+}
+
+// Name identifica a etapa nos logs.
+func (s ParquetSink) Name() string { return "ParquetLoader" }
+
+// CheckHealth reporta se Path pode receber escrita (ver HealthChecker).
+func (s ParquetSink) CheckHealth() error { return checkWritable(s.Path) }
+
+// Consume grava cada registro de in até o canal ser fechado ou ctx ser cancelado.
+// Em ambos os casos o arquivo é finalizado com os registros já recebidos.
+func (s ParquetSink) Consume(ctx context.Context, in <-chan ProcessedRecord) {
+	infof("ParquetLoader: Iniciando carregamento em %s...", s.Path)
+	w, err := newParquetFileWriter(s.Path, s.Parquet)
+	if err != nil {
+		log.Fatalf("ParquetLoader: Falha ao criar arquivo de saída: %v", err)
+	}
+	defer func() {
+		if err := w.Close(); err != nil {
+			errorf("ParquetLoader: Erro ao finalizar %s: %v", s.Path, err)
+		}
+	}()
+
+	for record := range in {
+		if ctx.Err() != nil {
+			warnf("ParquetLoader: Gravação abortada (%v)", ctx.Err())
+			return
+		}
+		if err := w.Write(record); err != nil {
+			errorf("ParquetLoader: Erro ao escrever registro %s: %v", record.ID, err)
+			continue
+		}
+		debugf("ParquetLoader: Carregado %s (Anomaly: %t)", record.ID, record.IsAnomaly)
+	}
+	infof("ParquetLoader: Carregamento de dados finalizado.")
+}
+
+// parquetFileWriter grava um arquivo Parquet e fecha row groups por número de registros.
+type parquetFileWriter struct {
+	path    string
+	tmp     string
+	cfg     ParquetConfig
+	file    *os.File
+	pw      *writer.ParquetWriter
+	records int // Registros no row group atual
+	total   int
+}
+
+func newParquetFileWriter(path string, cfg ParquetConfig) (*parquetFileWriter, error) {
+	compression := cfg.Compression
+	if compression == "" {
+		compression = CompressionSnappy
+	}
+	codec, ok := parquetCodecs[compression]
+	if !ok {
+		return nil, fmt.Errorf("compressão desconhecida %q", compression)
+	}
+	tmp := path + ".inprogress"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+	pw, err := writer.NewParquetWriterFromWriter(file, new(parquetRecord), 1)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	pw.CompressionType = codec
+	if cfg.RowGroupBytes > 0 {
+		pw.RowGroupSize = cfg.RowGroupBytes
+	}
+	return &parquetFileWriter{path: path, tmp: tmp, cfg: cfg, file: file, pw: pw}, nil
+}
+
+func (w *parquetFileWriter) Write(record ProcessedRecord) error {
+	if err := w.pw.Write(newParquetRecord(record)); err != nil {
+		return err
+	}
+	w.records++
+	w.total++
+	if w.cfg.RowGroupRecords > 0 && w.records >= w.cfg.RowGroupRecords {
+		w.records = 0
+		return w.pw.Flush(true)
+	}
+	return nil
+}
+
+// Close grava o último row group e o rodapé, sincroniza o arquivo e o renomeia para path.
+// Em caso de erro, o arquivo .inprogress é mantido para diagnóstico.
+func (w *parquetFileWriter) Close() error {
+	err := w.pw.WriteStop()
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(w.tmp, w.path); err != nil {
+		return err
+	}
+	infof("ParquetLoader: %s finalizado (%d registros em %d row groups)",
+		w.path, w.total, len(w.pw.Footer.RowGroups))
+	return nil
+}
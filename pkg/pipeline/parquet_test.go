@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func readParquet(t *testing.T, path string) ([]parquetRecord, *parquet.FileMetaData) {
+	t.Helper()
+	file, err := local.NewLocalFileReader(path)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	pr, err := reader.NewParquetReader(file, new(parquetRecord), 1)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	defer pr.ReadStop()
+	rows := make([]parquetRecord, pr.GetNumRows())
+	if err := pr.Read(&rows); err != nil {
+		t.Fatalf("Failed to read rows of %s: %v", path, err)
+	}
+	return rows, pr.Footer
+}
+
+func TestParquetSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "processed.parquet")
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 123456000, time.UTC)
+	in := make(chan ProcessedRecord, 5)
+	for i, id := range []string{"r1", "r2", "r3", "r4", "r5"} {
+		record := ProcessedRecord{
+			DataRecord:   DataRecord{ID: id, Timestamp: ts.Add(time.Duration(i) * time.Second), SensorID: "sensor-1", Value: float64(i) + 0.5, Unit: "unit_B", Location: "North", Status: "processed", Attempts: i},
+			ProcessedAt:  ts.Add(time.Minute),
+			AnomalyScore: float64(i),
+			IsAnomaly:    i == 4,
+		}
+		record.ErrorCodes = []string{ErrCodeInvalidUnit}
+		record.ErrorHistory = []AttemptError{{Attempt: 1, Error: "busy"}}
+		in <- record
+	}
+	close(in)
+	ParquetSink{Path: path, Parquet: ParquetConfig{Compression: CompressionZstd, RowGroupRecords: 2, RowGroupBytes: 1 << 20}}.
+		Consume(context.Background(), in)
+
+	if _, err := os.Stat(path + ".inprogress"); !os.IsNotExist(err) {
+		t.Errorf("Expected the in-progress file to be renamed, stat error: %v", err)
+	}
+	rows, footer := readParquet(t, path)
+	if len(rows) != 5 || len(footer.RowGroups) != 3 {
+		t.Fatalf("Expected 5 rows in 3 row groups, got %d rows in %d", len(rows), len(footer.RowGroups))
+	}
+	if codec := footer.RowGroups[0].Columns[0].MetaData.Codec; codec != parquet.CompressionCodec_ZSTD {
+		t.Errorf("Expected zstd compression, got %s", codec)
+	}
+	last := rows[4]
+	if last.ID != "r5" || last.Value != 4.5 || last.Attempts != 4 || !last.IsAnomaly || last.Location != "North" ||
+		!time.UnixMicro(last.Timestamp).Equal(ts.Add(4*time.Second)) || !time.UnixMicro(last.ProcessedAt).Equal(ts.Add(time.Minute)) {
+		t.Errorf("Unexpected last row: %+v", last)
+	}
+	if last.ErrorCodes != `["`+ErrCodeInvalidUnit+`"]` || !strings.Contains(last.ErrorHistory, `"busy"`) {
+		t.Errorf("Expected error_codes and error_history as JSON, got %q and %q", last.ErrorCodes, last.ErrorHistory)
+	}
+}
+
+func TestParquetSinkFinalizesOnAbort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "processed.parquet")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	in := make(chan ProcessedRecord, 1)
+	in <- ProcessedRecord{DataRecord: DataRecord{ID: "late"}}
+	close(in)
+	ParquetSink{Path: path, Parquet: ParquetConfig{RowGroupBytes: 1 << 20}}.Consume(ctx, in)
+
+	if rows, _ := readParquet(t, path); len(rows) != 0 {
+		t.Errorf("Expected an empty but valid file after abort, got %+v", rows)
+	}
+}
+
+// TestParquetSchemaCoversProcessedRecord garante que novos campos de ProcessedRecord, inclusive
+// slices, também sejam acrescentados a parquetRecord.
+func TestParquetSchemaCoversProcessedRecord(t *testing.T) {
+	columns := map[string]bool{}
+	pt := reflect.TypeOf(parquetRecord{})
+	for i := 0; i < pt.NumField(); i++ {
+		tag := pt.Field(i).Tag.Get("parquet")
+		columns[strings.TrimPrefix(strings.Split(tag, ",")[0], "name=")] = true
+	}
+	for _, column := range recordColumns(reflect.TypeOf(ProcessedRecord{})) {
+		if !columns[column.name] {
+			t.Errorf("ProcessedRecord field %s has no Parquet column", column.name)
+		}
+	}
+}
+
+func TestParquetAlongsideLoader(t *testing.T) {
+	for yaml, want := range map[string]string{
+		"output:\n  parquet:\n    compression: lz4\n":                "output.parquet.compression",
+		"output:\n  parquet:\n    row_group_bytes: 0\n":              "output.parquet.row_group_bytes",
+		"output:\n  parquet:\n    path: processed_data.jsonl\n":      "output.parquet.path",
+		"output:\n  format: parquet\n  partition:\n    dir: parts\n": "não suporta",
+		"output:\n  parquet:\n    row_group_records: -1\n":           "output.parquet.row_group_records",
+	} {
+		if _, err := ParseConfig([]byte(yaml)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got %v", want, err)
+		}
+	}
+
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Pipeline.NumRecords = 20
+	cfg.Output.ProcessedFile = filepath.Join(dir, "processed.jsonl")
+	cfg.Output.FailedFile = filepath.Join(dir, "failed.jsonl")
+	cfg.Output.Parquet.Path = filepath.Join(dir, "processed.parquet")
+	p, err := DefaultBuilder(cfg).Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	metrics, _ := p.Run(context.Background())
+
+	rows, _ := readParquet(t, cfg.Output.Parquet.Path)
+	jsonl, err := os.ReadFile(cfg.Output.ProcessedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != metrics.ProcessedCount || strings.Count(string(jsonl), "\n") != metrics.ProcessedCount {
+		t.Errorf("Expected %d records in both outputs, got %d in Parquet and %d in JSONL",
+			metrics.ProcessedCount, len(rows), strings.Count(string(jsonl), "\n"))
+	}
+}
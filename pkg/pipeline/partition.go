@@ -0,0 +1,391 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPartitionTemplate é o layout padrão das partições no estilo Hive.
+const DefaultPartitionTemplate = "date={date}/location={location}/sensor_id={sensor_id}"
+
+// partitionDefaultValue substitui valores vazios, como o Hive faz.
+const partitionDefaultValue = "__HIVE_DEFAULT_PARTITION__"
+
+// partitionFlushInterval é a frequência com que os buffers das partições abertas são gravados.
+const partitionFlushInterval = time.Second
+
+// partitionFields são os campos aceitos no template, extraídos de cada registro.
+// Datas e horas usam o Timestamp do registro em UTC.
+var partitionFields = map[string]func(DataRecord) string{
+	"date":      func(r DataRecord) string { return partitionTime(r, "2006-01-02") },
+	"year":      func(r DataRecord) string { return partitionTime(r, "2006") },
+	"month":     func(r DataRecord) string { return partitionTime(r, "01") },
+	"day":       func(r DataRecord) string { return partitionTime(r, "02") },
+	"hour":      func(r DataRecord) string { return partitionTime(r, "15") },
+	"location":  func(r DataRecord) string { return r.Location },
+	"sensor_id": func(r DataRecord) string { return r.SensorID },
+	"unit":      func(r DataRecord) string { return r.Unit },
+	"status":    func(r DataRecord) string { return r.Status },
+}
+
+func partitionTime(r DataRecord, layout string) string {
+	if r.Timestamp.IsZero() {
+		return ""
+	}
+	return r.Timestamp.UTC().Format(layout)
+}
+
+// PartitionConfig grava os registros processados em diretórios particionados em vez de
+// processed_file, ex.: date=2025-01-15/location=North/sensor_id=sensor-1/part-0001.jsonl.
+type PartitionConfig struct {
+	Dir               string `yaml:"dir"`                  // Raiz das partições; vazio desativa o particionamento
+	Template          string `yaml:"template"`             // Caminho relativo com campos entre chaves, ex.: {date}
+	MaxOpenFiles      int    `yaml:"max_open_files"`       // Arquivos abertos ao mesmo tempo; os menos usados são fechados
+	MaxRecordsPerFile int    `yaml:"max_records_per_file"` // Registros por arquivo part-NNNN; 0 para ilimitado
+}
+
+// Enabled reporta se o particionamento foi configurado.
+func (c PartitionConfig) Enabled() bool { return c.Dir != "" }
+
+// partitionTemplate é um template de partição já interpretado: trechos literais
+// intercalados com campos do registro.
+type partitionTemplate []templatePart
+
+type templatePart struct {
+	literal string
+	field   func(DataRecord) string // nil para trechos literais
+}
+
+// parsePartitionTemplate interpreta um template como DefaultPartitionTemplate.
+func parsePartitionTemplate(template string) (partitionTemplate, error) {
+	if strings.TrimSpace(template) == "" {
+		return nil, errors.New("template vazio")
+	}
+	if filepath.IsAbs(template) || strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("template %q deve ser um caminho relativo", template)
+	}
+	for _, dir := range strings.Split(template, "/") {
+		if dir == "" || dir == "." || dir == ".." {
+			return nil, fmt.Errorf("template %q contém um diretório inválido %q", template, dir)
+		}
+	}
+
+	var parts partitionTemplate
+	var fields int
+	rest := template
+	for rest != "" {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			parts = append(parts, templatePart{literal: rest})
+			break
+		}
+		if strings.IndexByte(rest[:open], '}') >= 0 {
+			return nil, fmt.Errorf("template %q tem '}' sem '{' correspondente", template)
+		}
+		end := strings.IndexByte(rest[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("template %q tem '{' sem '}' correspondente", template)
+		}
+		name := rest[open+1 : open+end]
+		field, ok := partitionFields[name]
+		if !ok {
+			return nil, fmt.Errorf("template %q usa o campo desconhecido {%s}", template, name)
+		}
+		if open > 0 {
+			parts = append(parts, templatePart{literal: rest[:open]})
+		}
+		parts = append(parts, templatePart{field: field})
+		fields++
+		rest = rest[open+end+1:]
+	}
+	if strings.IndexByte(rest, '}') >= 0 {
+		return nil, fmt.Errorf("template %q tem '}' sem '{' correspondente", template)
+	}
+	if fields == 0 {
+		return nil, fmt.Errorf("template %q não usa nenhum campo", template)
+	}
+	return parts, nil
+}
+
+// render retorna o diretório relativo da partição de record.
+func (t partitionTemplate) render(record DataRecord) string {
+	var b strings.Builder
+	for _, part := range t {
+		if part.field == nil {
+			b.WriteString(part.literal)
+			continue
+		}
+		b.WriteString(escapePartitionValue(part.field(record)))
+	}
+	return filepath.FromSlash(b.String())
+}
+
+// escapePartitionValue codifica em %XX os caracteres que mudariam a estrutura de diretórios
+// ou que o Hive não aceita em valores de partição.
+func escapePartitionValue(value string) string {
+	if value == "" {
+		return partitionDefaultValue
+	}
+	if value == "." || value == ".." {
+		return strings.Repeat("%2E", len(value))
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < 0x20 || c == 0x7f || strings.IndexByte(`"#%'*/:=?\{}[]^<>|`, c) >= 0 {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// PartitionedSink é o Sink que grava os registros processados em JSONL, um diretório por
+// partição no estilo Hive (ver PartitionConfig).
+type PartitionedSink struct {
+	Partition PartitionConfig
+}
+
+// Name identifica a etapa nos logs.
+func (s PartitionedSink) Name() string { return "PartitionedLoader" }
+
+// CheckHealth reporta se o diretório raiz das partições pode receber escrita (ver HealthChecker).
+func (s PartitionedSink) CheckHealth() error {
+	info, err := os.Stat(s.Partition.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		// A raiz é criada na primeira escrita; basta que o diretório pai exista
+		return checkWritable(s.Partition.Dir)
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s não é um diretório", s.Partition.Dir)
+	}
+	return nil
+}
+
+// Consume grava cada registro de in na sua partição até o canal ser fechado ou ctx ser cancelado.
+// Ao terminar, os buffers de todas as partições abertas são gravados em disco.
+func (s PartitionedSink) Consume(ctx context.Context, in <-chan ProcessedRecord) {
+	infof("PartitionedLoader: Iniciando carregamento em %s...", s.Partition.Dir)
+	writer, err := newPartitionWriter(s.Partition)
+	if err != nil {
+		log.Fatalf("PartitionedLoader: Configuração de partições inválida: %v", err)
+	}
+	defer func() {
+		if err := writer.Close(); err != nil {
+			errorf("PartitionedLoader: Erro ao fechar partições: %v", err)
+		}
+	}()
+	ticker := time.NewTicker(partitionFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		var record ProcessedRecord
+		select {
+		case r, ok := <-in:
+			if !ok {
+				infof("PartitionedLoader: Carregamento de dados finalizado.")
+				return
+			}
+			record = r
+		case <-ticker.C:
+			if err := writer.Flush(); err != nil {
+				errorf("PartitionedLoader: Erro ao gravar buffers: %v", err)
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			warnf("PartitionedLoader: Gravação abortada (%v)", ctx.Err())
+			return
+		}
+		jsonBytes, err := json.Marshal(record)
+		if err != nil {
+			errorf("PartitionedLoader: Erro ao serializar registro %s: %v", record.ID, err)
+			continue
+		}
+		path, err := writer.Write(record.DataRecord, append(jsonBytes, '\n'))
+		if err != nil {
+			errorf("PartitionedLoader: Erro ao escrever registro %s: %v", record.ID, err)
+			continue
+		}
+		debugf("PartitionedLoader: Carregado %s em %s (Anomaly: %t)", record.ID, path, record.IsAnomaly)
+	}
+}
+
+// partitionWriter mantém no máximo MaxOpenFiles arquivos abertos, fechando o usado há
+// mais tempo quando uma partição nova precisa ser aberta.
+type partitionWriter struct {
+	cfg      PartitionConfig
+	template partitionTemplate
+	lru      *list.List                // *partitionFile abertos; o mais recente na frente
+	files    map[string]*partitionFile // Por diretório, inclusive os já fechados
+
+	written   int // Arquivos part-NNNN criados nesta execução
+	evictions int
+}
+
+// partitionFile é o arquivo part-NNNN atual de uma partição.
+type partitionFile struct {
+	dir     string
+	part    int
+	records int
+
+	file *os.File
+	buf  *bufio.Writer
+	elem *list.Element // nil enquanto o arquivo está fechado
+}
+
+func (f *partitionFile) path() string {
+	return filepath.Join(f.dir, fmt.Sprintf("part-%04d.jsonl", f.part))
+}
+
+func newPartitionWriter(cfg PartitionConfig) (*partitionWriter, error) {
+	if cfg.Template == "" {
+		cfg.Template = DefaultPartitionTemplate
+	}
+	if cfg.MaxOpenFiles <= 0 {
+		cfg.MaxOpenFiles = 1
+	}
+	template, err := parsePartitionTemplate(cfg.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &partitionWriter{cfg: cfg, template: template, lru: list.New(), files: make(map[string]*partitionFile)}, nil
+}
+
+// Write grava line na partição de record e retorna o arquivo usado.
+func (w *partitionWriter) Write(record DataRecord, line []byte) (string, error) {
+	dir := filepath.Join(w.cfg.Dir, w.template.render(record))
+	f, ok := w.files[dir]
+	if !ok {
+		// Novos arquivos continuam a numeração deixada por execuções anteriores
+		part, err := nextPart(dir)
+		if err != nil {
+			return "", err
+		}
+		f = &partitionFile{dir: dir, part: part}
+		w.files[dir] = f
+	}
+	if err := w.open(f); err != nil {
+		return "", err
+	}
+	path := f.path()
+	if _, err := f.buf.Write(line); err != nil {
+		return path, err
+	}
+	f.records++
+	if w.cfg.MaxRecordsPerFile > 0 && f.records >= w.cfg.MaxRecordsPerFile {
+		f.part++
+		f.records = 0
+		return path, w.close(f)
+	}
+	return path, nil
+}
+
+// open garante que o arquivo atual de f esteja aberto e no topo do LRU.
+func (w *partitionWriter) open(f *partitionFile) error {
+	if f.elem != nil {
+		w.lru.MoveToFront(f.elem)
+		return nil
+	}
+	for w.lru.Len() >= w.cfg.MaxOpenFiles {
+		oldest := w.lru.Back().Value.(*partitionFile)
+		w.evictions++
+		if err := w.close(oldest); err != nil {
+			return fmt.Errorf("falha ao fechar %s: %w", oldest.path(), err)
+		}
+	}
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+	// Um arquivo fechado pelo LRU é reaberto para continuar de onde parou
+	file, err := os.OpenFile(f.path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if f.records == 0 {
+		w.written++
+	}
+	f.file, f.buf = file, bufio.NewWriter(file)
+	f.elem = w.lru.PushFront(f)
+	return nil
+}
+
+// close grava o buffer de f em disco e fecha o arquivo.
+func (w *partitionWriter) close(f *partitionFile) error {
+	w.lru.Remove(f.elem)
+	f.elem = nil
+	err := f.buf.Flush()
+	if syncErr := f.file.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file, f.buf = nil, nil
+	return err
+}
+
+// Flush grava os buffers das partições abertas, sem fechá-las.
+func (w *partitionWriter) Flush() error {
+	var errs []error
+	for e := w.lru.Front(); e != nil; e = e.Next() {
+		if err := e.Value.(*partitionFile).buf.Flush(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close grava em disco e fecha todas as partições abertas.
+func (w *partitionWriter) Close() error {
+	var errs []error
+	for w.lru.Len() > 0 {
+		f := w.lru.Front().Value.(*partitionFile)
+		if err := w.close(f); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.path(), err))
+		}
+	}
+	infof("PartitionedLoader: %d partições, %d arquivos gravados (%d fechados pelo limite de %d abertos)",
+		len(w.files), w.written, w.evictions, w.cfg.MaxOpenFiles)
+	return errors.Join(errs...)
+}
+
+// nextPart retorna o próximo número livre de arquivo part-NNNN.jsonl em dir.
+func nextPart(dir string) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	last := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "part-") || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "part-"), ".jsonl")); err == nil && n > last {
+			last = n
+		}
+	}
+	return last + 1, nil
+}
@@ -0,0 +1,127 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func partitionRecord(id, sensor, location string, ts time.Time) ProcessedRecord {
+	return ProcessedRecord{DataRecord: DataRecord{ID: id, SensorID: sensor, Location: location, Timestamp: ts}}
+}
+
+func loadPartitioned(t *testing.T, cfg PartitionConfig, records ...ProcessedRecord) {
+	t.Helper()
+	in := make(chan ProcessedRecord, len(records))
+	for _, record := range records {
+		in <- record
+	}
+	close(in)
+	PartitionedSink{Partition: cfg}.Consume(context.Background(), in)
+}
+
+func TestPartitionedSinkLayout(t *testing.T) {
+	dir := t.TempDir()
+	day1 := time.Date(2025, 1, 15, 23, 59, 0, 0, time.UTC)
+	day2 := time.Date(2025, 1, 16, 1, 0, 0, 0, time.FixedZone("BRT", -3*3600)) // 04:00 UTC
+	// Um único arquivo aberto força o fechamento e a reabertura a cada troca de partição
+	cfg := PartitionConfig{Dir: dir, Template: DefaultPartitionTemplate, MaxOpenFiles: 1, MaxRecordsPerFile: 3}
+
+	loadPartitioned(t, cfg,
+		partitionRecord("a1", "sensor-1", "North", day1),
+		partitionRecord("b1", "sensor-2", "South", day1),
+		partitionRecord("a2", "sensor-1", "North", day1),
+		partitionRecord("c1", "sensor-1", "North", day2),
+		partitionRecord("a3", "sensor-1", "North", day1),
+		partitionRecord("a4", "sensor-1", "North", day1),
+	)
+
+	north := filepath.Join(dir, "date=2025-01-15", "location=North", "sensor_id=sensor-1")
+	want := map[string][]string{
+		filepath.Join(north, "part-0001.jsonl"):                                                          {"a1", "a2", "a3"},
+		filepath.Join(north, "part-0002.jsonl"):                                                          {"a4"},
+		filepath.Join(dir, "date=2025-01-15", "location=South", "sensor_id=sensor-2", "part-0001.jsonl"): {"b1"},
+		filepath.Join(dir, "date=2025-01-16", "location=North", "sensor_id=sensor-1", "part-0001.jsonl"): {"c1"},
+	}
+	for path, ids := range want {
+		lines := segmentLines(t, path)
+		if len(lines) != len(ids) {
+			t.Errorf("Expected %d records in %s, got %v", len(ids), path, lines)
+			continue
+		}
+		for i, id := range ids {
+			if !strings.Contains(lines[i], `"id":"`+id+`"`) {
+				t.Errorf("Expected %s at line %d of %s, got %s", id, i+1, path, lines[i])
+			}
+		}
+	}
+
+	// Uma nova execução continua a numeração em vez de sobrescrever
+	loadPartitioned(t, cfg, partitionRecord("d1", "sensor-2", "South", day1))
+	next := filepath.Join(dir, "date=2025-01-15", "location=South", "sensor_id=sensor-2", "part-0002.jsonl")
+	if lines := segmentLines(t, next); len(lines) != 1 || !strings.Contains(lines[0], `"id":"d1"`) {
+		t.Errorf("Expected d1 in %s, got %v", next, lines)
+	}
+}
+
+func TestPartitionTemplate(t *testing.T) {
+	ts := time.Date(2025, 1, 15, 9, 0, 0, 0, time.UTC)
+	template, err := parsePartitionTemplate("year={year}/month={month}/{hour}h/loc={location}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	got := template.render(DataRecord{Timestamp: ts, Location: "../a/b=c"})
+	if want := filepath.FromSlash("year=2025/month=01/09h/loc=..%2Fa%2Fb%3Dc"); got != want {
+		t.Errorf("render = %q, want %q", got, want)
+	}
+	if got := template.render(DataRecord{Location: ".."}); got != filepath.FromSlash(
+		"year=__HIVE_DEFAULT_PARTITION__/month=__HIVE_DEFAULT_PARTITION__/__HIVE_DEFAULT_PARTITION__h/loc=%2E%2E") {
+		t.Errorf("Expected empty values to use the default partition, got %q", got)
+	}
+
+	for _, invalid := range []string{"", "/abs/{date}", "a//{date}", "../{date}", "static", "{nope}", "{date", "date}/{date}"} {
+		if _, err := parsePartitionTemplate(invalid); err == nil {
+			t.Errorf("Expected error for template %q", invalid)
+		}
+	}
+}
+
+func TestPartitionConfigValidation(t *testing.T) {
+	for yaml, want := range map[string]string{
+		"output:\n  partition:\n    dir: out\n    template: \"{bogus}\"\n":    "output.partition.template",
+		"output:\n  partition:\n    dir: out\n    max_open_files: 0\n":        "output.partition.max_open_files",
+		"output:\n  partition:\n    dir: out\n    max_records_per_file: -1\n": "output.partition.max_records_per_file",
+	} {
+		if _, err := ParseConfig([]byte(yaml)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got %v", want, err)
+		}
+	}
+
+	dir := t.TempDir()
+	cfg, err := ParseConfig([]byte("pipeline:\n  num_records: 5\noutput:\n  partition:\n    dir: " + filepath.Join(dir, "parts") +
+		"\n  processed_file: " + filepath.Join(dir, "processed.jsonl") + "\n  failed_file: " + filepath.Join(dir, "failed.jsonl") + "\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	p, err := DefaultBuilder(cfg).Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	metrics, _ := p.Run(context.Background())
+	if _, err := os.Stat(cfg.Output.ProcessedFile); !os.IsNotExist(err) {
+		t.Errorf("Expected processed_file to be skipped when partitioning, stat error: %v", err)
+	}
+	var written int
+	_ = filepath.Walk(filepath.Join(dir, "parts"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			written += len(segmentLines(t, path))
+		}
+		return nil
+	})
+	if written != metrics.ProcessedCount {
+		t.Errorf("Expected %d partitioned records, got %d", metrics.ProcessedCount, written)
+	}
+}
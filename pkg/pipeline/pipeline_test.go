@@ -1,6 +1,11 @@
 package pipeline
 
 import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -9,7 +14,7 @@ func TestProducer(t *testing.T) {
 	numRecords := 10
 	dataCh := make(chan DataRecord, numRecords)
 	
-	go Producer(dataCh, numRecords)
+	go Producer(context.Background(), dataCh, numRecords, DefaultConfig().Producer)
 	
 	count := 0
 	for record := range dataCh {
@@ -40,14 +45,14 @@ func TestValidator(t *testing.T) {
 	
 	// Test valid record
 	go func() {
-		dataCh <- DataRecord{ID: "test-1", Value: 50.0, Unit: "unit_A", Timestamp: time.Now()}
-		dataCh <- DataRecord{ID: "test-2", Value: -5.0, Unit: "unit_A", Timestamp: time.Now()} // Invalid
-		dataCh <- DataRecord{ID: "test-3", Value: 1001.0, Unit: "unit_A", Timestamp: time.Now()} // Invalid
+		dataCh <- DataRecord{ID: "test-1", Value: 50.0, Unit: "unit_A", Timestamp: time.Now(), SensorID: "sensor-1", Location: "North"}
+		dataCh <- DataRecord{ID: "test-2", Value: -5.0, Unit: "unit_A", Timestamp: time.Now(), SensorID: "sensor-1", Location: "North"} // Invalid
+		dataCh <- DataRecord{ID: "test-3", Value: 1001.0, Unit: "unit_A", Timestamp: time.Now(), SensorID: "sensor-1", Location: "North"} // Invalid
 		close(dataCh)
 	}()
 	
 	go func() {
-		Validator(dataCh, validCh, errorCh)
+		Validator(context.Background(), dataCh, validCh, errorCh, DefaultConfig().Validator)
 		close(validCh)
 		close(errorCh)
 	}()
@@ -98,7 +103,7 @@ func TestTransformer(t *testing.T) {
 	}()
 	
 	go func() {
-		Transformer(validCh, processedCh, errorCh)
+		Transformer(context.Background(), validCh, processedCh, errorCh, DefaultConfig().Transformer)
 		close(processedCh)
 		close(errorCh)
 	}()
@@ -183,10 +188,74 @@ func TestMetricsCollector(t *testing.T) {
 	}
 }
 
+func TestProducerStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	dataCh := make(chan DataRecord)
+
+	go Producer(ctx, dataCh, 1000, DefaultConfig().Producer)
+
+	<-dataCh
+	cancel()
+
+	count := 0
+	for range dataCh {
+		count++
+	}
+	if count > 1 {
+		t.Errorf("Producer should stop right after cancellation, got %d extra records", count)
+	}
+}
+
+func TestRunPipelineCancellation(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Pipeline.NumRecords = 100000
+	cfg.Pipeline.ChannelBufferSize = 10
+	cfg.Output.ProcessedFile = filepath.Join(dir, "processed.jsonl")
+	cfg.Output.FailedFile = filepath.Join(dir, "failed.jsonl")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	metrics, reason := RunPipeline(ctx, cfg)
+
+	if reason != StopCancelled {
+		t.Errorf("Expected stop reason %q, got %q", StopCancelled, reason)
+	}
+	total := metrics.ProcessedCount + metrics.ErrorCount
+	if total == 0 || total >= cfg.Pipeline.NumRecords {
+		t.Errorf("Expected partial metrics, got %d of %d records", total, cfg.Pipeline.NumRecords)
+	}
+
+	// Todos os registros drenados devem estar gravados em linhas completas
+	for path, expected := range map[string]int{
+		cfg.Output.ProcessedFile: metrics.ProcessedCount,
+		cfg.Output.FailedFile:    metrics.ErrorCount,
+	} {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", path, err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+		if len(content) == 0 {
+			lines = nil
+		}
+		if len(lines) != expected {
+			t.Errorf("Expected %d lines in %s, got %d", expected, path, len(lines))
+		}
+		for i, line := range lines {
+			var record DataRecord
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Errorf("Line %d of %s is not valid JSON: %v", i+1, path, err)
+			}
+		}
+	}
+}
+
 func BenchmarkProducer(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		dataCh := make(chan DataRecord, 100)
-		go Producer(dataCh, 100)
+		go Producer(context.Background(), dataCh, 100, DefaultConfig().Producer)
 		for range dataCh {
 			// Consume all records
 		}
@@ -201,13 +270,13 @@ func BenchmarkValidator(b *testing.B) {
 		
 		go func() {
 			for j := 0; j < 100; j++ {
-				dataCh <- DataRecord{ID: "test", Value: float64(j), Unit: "unit_A", Timestamp: time.Now()}
+				dataCh <- DataRecord{ID: "test", Value: float64(j), Unit: "unit_A", Timestamp: time.Now(), SensorID: "sensor-1", Location: "North"}
 			}
 			close(dataCh)
 		}()
 		
 		go func() {
-			Validator(dataCh, validCh, errorCh)
+			Validator(context.Background(), dataCh, validCh, errorCh, DefaultConfig().Validator)
 			close(validCh)
 			close(errorCh)
 		}()
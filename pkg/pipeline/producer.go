@@ -5,40 +5,76 @@
 package pipeline
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"math/rand"
 	"time"
 )
 
-// Producer gera registros de dados simulados.
-func Producer(out chan<- DataRecord, numRecords int) {
-	log.Println("Producer: Iniciando produção de dados...")
-	locations := []string{"North", "South", "East", "West", "Center"}
-	
+// Producer gera registros de dados simulados conforme cfg.
+// Interrompe a produção e fecha out assim que ctx é cancelado.
+func Producer(ctx context.Context, out chan<- DataRecord, numRecords int, cfg ProducerConfig) {
+	defer close(out)
+	ProducerSource{NumRecords: numRecords, Config: cfg}.Run(ctx, out, nil)
+}
+
+// ProducerSource é a Source padrão, que gera NumRecords registros simulados.
+type ProducerSource struct {
+	NumRecords int
+	Config     ProducerConfig
+}
+
+// Name identifica a etapa nos logs.
+func (s ProducerSource) Name() string { return "Producer" }
+
+// Run gera os registros simulados em out até esgotar NumRecords ou ctx ser cancelado.
+func (s ProducerSource) Run(ctx context.Context, out chan<- DataRecord, _ chan<- DataRecord) {
+	cfg := s.Config
+	numRecords := s.NumRecords
+	infof("Producer: Iniciando produção de dados...")
+	locations := cfg.Locations
+
+	// Intervalo entre registros quando há limite de taxa
+	var interval time.Duration
+	if cfg.RateLimit > 0 {
+		interval = time.Duration(float64(time.Second) / cfg.RateLimit)
+	}
+
 	for i := 0; i < numRecords; i++ {
+		if ctx.Err() != nil {
+			infof("Producer: Produção interrompida após %d registros (%v)", i, ctx.Err())
+			return
+		}
 		record := DataRecord{
 			ID:        fmt.Sprintf("rec-%04d", i),
 			Value:     rand.Float64() * 100, // Valor entre 0 e 100
 			Unit:      "unit_A",
 			Timestamp: time.Now(),
 			Status:    "raw",
-			SensorID:  fmt.Sprintf("sensor-%d", (i%5)+1), // Simula 5 sensores diferentes
-			Location:  locations[i%len(locations)],        // Rotaciona entre as localizações
+			SensorID:  fmt.Sprintf("sensor-%d", (i%cfg.NumSensors)+1), // Simula cfg.NumSensors sensores diferentes
+			Location:  locations[i%len(locations)],                     // Rotaciona entre as localizações
 		}
-		// Simular alguns erros para teste
-		if i%10 == 0 {
-			record.Value = -1.0 // Valor inválido
+		// Simular alguns erros para teste, metade de cada tipo
+		if rand.Float64() < cfg.ErrorInjectionRate {
+			if rand.Intn(2) == 0 {
+				record.Value = -1.0 // Valor inválido
+			} else {
+				record.Unit = "INVALID_UNIT" // Unidade inválida para transformação
+			}
 		}
-		if i%11 == 0 {
-			record.Unit = "INVALID_UNIT" // Unidade inválida para transformação
+		if !send(ctx, out, record) {
+			infof("Producer: Produção interrompida após %d registros (%v)", i, ctx.Err())
+			return
 		}
-		out <- record
-		log.Printf("Producer: Produzido %s (Value: %.2f, Sensor: %s, Location: %s)", 
+		debugf("Producer: Produzido %s (Value: %.2f, Sensor: %s, Location: %s)", 
 			record.ID, record.Value, record.SensorID, record.Location)
-		time.Sleep(time.Duration(rand.Intn(50)) * time.Millisecond)
+		if interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+			}
+		}
 	}
-	close(out)
-	log.Println("Producer: Produção de dados finalizada.")
+	infof("Producer: Produção de dados finalizada.")
 }
 
@@ -0,0 +1,366 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Resultados de um registro em ReplayResult.Outcome.
+const (
+	ReplaySucceeded = "succeeded" // Passou por todas as etapas
+	ReplayFailed    = "failed"    // Falhou novamente
+	ReplayPending   = "pending"   // Não concluiu antes do fim da execução (cancelamento)
+)
+
+// ReplayFilter seleciona os registros de um arquivo de dead-letter a reprocessar.
+// Critérios vazios não filtram; critérios preenchidos precisam ser todos satisfeitos.
+type ReplayFilter struct {
+	Statuses []string  // Status do registro deve ser um destes
+	Codes    []string  // Registro deve ter ao menos um destes ErrorCodes
+	Since    time.Time // Timestamp >= Since
+	Until    time.Time // Timestamp < Until
+}
+
+// Match reporta se record satisfaz o filtro.
+func (f ReplayFilter) Match(record DataRecord) bool {
+	if len(f.Statuses) > 0 && !containsString(f.Statuses, record.Status) {
+		return false
+	}
+	if len(f.Codes) > 0 {
+		var found bool
+		for _, code := range record.ErrorCodes {
+			found = found || containsString(f.Codes, code)
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && record.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !record.Timestamp.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// ReplayOptions descreve uma execução de replay.
+type ReplayOptions struct {
+	Input         string // Arquivo de dead-letter (ex.: failed_data.jsonl)
+	Filter        ReplayFilter
+	ProcessedFile string // Registros que agora passam
+	FailedFile    string // Registros que falham novamente
+	ReportFile    string // Relatório JSON; vazio para não gravar
+}
+
+// ReplayResult compara o estado anterior de um registro com o resultado do replay.
+type ReplayResult struct {
+	Line               int      `json:"line"` // Linha no arquivo de dead-letter
+	ID                 string   `json:"id"`
+	PreviousStatus     string   `json:"previous_status"`
+	PreviousError      string   `json:"previous_error,omitempty"`
+	PreviousErrorCodes []string `json:"previous_error_codes,omitempty"`
+	Outcome            string   `json:"outcome"`
+	Status             string   `json:"status,omitempty"`
+	Error              string   `json:"error,omitempty"`
+	ErrorCodes         []string `json:"error_codes,omitempty"`
+}
+
+// ReplayReport resume uma execução de replay.
+type ReplayReport struct {
+	Input      string         `json:"input"`
+	Read       int            `json:"read"`       // Registros lidos do arquivo
+	Unreadable int            `json:"unreadable"` // Linhas que não são registros JSON
+	Matched    int            `json:"matched"`    // Registros selecionados pelo filtro
+	Succeeded  int            `json:"succeeded"`
+	Failed     int            `json:"failed"`
+	Pending    int            `json:"pending"`
+	Records    []ReplayResult `json:"records"`
+}
+
+// RunReplay reprocessa os registros de opts.Input selecionados por opts.Filter com as etapas
+// padrão de cfg (Validator e Transformer). Cada registro volta ao status "raw", sem os erros
+// anteriores; registros que falharam na leitura (parse_error) são interpretados novamente
+// a partir de Raw, no formato de SourceFormat (linhas CSV com source.csv e o cabeçalho de
+// source.path). O relatório compara o estado anterior de cada registro com o novo resultado.
+// Os registros vão apenas para opts.ProcessedFile e opts.FailedFile; as demais saídas de cfg
+// são ignoradas.
+func RunReplay(ctx context.Context, cfg Config, opts ReplayOptions) (ReplayReport, StopReason, error) {
+	if opts.Input == "" {
+		return ReplayReport{}, "", errors.New("replay: arquivo de entrada não informado")
+	}
+	for _, out := range []string{opts.ProcessedFile, opts.FailedFile, opts.ReportFile} {
+		if out != "" && filepath.Clean(out) == filepath.Clean(opts.Input) {
+			return ReplayReport{}, "", fmt.Errorf("replay: a saída %s sobrescreveria o arquivo de entrada", out)
+		}
+	}
+	if opts.ProcessedFile == "" || opts.FailedFile == "" {
+		return ReplayReport{}, "", errors.New("replay: arquivos de saída não informados")
+	}
+	file, err := openRecordFile(opts.Input)
+	if err != nil {
+		return ReplayReport{}, "", fmt.Errorf("replay: %w", err)
+	}
+	_ = file.Close()
+
+	// As saídas são apenas as do replay: os destinos adicionais da pipeline (Parquet,
+	// Postgres, SQLite, partições, agregados por janela, sessões e registros atrasados)
+	// receberiam os registros reprocessados misturados aos da execução original. Sem o
+	// watermark, registros antigos também não são julgados atrasados
+	cfg.Output.ProcessedFile = opts.ProcessedFile
+	cfg.Output.FailedFile = opts.FailedFile
+	cfg.Output.Partition.Dir = ""
+	cfg.Output.Parquet.Path = ""
+	cfg.Output.Postgres.DSN = ""
+	cfg.Output.SQLite.Path = ""
+	cfg.Window.Enabled = false
+	cfg.Session.Enabled = false
+	cfg.Watermark.Enabled = false
+	// As filas em disco ficam ao lado de opts.ProcessedFile: as da execução original
+	// entregariam seus registros ao replay
+	cfg.Advanced.Backpressure.SpillDir = ""
+	// ReplaySource relê o arquivo inteiro a cada execução e não é uma ResumableSource
+	cfg.Source.Checkpoint = CheckpointConfig{}
+	collector := newReplayCollector(opts.Input)
+	source := ReplaySource{Path: opts.Input, Filter: opts.Filter, collector: collector}
+	if cfg.Source.Type == SourceCSV {
+		source.CSV = CSVFileSource{Path: cfg.Source.Path, CSV: cfg.Source.CSV}
+	}
+	p, err := DefaultBuilder(cfg).
+		WithSource(source).
+		AddSink(collector).
+		AddErrorSink(collector).
+		Build()
+	if err != nil {
+		return ReplayReport{}, "", err
+	}
+
+	_, reason := p.Run(ctx)
+	report := collector.report()
+	if opts.ReportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return report, reason, fmt.Errorf("replay: falha ao serializar relatório: %w", err)
+		}
+		if err := os.WriteFile(opts.ReportFile, append(data, '\n'), 0o644); err != nil {
+			return report, reason, fmt.Errorf("replay: falha ao gravar relatório: %w", err)
+		}
+	}
+	infof("Replay: %d de %d registros selecionados; %d passaram, %d falharam novamente",
+		report.Matched, report.Read, report.Succeeded, report.Failed)
+	return report, reason, nil
+}
+
+// ReplaySource é a Source que relê um arquivo de dead-letter gravado pelo ErrorHandler,
+// inclusive segmentos rotacionados comprimidos (.gz ou .zst).
+type ReplaySource struct {
+	Path   string
+	Filter ReplayFilter
+	// CSV é a Source original das linhas CSV que falharam na leitura: o cabeçalho de CSV.Path
+	// localiza as colunas e CSV.CSV descreve o formato
+	CSV CSVFileSource
+
+	collector *replayCollector
+	columns   map[string]int // Colunas do cabeçalho de CSV.Path; nil se indisponível
+}
+
+// Name identifica a etapa nos logs.
+func (s ReplaySource) Name() string { return "ReplaySource" }
+
+// Run lê o arquivo até o fim ou até ctx ser cancelado.
+func (s ReplaySource) Run(ctx context.Context, out chan<- DataRecord, errCh chan<- DataRecord) {
+	infof("ReplaySource: Iniciando leitura de %s...", s.Path)
+	file, err := openRecordFile(s.Path)
+	if err != nil {
+		log.Fatalf("ReplaySource: Falha ao abrir arquivo de dead-letter: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+	if s.CSV.Path != "" {
+		if s.columns, err = s.CSV.readHeader(); err != nil {
+			warnf("ReplaySource: Linhas CSV não serão interpretadas novamente: %v", err)
+		}
+	}
+
+	lines, err := readLines(file, func(lineNumber int, line []byte) bool {
+		return ctx.Err() == nil && s.replayLine(ctx, lineNumber, line, out, errCh)
+	})
+	if err != nil {
+		errorf("ReplaySource: Erro ao ler %s após a linha %d: %v", s.Path, lines, err)
+		return
+	}
+	infof("ReplaySource: Leitura de %s finalizada (%d linhas).", s.Path, lines)
+}
+
+// replayLine restaura o registro de uma linha e o envia para out, se selecionado pelo filtro.
+// Retorna false se ctx foi cancelado durante o envio.
+func (s ReplaySource) replayLine(ctx context.Context, lineNumber int, line []byte, out chan<- DataRecord, errCh chan<- DataRecord) bool {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 {
+		return true
+	}
+	var previous DataRecord
+	if err := json.Unmarshal(trimmed, &previous); err != nil {
+		warnf("ReplaySource: Linha %d não é um registro: %v", lineNumber, err)
+		s.collector.unreadable()
+		return true
+	}
+	if !s.collector.read(previous, s.Filter) {
+		return true
+	}
+
+	record := previous
+	if previous.Status == "parse_error" && previous.Raw != "" {
+		// A linha original nunca foi interpretada; tenta novamente a partir do texto original
+		var err error
+		if record, err = s.reparse(previous); err != nil {
+			failed := previous
+			failed.Error = err.Error()
+			failed.ReplayLine = lineNumber
+			s.collector.expect(lineNumber, previous)
+			return send(ctx, errCh, failed)
+		}
+		record.SourceLine = previous.SourceLine
+	}
+	record.Status = "raw"
+	record.Error = ""
+	record.ErrorCodes = nil
+	record.Attempts = 0
+	record.ErrorHistory = nil
+	record.Raw = ""
+	record.SourceFormat = ""
+	record.ReplayLine = lineNumber
+	s.collector.expect(lineNumber, previous)
+	return send(ctx, out, record)
+}
+
+// reparse interpreta novamente o texto original de um registro que falhou na leitura, no
+// formato da Source que o leu.
+func (s ReplaySource) reparse(previous DataRecord) (DataRecord, error) {
+	if previous.SourceFormat == SourceCSV {
+		if s.columns == nil {
+			return DataRecord{}, fmt.Errorf("Invalid CSV row at line %d: cabeçalho do CSV de origem indisponível (source.type csv e source.path)", previous.SourceLine)
+		}
+		record, err := s.CSV.parseLine(previous.Raw, s.columns)
+		if err != nil {
+			return DataRecord{}, fmt.Errorf("Invalid CSV row at line %d: %v", previous.SourceLine, err)
+		}
+		if record.ID == "" {
+			record.ID = previous.ID // Mesmo ID atribuído por CSVFileSource: <arquivo>:<linha>
+		}
+		return record, nil
+	}
+	var record DataRecord
+	if err := json.Unmarshal([]byte(previous.Raw), &record); err != nil {
+		return DataRecord{}, fmt.Errorf("Invalid JSON at line %d: %v", previous.SourceLine, err)
+	}
+	return record, nil
+}
+
+// replayCollector acompanha os registros reenviados e registra o resultado de cada um.
+// É ao mesmo tempo Sink e ErrorSink da pipeline de replay.
+type replayCollector struct {
+	mu      sync.Mutex
+	summary ReplayReport
+	results map[int]*ReplayResult // Por linha do arquivo de dead-letter
+}
+
+func newReplayCollector(input string) *replayCollector {
+	return &replayCollector{summary: ReplayReport{Input: input}, results: make(map[int]*ReplayResult)}
+}
+
+// Name identifica a etapa nos logs.
+func (c *replayCollector) Name() string { return "ReplayReport" }
+
+func (c *replayCollector) unreadable() {
+	c.mu.Lock()
+	c.summary.Unreadable++
+	c.mu.Unlock()
+}
+
+// read contabiliza um registro lido e reporta se ele é selecionado por filter.
+func (c *replayCollector) read(record DataRecord, filter ReplayFilter) bool {
+	matched := filter.Match(record)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.summary.Read++
+	if matched {
+		c.summary.Matched++
+	}
+	return matched
+}
+
+// expect registra o estado anterior do registro reenviado a partir de line.
+func (c *replayCollector) expect(line int, previous DataRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results[line] = &ReplayResult{
+		Line:               line,
+		ID:                 previous.ID,
+		PreviousStatus:     previous.Status,
+		PreviousError:      previous.Error,
+		PreviousErrorCodes: previous.ErrorCodes,
+		Outcome:            ReplayPending,
+	}
+}
+
+// finish registra o resultado do replay de record.
+func (c *replayCollector) finish(record DataRecord, outcome string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result, ok := c.results[record.ReplayLine]
+	if !ok {
+		return
+	}
+	result.Outcome = outcome
+	result.Status = record.Status
+	result.Error = record.Error
+	result.ErrorCodes = record.ErrorCodes
+}
+
+// Consume registra os registros que passaram por todas as etapas.
+func (c *replayCollector) Consume(_ context.Context, in <-chan ProcessedRecord) {
+	for record := range in {
+		c.finish(record.DataRecord, ReplaySucceeded)
+	}
+}
+
+// ConsumeErrors registra os registros que falharam novamente.
+func (c *replayCollector) ConsumeErrors(_ context.Context, in <-chan DataRecord) {
+	for record := range in {
+		c.finish(record, ReplayFailed)
+	}
+}
+
+// report retorna o relatório com os registros em ordem de linha.
+func (c *replayCollector) report() ReplayReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := c.summary
+	report.Records = make([]ReplayResult, 0, len(c.results))
+	for _, result := range c.results {
+		report.Records = append(report.Records, *result)
+		switch result.Outcome {
+		case ReplaySucceeded:
+			report.Succeeded++
+		case ReplayFailed:
+			report.Failed++
+		default:
+			report.Pending++
+		}
+	}
+	sort.Slice(report.Records, func(i, j int) bool { return report.Records[i].Line < report.Records[j].Line })
+	return report
+}
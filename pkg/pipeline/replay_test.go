@@ -0,0 +1,284 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunReplay(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	base := DataRecord{Timestamp: ts, SensorID: "sensor-1", Value: 5, Unit: "unit_A", Location: "North"}
+
+	outOfRange := base
+	outOfRange.ID, outOfRange.Value, outOfRange.Status = "dl-1", -1, "invalid"
+	outOfRange.Error, outOfRange.ErrorCodes = "VALUE_OUT_OF_RANGE: Value out of expected range (0-1000)", []string{ErrCodeValueOutOfRange}
+	outOfRange.Attempts = 1
+	badUnit := base
+	badUnit.ID, badUnit.Unit, badUnit.Status = "dl-2", "INVALID_UNIT", "transformation_error"
+	badUnit.Error, badUnit.ErrorCodes = "Invalid unit for transformation", []string{ErrCodeInvalidUnit}
+	fixedRaw := base
+	fixedRaw.ID = "dl-3"
+	raw, _ := json.Marshal(fixedRaw)
+	parseFixed := DataRecord{ID: "input.jsonl:7", Status: "parse_error", ErrorCodes: []string{ErrCodeParseError}, SourceLine: 7, Raw: string(raw)}
+	parseBroken := DataRecord{ID: "input.jsonl:9", Status: "parse_error", ErrorCodes: []string{ErrCodeParseError}, SourceLine: 9, Raw: "{not json"}
+
+	var lines []string
+	for _, record := range []DataRecord{outOfRange, badUnit, parseFixed, parseBroken} {
+		data, _ := json.Marshal(record)
+		lines = append(lines, string(data))
+	}
+	lines = append(lines, "", "garbage")
+	input := filepath.Join(dir, "failed_data.jsonl")
+	if err := os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A regra de valor foi corrigida; unidades inválidas continuam sendo rejeitadas
+	cfg := DefaultConfig()
+	cfg.Validator.MinValue = -10
+	opts := ReplayOptions{
+		Input:         input,
+		ProcessedFile: filepath.Join(dir, "replayed.jsonl"),
+		FailedFile:    filepath.Join(dir, "replay_failed.jsonl"),
+		ReportFile:    filepath.Join(dir, "report.json"),
+	}
+	report, reason, err := RunReplay(context.Background(), cfg, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if reason != StopCompleted {
+		t.Errorf("Expected stop reason %q, got %q", StopCompleted, reason)
+	}
+	if report.Read != 4 || report.Unreadable != 1 || report.Matched != 4 || report.Succeeded != 2 || report.Failed != 2 {
+		t.Fatalf("Unexpected report summary: %+v", report)
+	}
+
+	want := map[int]struct{ id, previous, outcome string }{
+		1: {"dl-1", "invalid", ReplaySucceeded},
+		2: {"dl-2", "transformation_error", ReplayFailed},
+		3: {"input.jsonl:7", "parse_error", ReplaySucceeded},
+		4: {"input.jsonl:9", "parse_error", ReplayFailed},
+	}
+	for _, result := range report.Records {
+		w := want[result.Line]
+		if result.ID != w.id || result.PreviousStatus != w.previous || result.Outcome != w.outcome {
+			t.Errorf("Line %d: expected %+v, got %+v", result.Line, w, result)
+		}
+	}
+
+	processed, err := os.ReadFile(opts.ProcessedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first ProcessedRecord
+	if err := json.Unmarshal([]byte(strings.Split(string(processed), "\n")[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != "processed" || first.Error != "" || first.Attempts != 0 || first.ReplayLine == 0 {
+		t.Errorf("Expected replayed record to be reset before processing, got %+v", first)
+	}
+
+	var saved ReplayReport
+	data, err := os.ReadFile(opts.ReportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Succeeded != 2 || len(saved.Records) != 4 {
+		t.Errorf("Expected report file to match the returned report, got %+v (err %v)", saved, err)
+	}
+}
+
+func TestReplayFilter(t *testing.T) {
+	ts := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	record := DataRecord{Status: "invalid", ErrorCodes: []string{ErrCodePatternMismatch, ErrCodeValueOutOfRange}, Timestamp: ts}
+
+	tests := []struct {
+		name   string
+		filter ReplayFilter
+		want   bool
+	}{
+		{"empty", ReplayFilter{}, true},
+		{"status", ReplayFilter{Statuses: []string{"transformation_error", "invalid"}}, true},
+		{"other status", ReplayFilter{Statuses: []string{"parse_error"}}, false},
+		{"code", ReplayFilter{Codes: []string{ErrCodeValueOutOfRange}}, true},
+		{"other code", ReplayFilter{Codes: []string{ErrCodeInvalidUnit}}, false},
+		{"since", ReplayFilter{Since: ts}, true},
+		{"until", ReplayFilter{Until: ts}, false},
+		{"range", ReplayFilter{Since: ts.Add(-time.Hour), Until: ts.Add(time.Hour)}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Match(record); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRunReplayRejectsOverwritingInput(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "failed_data.jsonl")
+	if err := os.WriteFile(input, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, _, err := RunReplay(context.Background(), DefaultConfig(), ReplayOptions{
+		Input:         input,
+		ProcessedFile: input + ".out",
+		FailedFile:    input,
+	})
+	if err == nil {
+		t.Error("Expected error when an output overwrites the input")
+	}
+	if _, _, err := RunReplay(context.Background(), DefaultConfig(), ReplayOptions{Input: input + ".missing", ProcessedFile: "a", FailedFile: "b"}); err == nil {
+		t.Error("Expected error for a missing input file")
+	}
+}
+
+func TestRunReplayIgnoresAdditionalOutputs(t *testing.T) {
+	dir := t.TempDir()
+	record := DataRecord{ID: "dl-1", Timestamp: time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC), SensorID: "sensor-1",
+		Value: 5, Unit: "unit_A", Location: "North", Status: "invalid", ErrorCodes: []string{ErrCodeValueOutOfRange}}
+	data, _ := json.Marshal(record)
+	input := filepath.Join(dir, "failed_data.jsonl")
+	if err := os.WriteFile(input, append(data, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Output.Parquet.Path = filepath.Join(dir, "processed.parquet")
+	cfg.Output.SQLite.Path = filepath.Join(dir, "pipeline.db")
+	cfg.Window.Enabled = true
+	cfg.Window.OutputFile = filepath.Join(dir, "window_aggregates.jsonl")
+	cfg.Session.Enabled = true
+	cfg.Session.OutputFile = filepath.Join(dir, "sessions.jsonl")
+	cfg.Source.Checkpoint = CheckpointConfig{Path: filepath.Join(dir, "checkpoint.json"), Interval: time.Second, ExactlyOnce: true}
+	cfg.Watermark.Enabled = true
+	cfg.Watermark.LatePolicy = LateSideOutput
+	cfg.Watermark.LateFile = filepath.Join(dir, "late_data.jsonl")
+	late := []byte(`{"id":"production-late"}` + "\n")
+	if err := os.WriteFile(cfg.Watermark.LateFile, late, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	report, _, err := RunReplay(context.Background(), cfg, ReplayOptions{
+		Input:         input,
+		ProcessedFile: filepath.Join(dir, "replayed.jsonl"),
+		FailedFile:    filepath.Join(dir, "replay_failed.jsonl"),
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Succeeded != 1 {
+		t.Fatalf("Expected the record to be replayed, got %+v", report)
+	}
+	for _, path := range []string{cfg.Output.Parquet.Path, cfg.Output.SQLite.Path, cfg.Window.OutputFile, cfg.Session.OutputFile, cfg.Source.Checkpoint.Path} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected replay not to write %s, got %v", filepath.Base(path), err)
+		}
+	}
+	if got, _ := os.ReadFile(cfg.Watermark.LateFile); string(got) != string(late) {
+		t.Errorf("Expected replay to leave the late file untouched, got %q", got)
+	}
+}
+
+func TestRunReplayIgnoresProductionSpillQueue(t *testing.T) {
+	dir := t.TempDir()
+	data, _ := json.Marshal(DataRecord{ID: "dl-1", Timestamp: time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC),
+		SensorID: "sensor-1", Value: 5, Unit: "unit_A", Location: "North", Status: "invalid"})
+	input := filepath.Join(dir, "failed_data.jsonl")
+	if err := os.WriteFile(input, append(data, '\n'), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Um registro deixado na fila em disco pela execução original
+	cfg := DefaultConfig()
+	cfg.Advanced.Backpressure.SpillDir = filepath.Join(dir, "spill")
+	cfg.Advanced.Backpressure.Edges = map[string]string{"loaderCh": BackpressureSpill}
+	queue, err := openSpillQueue[ProcessedRecord](filepath.Join(cfg.Advanced.Backpressure.SpillDir, "loaderCh"), 1<<20)
+	if err != nil {
+		t.Fatalf("Failed to open queue: %v", err)
+	}
+	if err := queue.push(ProcessedRecord{DataRecord: DataRecord{ID: "production-1"}}); err != nil {
+		t.Fatal(err)
+	}
+	queue.close()
+
+	replayed := filepath.Join(dir, "replayed.jsonl")
+	if _, _, err := RunReplay(context.Background(), cfg, ReplayOptions{
+		Input:         input,
+		ProcessedFile: replayed,
+		FailedFile:    filepath.Join(dir, "replay_failed.jsonl"),
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ids := readIDs(t, replayed); len(ids) != 1 || ids[0] != "dl-1" {
+		t.Errorf("Expected only the replayed record, got %v", ids)
+	}
+	queue, err = openSpillQueue[ProcessedRecord](filepath.Join(cfg.Advanced.Backpressure.SpillDir, "loaderCh"), 1<<20)
+	if err != nil {
+		t.Fatalf("Failed to reopen queue: %v", err)
+	}
+	defer queue.close()
+	if queue.len() != 1 {
+		t.Errorf("Expected the production queue to keep its record, got %d", queue.len())
+	}
+}
+
+func TestRunReplayReparsesCSVRows(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "input.csv")
+	content := "id,timestamp,sensor_id,value,unit,location\n" +
+		"a,15/01/2025 10:30,sensor-1,5,unit_A,\"North, upper\"\n" +
+		"b,15/01/2025 10:31,sensor-2,oops,unit_A,South\n"
+	if err := os.WriteFile(source, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.Source = SourceConfig{Type: SourceCSV, Path: source}
+	_, failed := readCSV(t, CSVFileSource{Path: source, CSV: cfg.Source.CSV})
+	if len(failed) != 2 {
+		t.Fatalf("Expected both rows to fail with the default timestamp layout, got %+v", failed)
+	}
+	var lines []string
+	for _, record := range failed {
+		data, _ := json.Marshal(record)
+		lines = append(lines, string(data))
+	}
+	input := filepath.Join(dir, "failed_data.jsonl")
+	if err := os.WriteFile(input, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// O layout do timestamp foi corrigido; o valor inválido continua rejeitado
+	cfg.Source.CSV.TimestampLayout = "02/01/2006 15:04"
+	opts := ReplayOptions{
+		Input:         input,
+		ProcessedFile: filepath.Join(dir, "replayed.jsonl"),
+		FailedFile:    filepath.Join(dir, "replay_failed.jsonl"),
+	}
+	report, _, err := RunReplay(context.Background(), cfg, opts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Succeeded != 1 || report.Failed != 1 {
+		t.Fatalf("Expected one row to pass and one to fail again, got %+v", report)
+	}
+	if result := report.Records[1]; result.ID != "input.csv:3" || !strings.Contains(result.Error, "Invalid CSV row at line 3") {
+		t.Errorf("Expected the bad value to fail as a CSV row, got %+v", result)
+	}
+
+	processed, err := os.ReadFile(opts.ProcessedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record ProcessedRecord
+	if err := json.Unmarshal(processed, &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.ID != "a" || record.Location != "North, upper" || record.SourceLine != 2 || record.Raw != "" || record.SourceFormat != "" ||
+		!record.Timestamp.Equal(time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)) {
+		t.Errorf("Expected the CSV row to be parsed with the fixed layout, got %+v", record)
+	}
+}
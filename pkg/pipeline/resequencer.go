@@ -0,0 +1,202 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// resequencerName identifica o resequenciador nos logs, na telemetria e nas sondas de saúde.
+const resequencerName = "Resequencer"
+
+// ResequenceConfig restaura, antes dos Sinks, a ordem em que a Source emitiu os registros.
+type ResequenceConfig struct {
+	Enabled   bool `yaml:"enabled"`
+	MaxBuffer int  `yaml:"max_buffer"` // Registros retidos à espera de um anterior; cheio, a menor lacuna é abandonada
+}
+
+// resequencer devolve os registros processados à ordem de DataRecord.Seq. Um registro fica
+// retido até que todos os anteriores tenham sido emitidos ou desviados para o caminho de erro
+// (informados por skip). Com mais de maxBuffer registros retidos, a menor lacuna é abandonada
+// e os registros seguintes são liberados; se o registro que faltava chegar depois, é emitido
+// imediatamente, fora de ordem.
+type resequencer struct {
+	maxBuffer int
+	telemetry *Telemetry
+	health    *Health
+
+	mu      sync.Mutex
+	skipped map[uint64]bool // Sequências desviadas para o caminho de erro
+	wake    chan struct{}   // Sinaliza novas sequências em skipped
+
+	next    uint64 // Próxima sequência a emitir
+	pending map[uint64]heldRecord
+	order   seqHeap
+
+	emitted, forced, late int
+	waited, maxWait       time.Duration
+}
+
+// heldRecord é um registro retido e o momento em que chegou ao resequenciador.
+type heldRecord struct {
+	record ProcessedRecord
+	since  time.Time
+}
+
+func newResequencer(maxBuffer int, telemetry *Telemetry, health *Health) *resequencer {
+	return &resequencer{
+		maxBuffer: maxBuffer,
+		telemetry: telemetry,
+		health:    health,
+		skipped:   make(map[uint64]bool),
+		wake:      make(chan struct{}, 1),
+		next:      1,
+		pending:   make(map[uint64]heldRecord),
+	}
+}
+
+// skip informa que a sequência seq foi desviada para o caminho de erro e não chegará ao
+// resequenciador. Nunca bloqueia, para não atrasar os registros com erro.
+func (r *resequencer) skip(seq uint64) {
+	if seq == 0 {
+		return
+	}
+	r.mu.Lock()
+	r.skipped[seq] = true
+	r.mu.Unlock()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run lê os registros de in e os emite em ordem em out, fechando out ao terminar. Quando in
+// é fechado, os registros retidos são emitidos em ordem, sem esperar pelas lacunas.
+func (r *resequencer) run(ctx context.Context, in <-chan ProcessedRecord, out chan<- ProcessedRecord) {
+	defer close(out)
+	defer func() {
+		mean := time.Duration(0)
+		if r.emitted > 0 {
+			mean = r.waited / time.Duration(r.emitted)
+		}
+		infof("Resequencer: %d registros emitidos (espera média %s, máxima %s, %d lacunas abandonadas, %d fora de ordem)",
+			r.emitted, mean, r.maxWait, r.forced, r.late)
+	}()
+	for {
+		select {
+		case record, ok := <-in:
+			if !ok {
+				for r.order.Len() > 0 {
+					if !r.emit(ctx, out, r.pop()) {
+						return
+					}
+				}
+				return
+			}
+			r.telemetry.recordStage(resequencerName, resultIn)
+			r.health.touch(resequencerName)
+			if !r.accept(ctx, record, out) {
+				return
+			}
+		case <-r.wake:
+			if !r.release(ctx, out) {
+				return
+			}
+		}
+	}
+}
+
+// accept retém record ou, se for o próximo, o emite com os que o seguem.
+func (r *resequencer) accept(ctx context.Context, record ProcessedRecord, out chan<- ProcessedRecord) bool {
+	held := heldRecord{record: record, since: time.Now()}
+	if record.Seq < r.next {
+		// Sem sequência, ou chegou depois que sua lacuna foi abandonada
+		if record.Seq != 0 {
+			r.late++
+			r.telemetry.recordResequence(resequenceLate)
+		}
+		return r.emit(ctx, out, held)
+	}
+	r.pending[record.Seq] = held
+	heap.Push(&r.order, record.Seq)
+	if !r.release(ctx, out) {
+		return false
+	}
+	for len(r.pending) > r.maxBuffer {
+		r.forced++
+		r.telemetry.recordResequence(resequenceForced)
+		r.next = r.order[0]
+		r.mu.Lock()
+		for seq := range r.skipped {
+			if seq < r.next {
+				delete(r.skipped, seq)
+			}
+		}
+		r.mu.Unlock()
+		if !r.release(ctx, out) {
+			return false
+		}
+	}
+	return true
+}
+
+// release emite os registros retidos a partir de next, pulando as sequências desviadas, até
+// encontrar uma lacuna.
+func (r *resequencer) release(ctx context.Context, out chan<- ProcessedRecord) bool {
+	for {
+		if _, ok := r.pending[r.next]; ok {
+			if !r.emit(ctx, out, r.pop()) {
+				return false
+			}
+			r.next++
+			continue
+		}
+		r.mu.Lock()
+		skipped := r.skipped[r.next]
+		delete(r.skipped, r.next)
+		r.mu.Unlock()
+		if !skipped {
+			return true
+		}
+		r.next++
+	}
+}
+
+// pop remove e retorna o registro retido de menor sequência.
+func (r *resequencer) pop() heldRecord {
+	seq := heap.Pop(&r.order).(uint64)
+	held := r.pending[seq]
+	delete(r.pending, seq)
+	return held
+}
+
+func (r *resequencer) emit(ctx context.Context, out chan<- ProcessedRecord, held heldRecord) bool {
+	wait := time.Since(held.since)
+	r.emitted++
+	r.waited += wait
+	if wait > r.maxWait {
+		r.maxWait = wait
+	}
+	r.telemetry.observeResequenceWait(wait, len(r.pending))
+	r.telemetry.recordStage(resequencerName, resultOut)
+	return send(ctx, out, held.record)
+}
+
+// seqHeap é um heap mínimo de sequências (ver container/heap).
+type seqHeap []uint64
+
+func (h seqHeap) Len() int            { return len(h) }
+func (h seqHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h seqHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *seqHeap) Push(x interface{}) { *h = append(*h, x.(uint64)) }
+func (h *seqHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResequenceRestoresSourceOrder(t *testing.T) {
+	var records []DataRecord
+	for i := 0; i < 300; i++ {
+		location := "north"
+		if i%7 == 3 {
+			location = ""
+		}
+		records = append(records, DataRecord{ID: fmt.Sprintf("r-%03d", i), Location: location})
+	}
+	cfg := DefaultConfig()
+	cfg.Pipeline.Workers = 4
+	cfg.Pipeline.ChannelBufferSize = 10
+	cfg.Pipeline.Resequence.Enabled = true
+	var active, peak int32
+	sink := &memorySink{}
+	p, err := NewBuilder(cfg).
+		WithSource(sliceSource{records: records}).
+		AddProcessor(jitterProcessor{name: "Jitter", active: &active, peak: &peak}).
+		AddProcessor(upperLocation{}).
+		AddSink(sink).
+		AddErrorSink(sink).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	metrics, _ := p.Run(context.Background())
+
+	if metrics.ProcessedCount != 257 || metrics.ErrorCount != 43 {
+		t.Fatalf("Expected 257 processed and 43 errors, got %d and %d", metrics.ProcessedCount, metrics.ErrorCount)
+	}
+	for i := 1; i < len(sink.processed); i++ {
+		if sink.processed[i].ID < sink.processed[i-1].ID {
+			t.Fatalf("Record %s arrived after %s", sink.processed[i].ID, sink.processed[i-1].ID)
+		}
+	}
+	if peak < 2 {
+		t.Errorf("Expected records to be processed in parallel, peak concurrency was %d", peak)
+	}
+}
+
+func TestResequencerBoundedBuffer(t *testing.T) {
+	telemetry := NewTelemetry()
+	r := newResequencer(2, telemetry, NewHealth(time.Minute))
+	in := make(chan ProcessedRecord, 10)
+	out := make(chan ProcessedRecord, 10)
+	// 1 atrasa além do buffer, 5 foi desviado para o caminho de erro e 7 nunca chega
+	r.skip(5)
+	for _, seq := range []uint64{2, 3, 4, 1, 6, 8} {
+		in <- ProcessedRecord{DataRecord: DataRecord{Seq: seq}}
+	}
+	close(in)
+	r.run(context.Background(), in, out)
+
+	var got []uint64
+	for record := range out {
+		got = append(got, record.Seq)
+	}
+	if fmt.Sprint(got) != "[2 3 4 1 6 8]" {
+		t.Errorf("Expected [2 3 4 1 6 8], got %v", got)
+	}
+	var buf bytes.Buffer
+	telemetry.writeMetrics(&buf)
+	for _, want := range []string{
+		"pipeline_resequencer_abandoned_gaps_total 1\n",
+		"pipeline_resequencer_late_records_total 1\n",
+		"pipeline_resequencer_wait_seconds_count 6\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in metrics output", want)
+		}
+	}
+}
+
+func TestResequenceConfigValidation(t *testing.T) {
+	if _, err := ParseConfig([]byte("pipeline:\n  resequence:\n    enabled: true\n    max_buffer: 0\n")); err == nil ||
+		!strings.Contains(err.Error(), "pipeline.resequence.max_buffer") {
+		t.Errorf("Expected max_buffer to be rejected, got %v", err)
+	}
+	if _, err := ParseConfig([]byte("pipeline:\n  resequence:\n    max_buffer: 0\n")); err != nil {
+		t.Errorf("Expected max_buffer to be ignored while disabled, got %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// transientError marca um erro como transitório: o registro pode ter sucesso em nova tentativa.
+type transientError struct {
+	err error
+}
+
+func (e transientError) Error() string   { return e.err.Error() }
+func (e transientError) Unwrap() error   { return e.err }
+func (e transientError) Transient() bool { return true }
+
+// Transient marca err como transitório, para que o Processor que o retornou seja
+// chamado novamente conforme retry. Erros não marcados são permanentes.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return transientError{err: err}
+}
+
+// IsTransient reporta se algum erro na cadeia de err implementa Transient() bool retornando true.
+// Cancelamentos de contexto nunca são transitórios.
+func IsTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var t interface{ Transient() bool }
+	return errors.As(err, &t) && t.Transient()
+}
+
+// AttemptError registra a falha de uma tentativa em DataRecord.ErrorHistory.
+type AttemptError struct {
+	Attempt   int       `json:"attempt"`
+	Stage     string    `json:"stage"`
+	Error     string    `json:"error"`
+	Transient bool      `json:"transient"`
+	At        time.Time `json:"at"`
+}
+
+// backoff calcula a espera antes da tentativa attempt+1, após attempt tentativas falharem.
+func backoff(cfg RetryConfig, attempt int) time.Duration {
+	delay := float64(cfg.InitialBackoff) * math.Pow(cfg.Multiplier, float64(attempt-1))
+	if max := float64(cfg.MaxBackoff); delay > max {
+		delay = max
+	}
+	if cfg.Jitter > 0 {
+		delay *= 1 + cfg.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
+
+// sleepContext espera d, retornando false se ctx for cancelado antes.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,124 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyProcessor falha de forma transitória nas primeiras failures tentativas de cada registro.
+// Registros com ID iniciado por "perm" falham de forma permanente.
+type flakyProcessor struct {
+	failures int
+	mu       sync.Mutex
+	calls    map[string]int
+}
+
+func (f *flakyProcessor) Name() string { return "Flaky" }
+
+func (f *flakyProcessor) Process(_ context.Context, record ProcessedRecord) (ProcessedRecord, error) {
+	if strings.HasPrefix(record.ID, "perm") {
+		return record, errors.New("bad record")
+	}
+	f.mu.Lock()
+	f.calls[record.ID]++
+	calls := f.calls[record.ID]
+	f.mu.Unlock()
+	if calls <= f.failures {
+		return record, Transient(fmt.Errorf("timeout on call %d", calls))
+	}
+	return record, nil
+}
+
+func runFlaky(t *testing.T, failures int, records ...DataRecord) (*memorySink, *Telemetry) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Retry = RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Multiplier: 2}
+	sink := &memorySink{}
+	p, err := NewBuilder(cfg).
+		WithSource(sliceSource{records: records}).
+		AddProcessor(&flakyProcessor{failures: failures, calls: make(map[string]int)}).
+		AddSink(sink).
+		AddErrorSink(sink).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	p.Run(context.Background())
+	return sink, p.Telemetry()
+}
+
+func TestRetrySucceedsAfterTransientFailures(t *testing.T) {
+	sink, telemetry := runFlaky(t, 2, DataRecord{ID: "r-1"})
+
+	if len(sink.processed) != 1 || len(sink.failed) != 0 {
+		t.Fatalf("Expected record to succeed on the third attempt, got %d processed and %d failed",
+			len(sink.processed), len(sink.failed))
+	}
+	record := sink.processed[0]
+	if record.Attempts != 3 || len(record.ErrorHistory) != 2 {
+		t.Errorf("Expected 3 attempts and 2 recorded failures, got %d and %+v", record.Attempts, record.ErrorHistory)
+	}
+	if h := record.ErrorHistory[1]; h.Attempt != 2 || h.Stage != "Flaky" || !h.Transient || h.Error != "timeout on call 2" {
+		t.Errorf("Unexpected history entry: %+v", h)
+	}
+	body := scrape(t, telemetry)
+	if !strings.Contains(body, `pipeline_stage_records_total{stage="Flaky",result="retry"} 2`) {
+		t.Errorf("Expected retries to be counted, got:\n%s", body)
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	sink, _ := runFlaky(t, 10, DataRecord{ID: "r-1"}, DataRecord{ID: "perm-1"})
+
+	if len(sink.failed) != 2 {
+		t.Fatalf("Expected both records to fail, got %+v", sink.failed)
+	}
+	for _, record := range sink.failed {
+		switch record.ID {
+		case "r-1":
+			if record.Attempts != 3 || len(record.ErrorHistory) != 3 || record.Error != "timeout on call 3" {
+				t.Errorf("Expected 3 transient attempts, got %+v", record)
+			}
+		case "perm-1":
+			if record.Attempts != 1 || len(record.ErrorHistory) != 1 || record.ErrorHistory[0].Transient {
+				t.Errorf("Expected a single permanent attempt, got %+v", record)
+			}
+		}
+		if record.Status != "failed" {
+			t.Errorf("Expected status failed, got %q", record.Status)
+		}
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	if !IsTransient(fmt.Errorf("wrapped: %w", Transient(errors.New("busy")))) {
+		t.Error("Expected wrapped transient error to be transient")
+	}
+	if IsTransient(errors.New("invalid")) || IsTransient(Transient(context.Canceled)) {
+		t.Error("Expected plain and cancellation errors to be permanent")
+	}
+	if Transient(nil) != nil {
+		t.Error("Expected Transient(nil) to be nil")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cfg := RetryConfig{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 5: time.Second} {
+		if got := backoff(cfg, attempt); got != want {
+			t.Errorf("backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+
+	cfg.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := backoff(cfg, 2); got < 100*time.Millisecond || got > 300*time.Millisecond {
+			t.Fatalf("Expected jittered backoff within ±50%% of 200ms, got %s", got)
+		}
+	}
+}
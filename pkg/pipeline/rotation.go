@@ -0,0 +1,506 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressões aceitas em output.rotation.compression.
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// RotationConfig controla a divisão dos arquivos de saída em segmentos. Um segmento é fechado
+// quando atinge MaxBytes ou MaxRecords, ou quando está aberto há Interval; com todos em zero,
+// a rotação fica desativada e cada execução sobrescreve o arquivo de saída.
+type RotationConfig struct {
+	MaxBytes    int64         `yaml:"max_bytes"`
+	MaxRecords  int           `yaml:"max_records"`
+	Interval    time.Duration `yaml:"interval"`
+	Compression string        `yaml:"compression"`  // none, gzip ou zstd, aplicada aos segmentos fechados
+	MaxSegments int           `yaml:"max_segments"` // Segmentos mantidos; 0 para ilimitado
+	MaxAge      time.Duration `yaml:"max_age"`      // Idade máxima de um segmento fechado; 0 para ilimitado
+}
+
+// Enabled reporta se algum critério de rotação foi configurado.
+func (c RotationConfig) Enabled() bool {
+	return c.MaxBytes > 0 || c.MaxRecords > 0 || c.Interval > 0
+}
+
+// SegmentInfo descreve um segmento fechado no manifesto.
+type SegmentInfo struct {
+	Seq         int       `json:"seq"`
+	File        string    `json:"file"` // Relativo ao diretório do manifesto
+	Records     int       `json:"records"`
+	Bytes       int64     `json:"bytes"`        // Tamanho sem compressão
+	StoredBytes int64     `json:"stored_bytes"` // Tamanho em disco
+	Compression string    `json:"compression"`
+	OpenedAt    time.Time `json:"opened_at"`
+	ClosedAt    time.Time `json:"closed_at"`
+}
+
+// Manifest lista os segmentos fechados de um arquivo de saída, do mais antigo ao mais recente.
+type Manifest struct {
+	NextSeq  int           `json:"next_seq"`
+	Segments []SegmentInfo `json:"segments"`
+}
+
+// ManifestPath retorna o caminho do manifesto de um arquivo de saída
+// (ex.: processed_data.jsonl -> processed_data.manifest.json).
+func ManifestPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".manifest.json"
+}
+
+// ReadManifest lê o manifesto de um arquivo de saída. Sem manifesto, retorna um manifesto vazio.
+func ReadManifest(path string) (Manifest, error) {
+	manifest := Manifest{NextSeq: 1}
+	data, err := os.ReadFile(ManifestPath(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return manifest, nil
+	}
+	if err != nil {
+		return manifest, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("manifesto %s inválido: %w", ManifestPath(path), err)
+	}
+	return manifest, nil
+}
+
+// recordWriter grava linhas JSONL de um Sink.
+type recordWriter interface {
+	// Write grava uma linha completa, terminada em '\n'.
+	Write(line []byte) error
+	// Tick fecha o segmento atual se o intervalo de rotação expirou.
+	Tick() error
+	// Sync grava em disco o que foi escrito até aqui.
+	Sync() error
+	// Close grava em disco o que foi escrito e libera o arquivo.
+	Close() error
+}
+
+// newRecordWriter cria o writer de path: um arquivo único sobrescrito a cada execução ou,
+// com rotação, segmentos registrados em manifesto. Com resume, o arquivo único recebe os
+// novos registros após os de uma execução anterior.
+func newRecordWriter(name, path string, cfg RotationConfig, resume bool) (recordWriter, error) {
+	if !cfg.Enabled() {
+		flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if resume {
+			flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		file, err := os.OpenFile(path, flag, 0o666)
+		if err != nil {
+			return nil, err
+		}
+		return &plainWriter{name: name, file: file}, nil
+	}
+	return openSegmentWriter(name, path, cfg)
+}
+
+// rotationTicker retorna o canal em que o Sink deve chamar Tick (nil sem rotação por tempo)
+// e a função que libera o ticker.
+func rotationTicker(cfg RotationConfig) (<-chan time.Time, func()) {
+	if !cfg.Enabled() || cfg.Interval <= 0 {
+		return nil, func() {}
+	}
+	period := time.Second
+	if cfg.Interval < period {
+		period = cfg.Interval
+	}
+	ticker := time.NewTicker(period)
+	return ticker.C, ticker.Stop
+}
+
+// plainWriter grava em um único arquivo.
+type plainWriter struct {
+	name string
+	file *os.File
+}
+
+func (w *plainWriter) Write(line []byte) error {
+	_, err := w.file.Write(line)
+	return err
+}
+
+func (w *plainWriter) Tick() error { return nil }
+
+func (w *plainWriter) Sync() error { return w.file.Sync() }
+
+func (w *plainWriter) Close() error {
+	// Garante que tudo o que foi escrito chegue ao disco, mesmo em caso de cancelamento
+	if err := w.file.Sync(); err != nil {
+		errorf("%s: Erro ao sincronizar %s: %v", w.name, w.file.Name(), err)
+	}
+	return w.file.Close()
+}
+
+// segmentWriter grava o segmento ativo em path e, ao fechá-lo, o renomeia para
+// <nome>-<seq>.<ext>, comprime, registra no manifesto e aplica a retenção.
+type segmentWriter struct {
+	name     string
+	path     string
+	cfg      RotationConfig
+	manifest Manifest
+	now      func() time.Time
+
+	file     *os.File
+	bytes    int64
+	records  int
+	openedAt time.Time
+}
+
+func openSegmentWriter(name, path string, cfg RotationConfig) (*segmentWriter, error) {
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	// Um segmento renomeado por uma execução interrompida antes de gravar o manifesto não está
+	// registrado nele; a numeração continua depois dele para não sobrescrevê-lo
+	last, err := lastSegmentSeq(path)
+	if err != nil {
+		return nil, err
+	}
+	if last >= manifest.NextSeq {
+		warnf("%s: Segmentos até %d não registrados no manifesto; numeração continua em %d", name, last, last+1)
+		manifest.NextSeq = last + 1
+	}
+	w := &segmentWriter{name: name, path: path, cfg: cfg, manifest: manifest, now: time.Now}
+
+	// Um segmento ativo deixado por uma execução interrompida é fechado antes de começar
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		records, err := countLines(path)
+		if err != nil {
+			return nil, err
+		}
+		w.bytes, w.records, w.openedAt = info.Size(), records, info.ModTime()
+		infof("%s: Fechando segmento deixado pela execução anterior (%d registros)", name, records)
+		if err := w.seal(); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// lastSegmentSeq retorna a maior sequência entre os segmentos de path presentes no diretório,
+// comprimidos ou não, ou 0 se não houver nenhum.
+func lastSegmentSeq(path string) (int, error) {
+	ext := filepath.Ext(path)
+	prefix := filepath.Base(strings.TrimSuffix(path, ext)) + "-"
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		return 0, err
+	}
+	last := 0
+	for _, entry := range entries {
+		rest, ok := strings.CutPrefix(entry.Name(), prefix)
+		if !ok {
+			continue
+		}
+		digits := rest
+		if i := strings.IndexByte(rest, '.'); i >= 0 {
+			digits = rest[:i]
+		}
+		seq, err := strconv.Atoi(digits)
+		if err != nil || !strings.HasPrefix(rest[len(digits):], ext) {
+			continue
+		}
+		last = max(last, seq)
+	}
+	return last, nil
+}
+
+func (w *segmentWriter) open() error {
+	file, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	w.file, w.bytes, w.records, w.openedAt = file, 0, 0, w.now()
+	return nil
+}
+
+func (w *segmentWriter) Write(line []byte) error {
+	n, err := w.file.Write(line)
+	w.bytes += int64(n)
+	if err != nil {
+		return err
+	}
+	w.records++
+	if (w.cfg.MaxBytes > 0 && w.bytes >= w.cfg.MaxBytes) || (w.cfg.MaxRecords > 0 && w.records >= w.cfg.MaxRecords) {
+		return w.rotate()
+	}
+	return nil
+}
+
+func (w *segmentWriter) Tick() error {
+	if w.cfg.Interval > 0 && w.records > 0 && w.now().Sub(w.openedAt) >= w.cfg.Interval {
+		return w.rotate()
+	}
+	return nil
+}
+
+// Sync grava em disco o segmento ativo; os segmentos fechados já foram sincronizados.
+func (w *segmentWriter) Sync() error { return w.file.Sync() }
+
+// rotate fecha o segmento ativo e abre o próximo. Mesmo se o fechamento falhar (disco cheio,
+// por exemplo), o arquivo ativo é reaberto, para que os próximos Write, Sync e Close não
+// falhem com o arquivo já fechado.
+func (w *segmentWriter) rotate() error {
+	err := w.closeActive()
+	if err == nil {
+		err = w.seal()
+	}
+	if openErr := w.reopen(); openErr != nil {
+		return errors.Join(err, openErr)
+	}
+	return err
+}
+
+// reopen volta a gravar no arquivo ativo após rotate. Se ele não chegou a virar segmento, os
+// registros continuam nele, acrescentados ao fim, e a próxima rotação tenta fechá-lo de novo;
+// caso contrário, um novo arquivo ativo é criado.
+func (w *segmentWriter) reopen() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND, 0o666)
+	if errors.Is(err, os.ErrNotExist) {
+		return w.open()
+	}
+	if err != nil {
+		return err
+	}
+	w.file = file
+	return nil
+}
+
+// Close fecha o segmento ativo; segmentos vazios são descartados.
+func (w *segmentWriter) Close() error {
+	if err := w.closeActive(); err != nil {
+		return err
+	}
+	if w.records == 0 {
+		return os.Remove(w.path)
+	}
+	return w.seal()
+}
+
+func (w *segmentWriter) closeActive() error {
+	if err := w.file.Sync(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// seal transforma o arquivo ativo (já fechado) em um segmento registrado no manifesto.
+func (w *segmentWriter) seal() error {
+	seq := w.manifest.NextSeq
+	ext := filepath.Ext(w.path)
+	segment := fmt.Sprintf("%s-%06d%s", strings.TrimSuffix(w.path, ext), seq, ext)
+	if err := os.Rename(w.path, segment); err != nil {
+		return err
+	}
+	// O número fica reservado mesmo se a compressão ou o manifesto falharem, para que o
+	// próximo segmento não sobrescreva este
+	w.manifest.NextSeq++
+
+	compression := w.cfg.Compression
+	if compression == "" {
+		compression = CompressionNone
+	}
+	stored, err := compressFile(segment, compression)
+	if err != nil {
+		return fmt.Errorf("falha ao comprimir %s: %w", segment, err)
+	}
+	info, err := os.Stat(stored)
+	if err != nil {
+		return err
+	}
+
+	w.manifest.Segments = append(w.manifest.Segments, SegmentInfo{
+		Seq:         seq,
+		File:        filepath.Base(stored),
+		Records:     w.records,
+		Bytes:       w.bytes,
+		StoredBytes: info.Size(),
+		Compression: compression,
+		OpenedAt:    w.openedAt,
+		ClosedAt:    w.now(),
+	})
+	infof("%s: Segmento %s fechado (%d registros)", w.name, filepath.Base(stored), w.records)
+	w.applyRetention()
+	return w.writeManifest()
+}
+
+// applyRetention remove os segmentos além de MaxSegments ou mais antigos que MaxAge.
+func (w *segmentWriter) applyRetention() {
+	dir := filepath.Dir(w.path)
+	cutoff := w.now().Add(-w.cfg.MaxAge)
+	kept := w.manifest.Segments[:0]
+	for i, segment := range w.manifest.Segments {
+		remaining := len(w.manifest.Segments) - i
+		expired := (w.cfg.MaxSegments > 0 && remaining > w.cfg.MaxSegments) ||
+			(w.cfg.MaxAge > 0 && segment.ClosedAt.Before(cutoff))
+		if !expired {
+			kept = append(kept, segment)
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, segment.File)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			warnf("%s: Falha ao remover segmento expirado %s: %v", w.name, segment.File, err)
+			kept = append(kept, segment)
+			continue
+		}
+		infof("%s: Segmento %s removido pela retenção", w.name, segment.File)
+	}
+	w.manifest.Segments = kept
+}
+
+// writeManifest grava o manifesto de forma atômica.
+func (w *segmentWriter) writeManifest() error {
+	data, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(ManifestPath(w.path), append(data, '\n'))
+}
+
+// writeFileAtomic grava data em um arquivo temporário e o renomeia para path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// compressFile comprime path conforme compression, removendo o original.
+// Retorna o caminho do arquivo resultante.
+func compressFile(path, compression string) (string, error) {
+	var ext string
+	var wrap func(io.Writer) (io.WriteCloser, error)
+	switch compression {
+	case CompressionNone:
+		return path, nil
+	case CompressionGzip:
+		ext = ".gz"
+		wrap = func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil }
+	case CompressionZstd:
+		ext = ".zst"
+		wrap = func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }
+	default:
+		return "", fmt.Errorf("compressão desconhecida %q", compression)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = src.Close() }()
+
+	target := path + ext
+	tmp := target + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return "", err
+	}
+	zw, err := wrap(dst)
+	if err == nil {
+		_, err = io.Copy(zw, src)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return "", err
+	}
+	return target, os.Remove(path)
+}
+
+// openRecordFile abre um arquivo JSONL, descomprimindo-o se terminar em .gz ou .zst.
+func openRecordFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	switch filepath.Ext(path) {
+	case ".gz":
+		zr, err := gzip.NewReader(bufio.NewReader(file))
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		return readCloser{Reader: zr, close: func() error { _ = zr.Close(); return file.Close() }}, nil
+	case ".zst":
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		return readCloser{Reader: zr, close: func() error { zr.Close(); return file.Close() }}, nil
+	}
+	return file, nil
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error { return r.close() }
+
+// countLines conta as linhas não vazias de path.
+func countLines(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+	count := 0
+	_, err = readLines(file, func(_ int, line []byte) bool {
+		if len(bytes.TrimSpace(line)) > 0 {
+			count++
+		}
+		return true
+	})
+	return count, err
+}
@@ -0,0 +1,243 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loadRecords(t *testing.T, sink LoaderSink, ids ...string) {
+	t.Helper()
+	in := make(chan ProcessedRecord, len(ids))
+	for _, id := range ids {
+		in <- ProcessedRecord{DataRecord: DataRecord{ID: id}}
+	}
+	close(in)
+	sink.Consume(context.Background(), in)
+}
+
+func segmentLines(t *testing.T, path string) []string {
+	t.Helper()
+	file, err := openRecordFile(path)
+	if err != nil {
+		t.Fatalf("Failed to open segment %s: %v", path, err)
+	}
+	defer file.Close()
+	var lines []string
+	if _, err := readLines(file, func(_ int, line []byte) bool {
+		lines = append(lines, strings.TrimSpace(string(line)))
+		return true
+	}); err != nil {
+		t.Fatalf("Failed to read segment %s: %v", path, err)
+	}
+	return lines
+}
+
+func TestRotationByRecordsWithGzip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "processed_data.jsonl")
+	sink := LoaderSink{Path: path, Rotation: RotationConfig{MaxRecords: 3, Compression: CompressionGzip}}
+
+	loadRecords(t, sink, "r1", "r2", "r3", "r4", "r5", "r6", "r7")
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected active segment to be sealed on close, stat error: %v", err)
+	}
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(manifest.Segments) != 3 || manifest.NextSeq != 4 {
+		t.Fatalf("Expected 3 segments, got %+v", manifest)
+	}
+	wantRecords := []int{3, 3, 1}
+	for i, segment := range manifest.Segments {
+		if segment.Seq != i+1 || segment.Records != wantRecords[i] || segment.Compression != CompressionGzip {
+			t.Errorf("Unexpected segment %d: %+v", i, segment)
+		}
+		if want := fmt.Sprintf("processed_data-%06d.jsonl.gz", i+1); segment.File != want {
+			t.Errorf("Expected segment file %s, got %s", want, segment.File)
+		}
+		lines := segmentLines(t, filepath.Join(dir, segment.File))
+		if len(lines) != wantRecords[i] {
+			t.Errorf("Expected %d records in %s, got %d", wantRecords[i], segment.File, len(lines))
+		}
+	}
+	if lines := segmentLines(t, filepath.Join(dir, manifest.Segments[2].File)); !strings.Contains(lines[0], `"id":"r7"`) {
+		t.Errorf("Expected r7 in the last segment, got %v", lines)
+	}
+}
+
+func TestRotationContinuesAcrossRunsWithRetention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "processed_data.jsonl")
+	sink := LoaderSink{Path: path, Rotation: RotationConfig{MaxRecords: 2, Compression: CompressionZstd, MaxSegments: 2}}
+
+	loadRecords(t, sink, "a1", "a2", "a3", "a4")
+	loadRecords(t, sink, "b1", "b2")
+
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(manifest.Segments) != 2 || manifest.Segments[0].Seq != 2 || manifest.Segments[1].Seq != 3 {
+		t.Fatalf("Expected segments 2 and 3 to be retained, got %+v", manifest.Segments)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "processed_data-000001.jsonl.zst")); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest segment to be deleted, stat error: %v", err)
+	}
+	if lines := segmentLines(t, filepath.Join(dir, manifest.Segments[1].File)); len(lines) != 2 || !strings.Contains(lines[0], `"id":"b1"`) {
+		t.Errorf("Expected second run in the newest segment, got %v", lines)
+	}
+}
+
+func TestRotationRecoversActiveSegment(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "failed_data.jsonl")
+	if err := os.WriteFile(path, []byte("{\"id\":\"old-1\"}\n{\"id\":\"old-2\"}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	in := make(chan DataRecord, 1)
+	in <- DataRecord{ID: "new-1"}
+	close(in)
+	ErrorHandlerSink{Path: path, Rotation: RotationConfig{MaxRecords: 10}}.ConsumeErrors(context.Background(), in)
+
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(manifest.Segments) != 2 || manifest.Segments[0].Records != 2 || manifest.Segments[1].Records != 1 {
+		t.Fatalf("Expected the leftover file to be sealed before new records, got %+v", manifest.Segments)
+	}
+}
+
+func TestRotationSkipsSegmentsMissingFromManifest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "processed_data.jsonl")
+	sink := LoaderSink{Path: path, Rotation: RotationConfig{MaxRecords: 10, Compression: CompressionGzip}}
+	loadRecords(t, sink, "r1")
+
+	// Simula uma execução interrompida após renomear o segmento 2 e antes de gravar o manifesto
+	orphan := filepath.Join(dir, "processed_data-000002.jsonl")
+	if err := os.WriteFile(orphan, []byte("{\"id\":\"orphan\"}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loadRecords(t, sink, "r2")
+
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(manifest.Segments) != 2 || manifest.Segments[1].Seq != 3 || manifest.NextSeq != 4 {
+		t.Fatalf("Expected the new segment to skip the unregistered one, got %+v", manifest)
+	}
+	if lines := segmentLines(t, orphan); len(lines) != 1 || !strings.Contains(lines[0], "orphan") {
+		t.Errorf("Expected the unregistered segment to be kept, got %v", lines)
+	}
+	if lines := segmentLines(t, filepath.Join(dir, manifest.Segments[1].File)); len(lines) != 1 || !strings.Contains(lines[0], "r2") {
+		t.Errorf("Expected the new segment to hold r2, got %v", lines)
+	}
+}
+
+func TestRotationKeepsWritingAfterSealFailure(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.jsonl")
+	w, err := openSegmentWriter("Test", path, RotationConfig{MaxRecords: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Um diretório no lugar do arquivo temporário faz a gravação do manifesto falhar
+	blocker := ManifestPath(path) + ".tmp"
+	if err := os.Mkdir(blocker, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]byte("{\"id\":\"a\"}\n")); err == nil {
+		t.Fatal("Expected the failed manifest write to be reported")
+	}
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]byte("{\"id\":\"b\"}\n")); err != nil {
+		t.Fatalf("Expected writes to continue after the failed rotation, got %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Unexpected close error: %v", err)
+	}
+
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(manifest.Segments) != 2 || manifest.Segments[1].Seq != 2 {
+		t.Fatalf("Expected both segments in the manifest, got %+v", manifest)
+	}
+	for i, id := range []string{"a", "b"} {
+		if lines := segmentLines(t, filepath.Join(dir, manifest.Segments[i].File)); len(lines) != 1 || !strings.Contains(lines[0], id) {
+			t.Errorf("Expected segment %d to hold %s, got %v", i+1, id, lines)
+		}
+	}
+}
+
+func TestRotationBySizeAndInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.jsonl")
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	w, err := openSegmentWriter("Test", path, RotationConfig{MaxBytes: 10, Interval: time.Minute, MaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	w.now = func() time.Time { return now }
+	w.openedAt = now
+
+	mustWrite := func(line string) {
+		if err := w.Write([]byte(line + "\n")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	mustWrite("12345678901") // Excede max_bytes: fecha o segmento 1
+	mustWrite("x")
+	if err := w.Tick(); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if err := w.Tick(); err != nil { // Intervalo expirado: fecha o segmento 2
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Hour)
+	mustWrite("y")
+	if err := w.Close(); err != nil { // Fecha o segmento 3; os anteriores expiram por max_age
+		t.Fatal(err)
+	}
+
+	manifest, err := ReadManifest(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(manifest.Segments) != 1 || manifest.Segments[0].Seq != 3 || manifest.NextSeq != 4 {
+		t.Fatalf("Expected only segment 3 to remain, got %+v", manifest)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out-000002.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("Expected expired segment to be deleted, stat error: %v", err)
+	}
+}
+
+func TestRotationConfigValidation(t *testing.T) {
+	for yaml, want := range map[string]string{
+		"output:\n  rotation:\n    max_records: 10\n    compression: lz4\n": "output.rotation.compression",
+		"output:\n  rotation:\n    max_segments: 3\n":                       "exigem max_bytes",
+		"output:\n  rotation:\n    interval: -1s\n":                         "output.rotation.interval",
+	} {
+		if _, err := ParseConfig([]byte(yaml)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got %v", want, err)
+		}
+	}
+	if _, err := ParseConfig([]byte("output:\n  rotation:\n    interval: 1h\n    compression: zstd\n    max_age: 168h\n")); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
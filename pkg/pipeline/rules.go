@@ -0,0 +1,354 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Códigos de erro estáveis gravados em DataRecord.ErrorCodes.
+const (
+	ErrCodeRequiredField    = "REQUIRED_FIELD"
+	ErrCodeValueOutOfRange  = "VALUE_OUT_OF_RANGE"
+	ErrCodePatternMismatch  = "PATTERN_MISMATCH"
+	ErrCodeValueNotAllowed  = "VALUE_NOT_ALLOWED"
+	ErrCodeFutureTimestamp  = "FUTURE_TIMESTAMP"
+	ErrCodeSensorOutOfRange = "SENSOR_VALUE_OUT_OF_RANGE"
+	ErrCodeInvalidUnit      = "INVALID_UNIT"
+	ErrCodeParseError       = "PARSE_ERROR"
+	ErrCodeLateRecord       = "LATE_RECORD"
+)
+
+// Tipos de regra aceitos em validator.rules[].type.
+const (
+	RuleRequired    = "required"
+	RuleRange       = "range"
+	RuleRegex       = "regex"
+	RuleAllowed     = "allowed"
+	RuleNotFuture   = "not_future"
+	RuleSensorRange = "sensor_range"
+)
+
+// RuleConfig declara uma regra de validação. Os campos usados dependem de Type:
+//   - required: Fields
+//   - range: Field (apenas "value"), Min e/ou Max
+//   - regex: Field, Pattern
+//   - allowed: Field, Values
+//   - not_future: Tolerance (tolerância para relógios adiantados)
+//   - sensor_range: Sensors (limites de Value por SensorID)
+//
+// Code substitui o código de erro padrão da regra.
+type RuleConfig struct {
+	Type      string                 `yaml:"type"`
+	Field     string                 `yaml:"field"`
+	Fields    []string               `yaml:"fields"`
+	Min       *float64               `yaml:"min"`
+	Max       *float64               `yaml:"max"`
+	Pattern   string                 `yaml:"pattern"`
+	Values    []string               `yaml:"values"`
+	Tolerance time.Duration          `yaml:"tolerance"`
+	Sensors   map[string]RangeConfig `yaml:"sensors"`
+	Code      string                 `yaml:"code"`
+}
+
+// RangeConfig define limites inclusivos para um valor numérico.
+type RangeConfig struct {
+	Min float64 `yaml:"min"`
+	Max float64 `yaml:"max"`
+}
+
+// Violation descreve uma regra que um registro não satisfez.
+type Violation struct {
+	Code    string
+	Field   string
+	Message string
+}
+
+// String formata a violação como "CODE: mensagem", o formato gravado em DataRecord.Error.
+func (v Violation) String() string {
+	return v.Code + ": " + v.Message
+}
+
+// Rule valida um aspecto de um registro, retornando todas as violações encontradas.
+type Rule interface {
+	Check(record DataRecord) []Violation
+}
+
+// recordFields lista os campos de DataRecord que as regras podem referenciar.
+var recordFields = []string{"id", "timestamp", "sensor_id", "value", "unit", "location", "status"}
+
+// stringFields lista os campos textuais aceitos pelas regras regex e allowed.
+var stringFields = []string{"id", "sensor_id", "unit", "location", "status"}
+
+// stringField retorna o valor de um campo textual de record.
+func stringField(record DataRecord, field string) string {
+	switch field {
+	case "id":
+		return record.ID
+	case "sensor_id":
+		return record.SensorID
+	case "unit":
+		return record.Unit
+	case "location":
+		return record.Location
+	case "status":
+		return record.Status
+	}
+	return ""
+}
+
+// CompileRules constrói as regras descritas em cfg: min_value/max_value e required_fields
+// viram regras implícitas, seguidas de cfg.Rules na ordem declarada.
+func CompileRules(cfg ValidatorConfig) ([]Rule, error) {
+	var rules []Rule
+	var problems []string
+
+	if len(cfg.RequiredFields) > 0 {
+		rule, err := newRequiredRule(cfg.RequiredFields, "")
+		if err != nil {
+			problems = append(problems, "validator.required_fields: "+err.Error())
+		} else {
+			rules = append(rules, rule)
+		}
+	}
+	rules = append(rules, rangeRule{min: cfg.MinValue, max: cfg.MaxValue, code: ErrCodeValueOutOfRange})
+
+	for i, rc := range cfg.Rules {
+		rule, err := compileRule(rc)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("validator.rules[%d] (%s): %v", i, rc.Type, err))
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "\n  - "))
+	}
+	return rules, nil
+}
+
+// compileRule constrói uma única regra a partir de sua declaração.
+func compileRule(rc RuleConfig) (Rule, error) {
+	codeOr := func(def string) string {
+		if rc.Code != "" {
+			return rc.Code
+		}
+		return def
+	}
+
+	switch rc.Type {
+	case RuleRequired:
+		return newRequiredRule(rc.Fields, rc.Code)
+	case RuleRange:
+		if rc.Field != "value" {
+			return nil, fmt.Errorf("field deve ser \"value\" (atual: %q)", rc.Field)
+		}
+		if rc.Min == nil && rc.Max == nil {
+			return nil, fmt.Errorf("min ou max é obrigatório")
+		}
+		rule := rangeRule{min: math.Inf(-1), max: math.Inf(1), code: codeOr(ErrCodeValueOutOfRange)}
+		if rc.Min != nil {
+			rule.min = *rc.Min
+		}
+		if rc.Max != nil {
+			rule.max = *rc.Max
+		}
+		if rule.min > rule.max {
+			return nil, fmt.Errorf("min (%g) deve ser <= max (%g)", rule.min, rule.max)
+		}
+		return rule, nil
+	case RuleRegex:
+		if !containsString(stringFields, rc.Field) {
+			return nil, fmt.Errorf("field deve ser um de %s (atual: %q)", strings.Join(stringFields, ", "), rc.Field)
+		}
+		re, err := regexp.Compile(rc.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern inválido: %v", err)
+		}
+		return regexRule{field: rc.Field, re: re, code: codeOr(ErrCodePatternMismatch)}, nil
+	case RuleAllowed:
+		if !containsString(stringFields, rc.Field) {
+			return nil, fmt.Errorf("field deve ser um de %s (atual: %q)", strings.Join(stringFields, ", "), rc.Field)
+		}
+		if len(rc.Values) == 0 {
+			return nil, fmt.Errorf("values não pode ser vazio")
+		}
+		return allowedRule{field: rc.Field, values: rc.Values, code: codeOr(ErrCodeValueNotAllowed)}, nil
+	case RuleNotFuture:
+		if rc.Tolerance < 0 {
+			return nil, fmt.Errorf("tolerance deve ser >= 0 (atual: %s)", rc.Tolerance)
+		}
+		return notFutureRule{tolerance: rc.Tolerance, now: time.Now, code: codeOr(ErrCodeFutureTimestamp)}, nil
+	case RuleSensorRange:
+		if len(rc.Sensors) == 0 {
+			return nil, fmt.Errorf("sensors não pode ser vazio")
+		}
+		for sensor, r := range rc.Sensors {
+			if r.Min > r.Max {
+				return nil, fmt.Errorf("sensors.%s: min (%g) deve ser <= max (%g)", sensor, r.Min, r.Max)
+			}
+		}
+		return sensorRangeRule{sensors: rc.Sensors, code: codeOr(ErrCodeSensorOutOfRange)}, nil
+	default:
+		return nil, fmt.Errorf("type deve ser um de %s",
+			strings.Join([]string{RuleRequired, RuleRange, RuleRegex, RuleAllowed, RuleNotFuture, RuleSensorRange}, ", "))
+	}
+}
+
+// requiredRule exige que os campos listados estejam preenchidos.
+type requiredRule struct {
+	fields []string
+	code   string
+}
+
+func newRequiredRule(fields []string, code string) (Rule, error) {
+	for _, field := range fields {
+		if !containsString(recordFields, field) {
+			return nil, fmt.Errorf("campo desconhecido %q (aceitos: %s)", field, strings.Join(recordFields, ", "))
+		}
+	}
+	if code == "" {
+		code = ErrCodeRequiredField
+	}
+	return requiredRule{fields: fields, code: code}, nil
+}
+
+func (r requiredRule) Check(record DataRecord) []Violation {
+	var violations []Violation
+	for _, field := range r.fields {
+		var missing bool
+		switch field {
+		case "timestamp":
+			missing = record.Timestamp.IsZero()
+		case "value":
+			// Zero é um valor legítimo; apenas valores não numéricos contam como ausentes
+			missing = math.IsNaN(record.Value) || math.IsInf(record.Value, 0)
+		default:
+			missing = strings.TrimSpace(stringField(record, field)) == ""
+		}
+		if missing {
+			violations = append(violations, Violation{Code: r.code, Field: field, Message: field + " is required"})
+		}
+	}
+	return violations
+}
+
+// rangeRule exige que Value esteja no intervalo inclusivo [min, max].
+type rangeRule struct {
+	min, max float64
+	code     string
+}
+
+func (r rangeRule) Check(record DataRecord) []Violation {
+	if record.Value >= r.min && record.Value <= r.max {
+		return nil
+	}
+	return []Violation{{
+		Code:    r.code,
+		Field:   "value",
+		Message: fmt.Sprintf("Value out of expected range (%g-%g)", r.min, r.max),
+	}}
+}
+
+// regexRule exige que um campo textual satisfaça uma expressão regular.
+type regexRule struct {
+	field string
+	re    *regexp.Regexp
+	code  string
+}
+
+func (r regexRule) Check(record DataRecord) []Violation {
+	value := stringField(record, r.field)
+	if r.re.MatchString(value) {
+		return nil
+	}
+	return []Violation{{
+		Code:    r.code,
+		Field:   r.field,
+		Message: fmt.Sprintf("%s %q does not match %s", r.field, value, r.re.String()),
+	}}
+}
+
+// allowedRule exige que um campo textual esteja em uma lista de valores.
+type allowedRule struct {
+	field  string
+	values []string
+	code   string
+}
+
+func (r allowedRule) Check(record DataRecord) []Violation {
+	value := stringField(record, r.field)
+	if containsString(r.values, value) {
+		return nil
+	}
+	return []Violation{{
+		Code:    r.code,
+		Field:   r.field,
+		Message: fmt.Sprintf("%s %q is not one of [%s]", r.field, value, strings.Join(r.values, ", ")),
+	}}
+}
+
+// notFutureRule rejeita registros com Timestamp além de agora + tolerance.
+type notFutureRule struct {
+	tolerance time.Duration
+	now       func() time.Time
+	code      string
+}
+
+func (r notFutureRule) Check(record DataRecord) []Violation {
+	limit := r.now().Add(r.tolerance)
+	if !record.Timestamp.After(limit) {
+		return nil
+	}
+	return []Violation{{
+		Code:    r.code,
+		Field:   "timestamp",
+		Message: fmt.Sprintf("timestamp %s is in the future", record.Timestamp.Format(time.RFC3339)),
+	}}
+}
+
+// sensorRangeRule aplica limites de Value específicos por SensorID.
+// Sensores não listados não são verificados.
+type sensorRangeRule struct {
+	sensors map[string]RangeConfig
+	code    string
+}
+
+func (r sensorRangeRule) Check(record DataRecord) []Violation {
+	limits, ok := r.sensors[record.SensorID]
+	if !ok || (record.Value >= limits.Min && record.Value <= limits.Max) {
+		return nil
+	}
+	return []Violation{{
+		Code:  r.code,
+		Field: "value",
+		Message: fmt.Sprintf("Value %g out of range for %s (%g-%g)",
+			record.Value, record.SensorID, limits.Min, limits.Max),
+	}}
+}
+
+// applyViolations grava as violações em record.Error e record.ErrorCodes.
+// Os códigos são únicos e ordenados; as mensagens seguem a ordem das regras.
+func applyViolations(record *DataRecord, violations []Violation) {
+	messages := make([]string, len(violations))
+	seen := make(map[string]bool)
+	var codes []string
+	for i, v := range violations {
+		messages[i] = v.String()
+		if !seen[v.Code] {
+			seen[v.Code] = true
+			codes = append(codes, v.Code)
+		}
+	}
+	sort.Strings(codes)
+	record.Error = strings.Join(messages, "; ")
+	record.ErrorCodes = codes
+}
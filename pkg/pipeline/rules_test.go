@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func floatPtr(v float64) *float64 { return &v }
+
+func validRecord() DataRecord {
+	return DataRecord{
+		ID:        "rule-1",
+		Timestamp: time.Now(),
+		SensorID:  "sensor-1",
+		Value:     50,
+		Unit:      "unit_A",
+		Location:  "North",
+		Status:    "raw",
+	}
+}
+
+func TestValidatorProcessorReportsAllViolations(t *testing.T) {
+	cfg := DefaultConfig().Validator
+	cfg.Rules = []RuleConfig{
+		{Type: RuleRegex, Field: "sensor_id", Pattern: `^sensor-[0-9]+$`},
+		{Type: RuleAllowed, Field: "unit", Values: []string{"unit_A", "unit_B"}},
+		{Type: RuleNotFuture, Tolerance: time.Minute},
+		{Type: RuleSensorRange, Sensors: map[string]RangeConfig{"sensor-1": {Min: 0, Max: 40}}},
+	}
+	validator, err := NewValidatorProcessor(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := validator.Process(context.Background(), ProcessedRecord{DataRecord: func() DataRecord {
+		r := validRecord()
+		r.Value = 30
+		return r
+	}()}); err != nil {
+		t.Errorf("Expected valid record to pass, got: %v", err)
+	}
+
+	record := validRecord()
+	record.SensorID = "probe-9"
+	record.Unit = "unit_Z"
+	record.Location = ""
+	record.Value = 2000
+	record.Timestamp = time.Now().Add(time.Hour)
+
+	result, err := validator.Process(context.Background(), ProcessedRecord{DataRecord: record})
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	if result.Status != "invalid" {
+		t.Errorf("Expected status invalid, got %s", result.Status)
+	}
+	expectedCodes := []string{
+		ErrCodeFutureTimestamp, ErrCodePatternMismatch, ErrCodeRequiredField, ErrCodeValueNotAllowed, ErrCodeValueOutOfRange,
+	}
+	if strings.Join(result.ErrorCodes, ",") != strings.Join(expectedCodes, ",") {
+		t.Errorf("Expected codes %v, got %v", expectedCodes, result.ErrorCodes)
+	}
+	for _, code := range expectedCodes {
+		if !strings.Contains(result.Error, code+": ") {
+			t.Errorf("Expected error message to contain %s, got %q", code, result.Error)
+		}
+	}
+}
+
+func TestSensorRangeRule(t *testing.T) {
+	cfg := DefaultConfig().Validator
+	cfg.Rules = []RuleConfig{
+		{Type: RuleSensorRange, Sensors: map[string]RangeConfig{"sensor-2": {Min: 10, Max: 20}}},
+	}
+	validator, err := NewValidatorProcessor(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	record := validRecord()
+	record.SensorID = "sensor-2"
+	record.Value = 25
+	result, err := validator.Process(context.Background(), ProcessedRecord{DataRecord: record})
+	if err == nil || len(result.ErrorCodes) != 1 || result.ErrorCodes[0] != ErrCodeSensorOutOfRange {
+		t.Errorf("Expected %s, got %v (%v)", ErrCodeSensorOutOfRange, result.ErrorCodes, err)
+	}
+
+	record.SensorID = "sensor-3" // Sem limites específicos
+	if _, err := validator.Process(context.Background(), ProcessedRecord{DataRecord: record}); err != nil {
+		t.Errorf("Sensors without specific limits should pass, got: %v", err)
+	}
+}
+
+func TestRuleCodeOverride(t *testing.T) {
+	cfg := DefaultConfig().Validator
+	cfg.Rules = []RuleConfig{{Type: RuleRange, Field: "value", Max: floatPtr(10), Code: "TOO_HIGH"}}
+	validator, err := NewValidatorProcessor(cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, _ := validator.Process(context.Background(), ProcessedRecord{DataRecord: validRecord()})
+	if len(result.ErrorCodes) != 1 || result.ErrorCodes[0] != "TOO_HIGH" {
+		t.Errorf("Expected custom code TOO_HIGH, got %v", result.ErrorCodes)
+	}
+}
+
+func TestCompileRulesErrors(t *testing.T) {
+	cfg := DefaultConfig().Validator
+	cfg.RequiredFields = []string{"id", "colour"}
+	cfg.Rules = []RuleConfig{
+		{Type: RuleRegex, Field: "sensor_id", Pattern: "("},
+		{Type: RuleRange, Field: "location", Min: floatPtr(0)},
+		{Type: "checksum"},
+	}
+	_, err := CompileRules(cfg)
+	if err == nil {
+		t.Fatal("Expected compile error")
+	}
+	for _, want := range []string{"colour", "validator.rules[0]", "validator.rules[1]", "validator.rules[2]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got: %v", want, err)
+		}
+	}
+}
+
+func TestParseConfigRules(t *testing.T) {
+	yaml := `
+validator:
+  rules:
+    - type: regex
+      field: location
+      pattern: "^[A-Z][a-z]+$"
+    - type: not_future
+      tolerance: 30s
+    - type: sensor_range
+      sensors:
+        sensor-1: {min: 0, max: 100}
+`
+	cfg, err := ParseConfig([]byte(yaml))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(cfg.Validator.Rules) != 3 {
+		t.Fatalf("Expected 3 rules, got %d", len(cfg.Validator.Rules))
+	}
+	if cfg.Validator.Rules[1].Tolerance != 30*time.Second {
+		t.Errorf("Expected tolerance 30s, got %s", cfg.Validator.Rules[1].Tolerance)
+	}
+	if _, err := ParseConfig([]byte("validator:\n  rules:\n    - type: regex\n      field: sensor_id\n      pattern: \"[\"\n")); err == nil {
+		t.Error("Expected invalid regex to be rejected at load time")
+	}
+}
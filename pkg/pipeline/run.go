@@ -5,130 +5,672 @@
 package pipeline
 
 import (
+	"context"
+	"fmt"
+	"hash/fnv"
 	"log"
 	"sync"
+	"time"
 )
 
+// RunAdvancedPipeline executa a pipeline com a configuração padrão,
+// substituindo apenas o número de registros e de workers.
 func RunAdvancedPipeline(numRecords int, numWorkers int) Metrics {
-	// Canais para comunicação entre as etapas
-	dataCh := make(chan DataRecord, 100)       // Producer -> Validator
-	validCh := make(chan DataRecord, 100)      // Validator -> Transformer
-	processedCh := make(chan ProcessedRecord, 100) // Transformer -> Fan-out
-	errorCh := make(chan DataRecord, 100)      // Erros de Validator ou Transformer -> Fan-out
-	
-	// Canais dedicados para cada consumidor
-	loaderCh := make(chan ProcessedRecord, 100)
-	errorHandlerCh := make(chan DataRecord, 100)
-	metricsProcessedCh := make(chan ProcessedRecord, 100)
-	metricsErrorCh := make(chan DataRecord, 100)
+	cfg := DefaultConfig()
+	cfg.Pipeline.NumRecords = numRecords
+	cfg.Pipeline.Workers = numWorkers
+	metrics, _ := RunPipeline(context.Background(), cfg)
+	return metrics
+}
+
+// RunPipeline executa a pipeline padrão descrita por cfg (ver DefaultBuilder) e retorna as
+// métricas coletadas junto com o motivo da parada.
+func RunPipeline(ctx context.Context, cfg Config) (Metrics, StopReason) {
+	p, err := DefaultBuilder(cfg).Build()
+	if err != nil {
+		log.Fatalf("Pipeline: Configuração inválida: %v", err)
+	}
+	return p.Run(ctx)
+}
+
+// Run executa todas as etapas da pipeline e retorna as métricas coletadas
+// junto com o motivo da parada.
+//
+// Quando ctx é cancelado, a Source deixa de gerar registros e as demais etapas drenam os
+// registros em trânsito por até advanced.shutdown_timeout segundos (0 espera sem limite).
+// Esgotado o prazo, as etapas são abortadas e as métricas refletem apenas o que foi concluído.
+func (p *Pipeline) Run(ctx context.Context) (Metrics, StopReason) {
+	// abortCtx interrompe todas as etapas quando a drenagem excede o prazo
+	abortCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+
+	telemetry := p.telemetry
+	health := p.health
+	health.setState(StateRunning)
+	numWorkers := p.cfg.Pipeline.Workers
 
 	var wg sync.WaitGroup // Main WaitGroup for all goroutines
 
-	// WaitGroups para coordenar o fechamento dos canais
-	var validatorWg sync.WaitGroup
-	var transformerWg sync.WaitGroup
-	var errorWg sync.WaitGroup // Para goroutines que escrevem em errorCh (Validators e Transformers)
+	// Com source.checkpoint, a leitura continua após a última linha gravada em disco e os
+	// Sinks duráveis confirmam cada registro gravado: os DurableSinks após cada fsync ou, com
+	// exactly_once, os TransactionalSinks a cada segmento preparado
+	source := p.source
+	var tracker *checkpointTracker
+	var durability Durability
+	var txn Transaction
+	exactlyOnce := p.cfg.Source.Checkpoint.ExactlyOnce
+	if cp := p.cfg.Source.Checkpoint; cp.Path != "" {
+		var err error
+		tracker, source, err = openCheckpoint(cp, p.source.(ResumableSource))
+		if err != nil {
+			log.Fatalf("Pipeline: Falha ao ler checkpoint: %v", err)
+		}
+		durability = Durability{Resume: tracker.resumed(), Interval: cp.Interval, Commit: tracker.commit}
+		txn = Transaction{Interval: cp.Interval, Prepare: tracker.prepared}
+	}
+	acknowledges := func(sink any) bool {
+		if tracker == nil {
+			return false
+		}
+		if exactlyOnce {
+			_, ok := sink.(TransactionalSink)
+			_, errorOK := sink.(TransactionalErrorSink)
+			return ok || errorOK
+		}
+		_, ok := sink.(DurableSink)
+		_, errorOK := sink.(DurableErrorSink)
+		return ok || errorOK
+	}
+	durableSinks, durableErrorSinks := 0, 0
+	for _, sink := range p.sinks {
+		if acknowledges(sink) {
+			durableSinks++
+		}
+	}
+	for _, sink := range p.errorSinks {
+		if acknowledges(sink) {
+			durableErrorSinks++
+		}
+	}
+
+	// Com pipeline.resequence.enabled, os registros voltam à ordem da Source antes do fan-out;
+	// os registros com erro ou descartados antes dele apenas liberam suas sequências
+	var reseq *resequencer
+	if p.cfg.Pipeline.Resequence.Enabled {
+		reseq = newResequencer(p.cfg.Pipeline.Resequence.MaxBuffer, telemetry, health)
+	}
+	skip := func(seq uint64) {
+		if reseq != nil {
+			reseq.skip(seq)
+		}
+	}
+	// Um registro descartado pelo backpressure antes do fan-out libera sua sequência e não
+	// espera confirmação de nenhum Sink
+	skipProcessed := func(record ProcessedRecord) {
+		skip(record.Seq)
+		tracker.routed(record.Seq, 0)
+	}
+	skipFailed := func(record DataRecord) {
+		skip(record.Seq)
+		tracker.routed(record.Seq, 0)
+	}
 
-	// 1. Producer
+	// Canais para comunicação entre as etapas, cada um com sua política de backpressure
+	edges := newEdgeSet(abortCtx, &wg, p.cfg, telemetry)
+	data := newEdge[DataRecord](edges, "dataCh", skipFailed)                   // Source -> primeiro Processor
+	processed := newEdge[ProcessedRecord](edges, "processedCh", skipProcessed) // Último Processor -> Fan-out
+	errs := newEdge[DataRecord](edges, "errorCh", skipFailed)                  // Erros da Source ou dos Processors -> Fan-out
+
+	// Canais dedicados para cada consumidor; um registro descartado no canal de um Sink
+	// durável conta como confirmado por ele
+	sinkEdges := make([]*edge[ProcessedRecord], len(p.sinks))
+	for i, sink := range p.sinks {
+		var onDrop func(ProcessedRecord)
+		if acknowledges(sink) {
+			onDrop = func(record ProcessedRecord) { tracker.commit([]uint64{record.Seq}) }
+		}
+		sinkEdges[i] = newEdge[ProcessedRecord](edges, channelName(sink.Name()), onDrop)
+	}
+	errorSinkEdges := make([]*edge[DataRecord], len(p.errorSinks))
+	for i, sink := range p.errorSinks {
+		var onDrop func(DataRecord)
+		if acknowledges(sink) {
+			onDrop = func(record DataRecord) { tracker.commit([]uint64{record.Seq}) }
+		}
+		errorSinkEdges[i] = newEdge[DataRecord](edges, channelName(sink.Name()), onDrop)
+	}
+	metricsProcessed := newEdge[ProcessedRecord](edges, "metricsProcessedCh", nil)
+	metricsErrors := newEdge[DataRecord](edges, "metricsErrorCh", nil)
+
+	// Etapas acompanhadas pelas sondas de saúde, com os registros pendentes em sua entrada
+	health.registerStage(source.Name(), nil)
+	for i, sink := range p.sinks {
+		health.registerStage(sink.Name(), sinkEdges[i].depth)
+	}
+	for i, sink := range p.errorSinks {
+		health.registerStage(sink.Name(), errorSinkEdges[i].depth)
+	}
+
+	// WaitGroup para goroutines que escrevem em errorCh (Source e Processors)
+	var errorWg sync.WaitGroup
+
+	// 1. Source
+	sourceName := source.Name()
+	sourceOut := make(chan DataRecord)
+	sourceErrCh := make(chan DataRecord)
 	wg.Add(1)
+	health.workerStarted(sourceName)
 	go func() {
 		defer wg.Done()
-		Producer(dataCh, numRecords)
+		defer health.workerStopped(sourceName)
+		defer close(sourceErrCh)
+		defer close(sourceOut)
+		source.Run(ctx, sourceOut, sourceErrCh)
 	}()
 
-	// 2. Validators
-	for i := 0; i < numWorkers; i++ {
-		wg.Add(1)
-		validatorWg.Add(1)
-		errorWg.Add(1) // Validators escrevem em errorCh
-		go func() {
-			defer wg.Done()
-			defer validatorWg.Done()
-			defer errorWg.Done()
-			Validator(dataCh, validCh, errorCh)
-		}()
+	// Numera os registros e os erros da Source na ordem de leitura (DataRecord.Seq) e os
+	// encaminha para dataCh e errorCh. Após o aborto continua drenando, para que a Source
+	// nunca fique bloqueada.
+	wg.Add(1)
+	errorWg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer errorWg.Done()
+		defer close(data.in)
+		var seq uint64
+		out, errIn := sourceOut, sourceErrCh
+		for out != nil || errIn != nil {
+			select {
+			case record, ok := <-out:
+				if !ok {
+					out = nil
+					continue
+				}
+				seq++
+				record.Seq = seq
+				tracker.emitted(seq, record.SourceLine)
+				telemetry.recordStage(sourceName, resultOut)
+				health.touch(sourceName)
+				send(abortCtx, data.in, record)
+			case record, ok := <-errIn:
+				if !ok {
+					errIn = nil
+					continue
+				}
+				seq++
+				record.Seq = seq
+				tracker.emitted(seq, record.SourceLine)
+				telemetry.recordStage(sourceName, resultError)
+				health.touch(sourceName)
+				send(abortCtx, errs.in, record)
+			}
+		}
+	}()
+
+	// Com pipeline.partition_key, cada etapa tem uma faixa (canal e worker) por worker e cada
+	// registro segue sempre pela faixa de sua chave, preservando a ordem por chave até os Sinks.
+	// Sem ela, os workers de cada etapa disputam um único canal.
+	keyOf := partitionFields[p.cfg.Pipeline.PartitionKey]
+	lanes, workersPerLane := 1, numWorkers
+	if keyOf != nil {
+		lanes, workersPerLane = numWorkers, 1
 	}
 
-	// Goroutine para fechar validCh após todos os validators terminarem
+	// Adapta a saída da Source para a entrada do primeiro Processor
+	sourceChs := make([]chan ProcessedRecord, lanes)
+	for l := range sourceChs {
+		sourceChs[l] = make(chan ProcessedRecord)
+	}
+	wg.Add(1)
 	go func() {
-		validatorWg.Wait()
-		close(validCh)
+		defer wg.Done()
+		defer func() {
+			for _, ch := range sourceChs {
+				close(ch)
+			}
+		}()
+		for record := range data.out {
+			lane := 0
+			if keyOf != nil {
+				lane = keyLane(keyOf(record), lanes)
+			}
+			send(abortCtx, sourceChs[lane], ProcessedRecord{DataRecord: record})
+		}
 	}()
 
-	// 3. Transformers
-	for i := 0; i < numWorkers; i++ {
+	// 2. Processors, cada um com seu próprio grupo de workers e canais de saída
+	processors := p.processors
+	if len(processors) == 0 {
+		processors = []Processor{passThrough{}}
+	}
+	stageIns := sourceChs
+	stageDepth := data.depth // sourceChs não têm buffer; os pendentes ficam em dataCh
+	for i, processor := range processors {
+		health.registerStage(processor.Name(), stageDepth)
+		last := i == len(processors)-1
+		var stageEdges []*edge[ProcessedRecord]
+		stageOuts := make([]chan ProcessedRecord, lanes) // Entradas das faixas da próxima etapa
+		nextIns := make([]chan ProcessedRecord, lanes)   // Saídas das faixas da próxima etapa
+		for l := range stageOuts {
+			if last {
+				stageOuts[l] = processed.in // As faixas se juntam antes do fan-out
+				continue
+			}
+			name := channelName(processors[i+1].Name())
+			if lanes > 1 {
+				name = fmt.Sprintf("%s[%d]", name, l)
+			}
+			e := newEdge[ProcessedRecord](edges, name, skipProcessed)
+			stageEdges = append(stageEdges, e)
+			stageOuts[l], nextIns[l] = e.in, e.out
+		}
+		if !last {
+			stageDepth = lanesDepth(stageEdges)
+		}
+
+		var stageWg sync.WaitGroup
+		for l := range stageIns {
+			for w := 0; w < workersPerLane; w++ {
+				wg.Add(1)
+				stageWg.Add(1)
+				errorWg.Add(1) // Processors escrevem em errorCh
+				health.workerStarted(processor.Name())
+				go func(processor Processor, in <-chan ProcessedRecord, out chan<- ProcessedRecord) {
+					defer wg.Done()
+					defer stageWg.Done()
+					defer errorWg.Done()
+					defer health.workerStopped(processor.Name())
+					runner := stageRunner{processor: processor, retry: p.cfg.Retry, telemetry: telemetry, health: health}
+					runner.run(abortCtx, in, out, errs.in)
+				}(processor, stageIns[l], stageOuts[l])
+			}
+		}
+
+		// Goroutine para fechar os canais de saída após todos os workers da etapa terminarem
+		go func(stageWg *sync.WaitGroup, outs []chan ProcessedRecord, last bool) {
+			stageWg.Wait()
+			if last {
+				close(processed.in)
+				return
+			}
+			for _, out := range outs {
+				close(out)
+			}
+		}(&stageWg, stageOuts, last)
+		stageIns = nextIns
+	}
+
+	fanOutIn, fanOutDepth := processed.out, processed.depth
+	if reseq != nil {
+		ordered := newEdge[ProcessedRecord](edges, "orderedCh", func(record ProcessedRecord) {
+			tracker.routed(record.Seq, 0)
+		})
+		health.registerStage(resequencerName, processed.depth)
 		wg.Add(1)
-		transformerWg.Add(1)
-		errorWg.Add(1) // Transformers escrevem em errorCh
+		health.workerStarted(resequencerName)
 		go func() {
 			defer wg.Done()
-			defer transformerWg.Done()
-			defer errorWg.Done()
-			Transformer(validCh, processedCh, errorCh)
+			defer health.workerStopped(resequencerName)
+			reseq.run(abortCtx, processed.out, ordered.in)
 		}()
+		fanOutIn = ordered.out
+		fanOutDepth = ordered.depth
 	}
 
-	// Goroutine para fechar processedCh após todos os transformers terminarem
-	go func() {
-		transformerWg.Wait()
-		close(processedCh)
-	}()
+	// Com watermark.enabled, os registros além do atraso permitido deixam o fluxo antes do
+	// fan-out: são descartados, vão para o LateSink ou para errorCh. Nos dois primeiros casos
+	// não esperam confirmação de nenhum Sink.
+	if wm := p.cfg.Watermark; wm.Enabled {
+		release := func(record ProcessedRecord) { tracker.routed(record.Seq, 0) }
+		timely := newEdge[ProcessedRecord](edges, "timelyCh", release)
+		stage := watermarkStage{
+			cfg:       wm,
+			telemetry: telemetry,
+			health:    health,
+			release:   func(record DataRecord) { tracker.routed(record.Seq, 0) },
+		}
+		var lateIn chan DataRecord
+		switch wm.LatePolicy {
+		case LateError:
+			stage.late = errs.in
+			errorWg.Add(1)
+		case LateSideOutput:
+			late := newEdge[DataRecord](edges, channelName(p.lateSink.Name()), nil)
+			lateIn, stage.late = late.in, late.in
+			health.registerStage(p.lateSink.Name(), late.depth)
+			wg.Add(1)
+			health.workerStarted(p.lateSink.Name())
+			go func() {
+				defer wg.Done()
+				defer health.workerStopped(p.lateSink.Name())
+				p.lateSink.ConsumeErrors(abortCtx, late.out)
+			}()
+		}
+		health.registerStage(watermarkName, fanOutDepth)
+		wg.Add(1)
+		health.workerStarted(watermarkName)
+		go func(in <-chan ProcessedRecord) {
+			defer wg.Done()
+			defer health.workerStopped(watermarkName)
+			stage.run(abortCtx, in, timely.in)
+			if lateIn != nil {
+				close(lateIn)
+			} else if stage.late != nil {
+				errorWg.Done()
+			}
+		}(fanOutIn)
+		fanOutIn = timely.out
+	}
+	edges.warnUnknown()
 
 	// Goroutine para fechar errorCh após todos os produtores de erro terminarem
 	go func() {
 		errorWg.Wait()
-		close(errorCh)
-	}()
-	
-	// Fan-out para processedCh - distribui para Loader e MetricsCollector
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		defer close(loaderCh)
-		defer close(metricsProcessedCh)
-		for record := range processedCh {
-			loaderCh <- record
-			metricsProcessedCh <- record
-		}
+		close(errs.in)
 	}()
-	
-	// Fan-out para errorCh - distribui para ErrorHandler e MetricsCollector
+
+	sinkChs := make([]chan ProcessedRecord, len(sinkEdges))
+	for i, e := range sinkEdges {
+		sinkChs[i] = e.in
+	}
+	errorSinkChs := make([]chan DataRecord, len(errorSinkEdges))
+	for i, e := range errorSinkEdges {
+		errorSinkChs[i] = e.in
+	}
+
+	// Fan-out para processedCh - distribui para os Sinks e o MetricsCollector
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		defer close(errorHandlerCh)
-		defer close(metricsErrorCh)
-		for record := range errorCh {
-			errorHandlerCh <- record
-			metricsErrorCh <- record
-		}
+		fanOut(abortCtx, fanOutIn, append(sinkChs, metricsProcessed.in), func(record ProcessedRecord) {
+			tracker.routed(record.Seq, durableSinks)
+			for _, sink := range p.sinks {
+				telemetry.recordStage(sink.Name(), resultIn)
+				health.touch(sink.Name())
+			}
+			if record.IsAnomaly {
+				telemetry.recordAnomaly(record.SensorID, record.Location)
+			}
+		})
 	}()
 
-	// 4. Loader
+	// Fan-out para errorCh - distribui para os ErrorSinks e o MetricsCollector
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		Loader(loaderCh)
+		fanOut(abortCtx, errs.out, append(errorSinkChs, metricsErrors.in), func(record DataRecord) {
+			if record.Status != "late" { // Os atrasados já passaram pelo resequencer
+				skip(record.Seq)
+			}
+			tracker.routed(record.Seq, durableErrorSinks)
+			for _, sink := range p.errorSinks {
+				telemetry.recordStage(sink.Name(), resultIn)
+				health.touch(sink.Name())
+			}
+		})
 	}()
 
-	// 5. Error Handler
+	// 3. Sinks
+	for i, sink := range p.sinks {
+		wg.Add(1)
+		health.workerStarted(sink.Name())
+		go func(sink Sink, in <-chan ProcessedRecord) {
+			defer wg.Done()
+			defer health.workerStopped(sink.Name())
+			if acknowledges(sink) {
+				if exactlyOnce {
+					sink.(TransactionalSink).ConsumeTransactional(abortCtx, in, txn)
+				} else {
+					sink.(DurableSink).ConsumeDurable(abortCtx, in, durability)
+				}
+				return
+			}
+			if observed, ok := sink.(ObservedSink); ok {
+				observed.ConsumeObserved(abortCtx, in, func(records int, d time.Duration) {
+					telemetry.observeSinkWrite(sink.Name(), records, d)
+				})
+				return
+			}
+			sink.Consume(abortCtx, in)
+		}(sink, sinkEdges[i].out)
+	}
+
+	// 4. Error Sinks
+	for i, sink := range p.errorSinks {
+		wg.Add(1)
+		health.workerStarted(sink.Name())
+		go func(sink ErrorSink, in <-chan DataRecord) {
+			defer wg.Done()
+			defer health.workerStopped(sink.Name())
+			if acknowledges(sink) {
+				if exactlyOnce {
+					sink.(TransactionalErrorSink).ConsumeErrorsTransactional(abortCtx, in, txn)
+				} else {
+					sink.(DurableErrorSink).ConsumeErrorsDurable(abortCtx, in, durability)
+				}
+				return
+			}
+			sink.ConsumeErrors(abortCtx, in)
+		}(sink, errorSinkEdges[i].out)
+	}
+
+	// 5. Metrics Collector
+	var metrics Metrics
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		ErrorHandler(errorHandlerCh)
+		metrics = MetricsCollector(metricsProcessed.out, metricsErrors.out)
 	}()
 
-	// 6. Metrics Collector
-	var metrics Metrics
-	wg.Add(1)
+	// Supervisiona o cancelamento e aplica o prazo de drenagem
+	finished := make(chan struct{})
+	if tracker != nil {
+		go tracker.run(p.cfg.Source.Checkpoint.Interval, finished)
+	}
+	reasonCh := make(chan StopReason, 1)
 	go func() {
-		defer wg.Done()
-		metrics = MetricsCollector(metricsProcessedCh, metricsErrorCh)
+		select {
+		case <-finished:
+			reasonCh <- StopCompleted
+			return
+		case <-ctx.Done():
+		}
+		infof("Pipeline: Cancelamento recebido (%v), drenando registros em trânsito...", ctx.Err())
+		health.setState(StateDraining)
+
+		var timeout <-chan time.Time
+		if p.cfg.Advanced.ShutdownTimeout > 0 {
+			timer := time.NewTimer(time.Duration(p.cfg.Advanced.ShutdownTimeout) * time.Second)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-finished:
+			reasonCh <- StopCancelled
+		case <-timeout:
+			warnf("Pipeline: Prazo de drenagem de %ds esgotado, abortando etapas", p.cfg.Advanced.ShutdownTimeout)
+			abort()
+			reasonCh <- StopDrainTimeout
+		}
 	}()
 
 	wg.Wait() // Espera todas as etapas da pipeline serem concluídas
-	log.Println("Pipeline completed!")
-	return metrics
+	close(finished)
+	if tracker != nil {
+		if line, err := tracker.save(); err != nil {
+			errorf("Pipeline: Erro ao gravar checkpoint %s: %v", tracker.path, err)
+		} else {
+			infof("Pipeline: Checkpoint de %s na linha %d", tracker.input, line)
+		}
+	}
+	metrics.SinkWrites = telemetry.sinkWriteStats()
+	metrics.Dropped = telemetry.droppedRecords()
+	metrics.Late = telemetry.lateRecords()
+	for sink, stats := range metrics.SinkWrites {
+		infof("Pipeline: %s gravou %d registros em %d escritas (média %s, máxima %s)",
+			sink, stats.Records, stats.Writes, stats.Mean(), stats.Max)
+	}
+	reason := <-reasonCh
+	health.setState(StateStopped)
+	infof("Pipeline completed! (motivo: %s)", reason)
+	return metrics, reason
 }
 
+// stageRunner executa um Processor sobre os registros de uma etapa, registrando contagens e
+// latência em telemetry, progresso em health e repetindo falhas transitórias conforme retry.
+type stageRunner struct {
+	processor Processor
+	retry     RetryConfig
+	telemetry *Telemetry
+	health    *Health
+}
+
+// run aplica o Processor a cada registro de in, enviando o resultado para out
+// ou, em caso de erro, para errCh.
+func (s stageRunner) run(ctx context.Context, in <-chan ProcessedRecord, out chan<- ProcessedRecord, errCh chan<- DataRecord) {
+	name := s.processor.Name()
+	for record := range in {
+		if ctx.Err() != nil {
+			warnf("%s: Processamento abortado (%v)", name, ctx.Err())
+			return
+		}
+		s.telemetry.recordStage(name, resultIn)
+		s.health.touch(name)
+		result, err := s.process(ctx, record)
+		result.Seq = record.Seq // A sequência pertence à pipeline, não ao Processor
+		if err != nil {
+			s.telemetry.recordStage(name, resultError)
+			if !send(ctx, errCh, failedRecord(result.DataRecord, err)) {
+				return
+			}
+			continue
+		}
+		s.telemetry.recordStage(name, resultOut)
+		if !send(ctx, out, result) {
+			return
+		}
+	}
+	infof("%s: Processamento finalizado.", name)
+}
 
+// process aplica o Processor a record, tentando novamente enquanto o erro for transitório e
+// restarem tentativas. Cada falha é acrescentada a ErrorHistory; em caso de falha final,
+// Attempts indica quantas tentativas foram feitas.
+func (s stageRunner) process(ctx context.Context, record ProcessedRecord) (ProcessedRecord, error) {
+	name := s.processor.Name()
+	history := append([]AttemptError(nil), record.ErrorHistory...)
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		result, err := s.processor.Process(ctx, record)
+		s.telemetry.observeLatency(name, time.Since(start))
+		if err == nil {
+			if attempt > 1 {
+				result.Attempts = attempt
+				result.ErrorHistory = history
+			}
+			return result, nil
+		}
+
+		transient := IsTransient(err)
+		history = append(history, AttemptError{
+			Attempt:   attempt,
+			Stage:     name,
+			Error:     err.Error(),
+			Transient: transient,
+			At:        time.Now(),
+		})
+		result.Attempts = attempt
+		result.ErrorHistory = history
+		if !transient || attempt >= s.retry.MaxAttempts {
+			return result, err
+		}
+
+		delay := backoff(s.retry, attempt)
+		warnf("%s: Falha transitória no registro %s (tentativa %d/%d), nova tentativa em %s: %v",
+			name, record.ID, attempt, s.retry.MaxAttempts, delay.Round(time.Millisecond), err)
+		s.telemetry.recordStage(name, resultRetry)
+		if !sleepContext(ctx, delay) {
+			return result, err
+		}
+		s.health.touch(name)
+	}
+}
+
+// failedRecord prepara um registro para o caminho de erro, preenchendo Status e Error
+// quando o Processor não os definiu.
+func failedRecord(record DataRecord, err error) DataRecord {
+	if record.Error == "" {
+		record.Error = err.Error()
+	}
+	if record.Status == "" || record.Status == "raw" {
+		record.Status = "failed"
+	}
+	return record
+}
+
+// passThrough é o Processor usado quando nenhum foi configurado.
+type passThrough struct{}
+
+func (passThrough) Name() string { return "PassThrough" }
+
+func (passThrough) Process(_ context.Context, record ProcessedRecord) (ProcessedRecord, error) {
+	return record, nil
+}
+
+// fanOut replica cada item de in em todos os canais de outs, fechando-os ao terminar.
+// observe, se não for nil, é chamado uma vez por item antes da replicação.
+func fanOut[T any](ctx context.Context, in <-chan T, outs []chan T, observe func(T)) {
+	defer func() {
+		for _, out := range outs {
+			close(out)
+		}
+	}()
+	for item := range in {
+		if observe != nil {
+			observe(item)
+		}
+		for _, out := range outs {
+			if !send(ctx, out, item) {
+				return
+			}
+		}
+	}
+}
+
+// channelDepth retorna uma função que informa quantos registros aguardam em ch.
+func channelDepth[T any](ch chan T) func() int {
+	return func() int { return len(ch) }
+}
+
+// lanesDepth retorna uma função que soma a ocupação das faixas de uma etapa.
+func lanesDepth[T any](lanes []*edge[T]) func() int {
+	return func() int {
+		depth := 0
+		for _, lane := range lanes {
+			depth += lane.depth()
+		}
+		return depth
+	}
+}
+
+// keyLane escolhe a faixa de uma chave pelo hash FNV-1a, de modo que registros com a mesma
+// chave sigam sempre pela mesma faixa.
+func keyLane(key string, lanes int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(lanes))
+}
+
+// send envia v em ch, desistindo se ctx for cancelado antes.
+// Retorna false quando o envio não aconteceu.
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
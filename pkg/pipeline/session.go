@@ -0,0 +1,256 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// SessionConfig controla as janelas de sessão por SensorID (ver SessionSink).
+type SessionConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Gap é o silêncio que encerra uma sessão: registros do mesmo sensor a menos de Gap um do
+	// outro, pelo Timestamp, pertencem à mesma sessão
+	Gap        time.Duration `yaml:"gap"`
+	OutputFile string        `yaml:"output_file"` // JSONL com um SessionSummary por sessão
+}
+
+// SessionSummary resume uma sessão de atividade de um sensor: os registros entre Start e End,
+// inclusive, sem silêncio de Gap entre eles. Flushed indica que a sessão ainda estava aberta
+// quando a entrada terminou e pode continuar na próxima execução.
+type SessionSummary struct {
+	SensorID  string    `json:"sensor_id"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Count     int       `json:"count"`
+	Mean      float64   `json:"mean"`
+	Anomalies int       `json:"anomalies"`
+	Flushed   bool      `json:"flushed,omitempty"`
+}
+
+// SessionOutput consome os resumos emitidos por um SessionSink.
+// ConsumeSessions retorna quando in é fechado ou ctx é cancelado, liberando seus recursos.
+type SessionOutput interface {
+	Name() string
+	ConsumeSessions(ctx context.Context, in <-chan SessionSummary)
+}
+
+// SessionSink é o Sink que agrupa os registros processados de cada SensorID em sessões
+// separadas por session.gap de silêncio e envia um SessionSummary por sessão encerrada para
+// Output.
+//
+// As sessões seguem o tempo dos eventos: uma sessão é encerrada quando o watermark passa de
+// seu fim mais Gap e, com Watermark.Enabled, mais Watermark.AllowedLateness, de modo que os
+// registros atrasados aceitos pela pipeline ainda a alcançam. Sem Watermark.Enabled, o
+// watermark do SessionSink ainda é o maior Timestamp recebido menos
+// Watermark.MaxOutOfOrderness, para tolerar a reordenação entre workers. Um registro fora de ordem que
+// preenche o silêncio entre duas sessões abertas as une. Registros que cairiam em uma sessão
+// já encerrada são ignorados e contados, assim como os sem Timestamp. Ao fim da entrada,
+// inclusive no desligamento gracioso, as sessões abertas são encerradas com Flushed.
+type SessionSink struct {
+	Session   SessionConfig
+	Watermark WatermarkConfig
+	Output    SessionOutput
+}
+
+// Name identifica a etapa nos logs.
+func (s SessionSink) Name() string { return "Sessionizer" }
+
+// CheckHealth repassa a verificação de Output, se ele a implementar (ver HealthChecker).
+func (s SessionSink) CheckHealth() error {
+	if checker, ok := s.Output.(HealthChecker); ok {
+		return checker.CheckHealth()
+	}
+	return nil
+}
+
+// Consume agrupa cada registro de in até o canal ser fechado ou ctx ser cancelado.
+func (s SessionSink) Consume(ctx context.Context, in <-chan ProcessedRecord) {
+	infof("Sessionizer: Iniciando sessões por sensor com intervalo de %s...", s.Session.Gap)
+	out := make(chan SessionSummary)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Output.ConsumeSessions(ctx, out)
+	}()
+	defer func() {
+		close(out)
+		<-done
+	}()
+
+	z := newSessionizer(s.Session, s.Watermark)
+	emit := func(summaries []SessionSummary) bool {
+		for _, summary := range summaries {
+			if !send(ctx, out, summary) {
+				return false
+			}
+		}
+		return true
+	}
+	for record := range in {
+		if ctx.Err() != nil {
+			warnf("Sessionizer: Sessões abortadas (%v)", ctx.Err())
+			return
+		}
+		if !emit(z.add(record)) {
+			return
+		}
+	}
+	flushed := z.flush()
+	emit(flushed)
+	if z.late > 0 || z.untimed > 0 {
+		warnf("Sessionizer: %d registros chegaram após o encerramento de suas sessões e %d não tinham timestamp; ambos foram ignorados",
+			z.late, z.untimed)
+	}
+	infof("Sessionizer: Sessões finalizadas (%d encerradas, %d abertas no fim da entrada).", z.emitted-len(flushed), len(flushed))
+}
+
+// session acumula os registros de uma sessão aberta.
+type session struct {
+	start, end time.Time
+	count      int
+	sum        float64
+	anomalies  int
+}
+
+// sessionizer mantém as sessões abertas de cada sensor e encerra as que o watermark passou.
+type sessionizer struct {
+	gap      time.Duration
+	lateness time.Duration
+
+	open   map[string][]*session // Sessões abertas por SensorID, em ordem de início
+	closed map[string]time.Time  // Fim da última sessão encerrada de cada SensorID
+	clock  eventClock
+
+	late, untimed, emitted int
+}
+
+func newSessionizer(cfg SessionConfig, watermark WatermarkConfig) *sessionizer {
+	z := &sessionizer{
+		gap:    cfg.Gap,
+		open:   make(map[string][]*session),
+		closed: make(map[string]time.Time),
+	}
+	// Como nas janelas, os workers paralelos entregam os registros fora de ordem mesmo sem
+	// watermark.enabled: as sessões esperam max_out_of_orderness antes de serem encerradas
+	z.clock.maxOutOfOrderness = watermark.MaxOutOfOrderness
+	if watermark.Enabled {
+		z.lateness = watermark.AllowedLateness
+	}
+	return z
+}
+
+// add acrescenta record à sessão de seu sensor e retorna os resumos das sessões que o novo
+// watermark encerrou.
+func (z *sessionizer) add(record ProcessedRecord) []SessionSummary {
+	ts := record.Timestamp
+	if ts.IsZero() {
+		z.untimed++
+		return nil
+	}
+	if end, ok := z.closed[record.SensorID]; ok && ts.Before(end.Add(z.gap)) {
+		z.late++
+		return nil
+	}
+
+	// Une o registro às sessões a menos de gap dele, que podem ser mais de uma quando ele
+	// preenche o silêncio entre elas
+	merged := &session{start: ts, end: ts, count: 1, sum: record.Value}
+	if record.IsAnomaly {
+		merged.anomalies = 1
+	}
+	var kept []*session
+	for _, s := range z.open[record.SensorID] {
+		if ts.After(s.start.Add(-z.gap)) && ts.Before(s.end.Add(z.gap)) {
+			merged.merge(s)
+			continue
+		}
+		kept = append(kept, s)
+	}
+	kept = append(kept, merged)
+	sort.Slice(kept, func(i, j int) bool { return kept[i].start.Before(kept[j].start) })
+	z.open[record.SensorID] = kept
+
+	z.clock.observe(ts)
+	horizon := z.clock.watermark().Add(-z.lateness)
+	return z.close(func(s *session) bool { return !s.end.Add(z.gap).After(horizon) }, false)
+}
+
+// flush encerra todas as sessões abertas.
+func (z *sessionizer) flush() []SessionSummary {
+	return z.close(func(*session) bool { return true }, true)
+}
+
+// close encerra as sessões que satisfazem due e retorna seus resumos em ordem de fim e sensor.
+func (z *sessionizer) close(due func(*session) bool, flushed bool) []SessionSummary {
+	var summaries []SessionSummary
+	for sensorID, sessions := range z.open {
+		var kept []*session
+		for _, s := range sessions {
+			if !due(s) {
+				kept = append(kept, s)
+				continue
+			}
+			summaries = append(summaries, SessionSummary{
+				SensorID:  sensorID,
+				Start:     s.start.UTC(),
+				End:       s.end.UTC(),
+				Count:     s.count,
+				Mean:      s.sum / float64(s.count),
+				Anomalies: s.anomalies,
+				Flushed:   flushed,
+			})
+			if s.end.After(z.closed[sensorID]) {
+				z.closed[sensorID] = s.end
+			}
+		}
+		if len(kept) == 0 {
+			delete(z.open, sensorID)
+		} else {
+			z.open[sensorID] = kept
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if !a.End.Equal(b.End) {
+			return a.End.Before(b.End)
+		}
+		return a.SensorID < b.SensorID
+	})
+	z.emitted += len(summaries)
+	return summaries
+}
+
+// merge acrescenta os registros de other à sessão.
+func (s *session) merge(other *session) {
+	if other.start.Before(s.start) {
+		s.start = other.start
+	}
+	if other.end.After(s.end) {
+		s.end = other.end
+	}
+	s.count += other.count
+	s.sum += other.sum
+	s.anomalies += other.anomalies
+}
+
+// SessionFileSink é o SessionOutput padrão, que grava os resumos em JSONL no arquivo em Path,
+// sobrescrito a cada execução.
+type SessionFileSink struct {
+	Path string
+}
+
+// Name identifica a etapa nos logs.
+func (s SessionFileSink) Name() string { return "SessionWriter" }
+
+// CheckHealth reporta se Path pode receber escrita (ver HealthChecker).
+func (s SessionFileSink) CheckHealth() error { return checkWritable(s.Path) }
+
+// ConsumeSessions grava cada resumo de in até o canal ser fechado ou ctx ser cancelado.
+func (s SessionFileSink) ConsumeSessions(ctx context.Context, in <-chan SessionSummary) {
+	writeJSONL(ctx, s.Name(), s.Path, in)
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// runSessions envia records a um SessionSink com intervalo gap e o watermark de wm e retorna
+// os resumos emitidos.
+func runSessions(gap time.Duration, wm WatermarkConfig, records ...ProcessedRecord) []SessionSummary {
+	out := &memoryOutput[SessionSummary]{}
+	in := make(chan ProcessedRecord, len(records))
+	for _, record := range records {
+		in <- record
+	}
+	close(in)
+	SessionSink{Session: SessionConfig{Gap: gap}, Watermark: wm, Output: out}.Consume(context.Background(), in)
+	return out.all()
+}
+
+// anomalous marca record como anomalia.
+func anomalous(record ProcessedRecord) ProcessedRecord {
+	record.IsAnomaly = true
+	return record
+}
+
+func TestSessionsTolerateReorderingWithoutWatermark(t *testing.T) {
+	// Com o watermark desativado, o registro de 29s chega depois do de 31s, reordenado entre
+	// workers, e ainda pertence à sessão iniciada em 0s
+	summaries := runSessions(30*time.Second, DefaultConfig().Watermark,
+		timedRecord("s1", 0, 1),
+		timedRecord("s1", 31, 2),
+		timedRecord("s1", 29, 3),
+	)
+	if len(summaries) != 1 || summaries[0].Count != 3 {
+		t.Fatalf("Expected the reordered record to join the single session, got %+v", summaries)
+	}
+}
+
+func TestSessionsSplitBursts(t *testing.T) {
+	summaries := runSessions(30*time.Second, WatermarkConfig{},
+		timedRecord("s1", 0, 10),
+		anomalous(timedRecord("s1", 5, 20)),
+		timedRecord("s1", 10, 30),
+		timedRecord("s2", 50, 7),  // Encerra a primeira sessão de s1
+		timedRecord("s1", 100, 1), // Encerra a sessão de s2
+		anomalous(timedRecord("s1", 105, 3)),
+		timedRecord("s1", 8, 99),                                          // A sessão já foi encerrada
+		ProcessedRecord{DataRecord: DataRecord{SensorID: "s1", Value: 1}}, // Sem timestamp
+	)
+	if len(summaries) != 3 {
+		t.Fatalf("Expected 3 sessions, got %d: %+v", len(summaries), summaries)
+	}
+	first := summaries[0]
+	if first.SensorID != "s1" || !first.Start.Equal(windowEpoch) || !first.End.Equal(windowEpoch.Add(10*time.Second)) ||
+		first.Count != 3 || first.Mean != 20 || first.Anomalies != 1 || first.Flushed {
+		t.Errorf("Expected the first burst of s1 closed by the gap, got %+v", first)
+	}
+	if s2 := summaries[1]; s2.SensorID != "s2" || s2.Count != 1 || s2.Flushed {
+		t.Errorf("Expected the session of s2 closed by the gap, got %+v", s2)
+	}
+	if last := summaries[2]; last.SensorID != "s1" || last.Count != 2 || last.Mean != 2 || last.Anomalies != 1 || !last.Flushed {
+		t.Errorf("Expected the second burst of s1 flushed at the end, got %+v", last)
+	}
+}
+
+func TestSessionsMergeOutOfOrderRecords(t *testing.T) {
+	wm := WatermarkConfig{Enabled: true, MaxOutOfOrderness: 10 * time.Second, AllowedLateness: 20 * time.Second}
+	summaries := runSessions(15*time.Second, wm,
+		timedRecord("s1", 0, 1),
+		timedRecord("s1", 20, 2),  // Sessão separada
+		timedRecord("s1", 10, 3),  // Preenche o silêncio e une as duas
+		timedRecord("s1", 60, 4),  // Horizonte 30s (watermark 50s - 20s): a sessão [0s, 20s] segue aberta
+		timedRecord("s1", 30, 5),  // Atrasado no prazo: estende a sessão até 30s
+		timedRecord("s1", 120, 6), // Horizonte 90s: encerra [0s, 30s] e [60s]
+	)
+	if len(summaries) != 3 {
+		t.Fatalf("Expected 3 sessions, got %d: %+v", len(summaries), summaries)
+	}
+	if merged := summaries[0]; merged.Count != 4 || merged.Mean != 2.75 || !merged.End.Equal(windowEpoch.Add(30*time.Second)) || merged.Flushed {
+		t.Errorf("Expected one session with the four records up to 30s, got %+v", merged)
+	}
+	if summaries[1].Count != 1 || summaries[1].Flushed || summaries[2].Count != 1 || !summaries[2].Flushed {
+		t.Errorf("Expected the session at 60s closed and the one at 120s flushed at the end, got %+v", summaries[1:])
+	}
+}
+
+func TestSessionsFlushOnShutdown(t *testing.T) {
+	records := make([]DataRecord, 1000)
+	for i := range records {
+		records[i] = timedRecord("s1", i, 1).DataRecord
+	}
+	out := &memoryOutput[SessionSummary]{}
+	cfg := DefaultConfig()
+	cfg.Session = SessionConfig{Enabled: true, Gap: time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p, err := NewBuilder(cfg).
+		WithSource(sliceSource{records: records}).
+		AddProcessor(cancelAfter{n: 20, count: new(atomic.Int32), cancel: cancel}).
+		AddSink(SessionSink{Session: cfg.Session, Output: out}).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	if _, reason := p.Run(ctx); reason != StopCancelled {
+		t.Fatalf("Expected the run to be cancelled, got %s", reason)
+	}
+	summaries := out.all()
+	if len(summaries) != 1 || !summaries[0].Flushed || summaries[0].Count < 20 || summaries[0].Count == len(records) {
+		t.Errorf("Expected the open session flushed with the records drained before shutdown, got %+v", summaries)
+	}
+}
+
+func TestSessionConfigValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Session = SessionConfig{Enabled: true, OutputFile: cfg.Window.OutputFile}
+	err := cfg.Validate()
+	for _, field := range []string{"session.gap", "session.output_file"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected an error for %s, got %v", field, err)
+		}
+	}
+
+	cfg = DefaultConfig()
+	cfg.Session.Enabled = true
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the default session settings to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,420 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	spillSegmentPrefix = "seg-"
+	spillSegmentSuffix = ".spill"
+	spillCursorFile    = "cursor"
+	spillFrameHeader   = 8   // Tamanho (uint32) e CRC-32C (uint32) do conteúdo
+	spillSyncFrames    = 128 // Quadros gravados entre duas sincronizações do segmento de escrita
+)
+
+var spillCRC = crc32.MakeTable(crc32.Castagnoli)
+
+// spillQueue é a fila em disco de um canal com a política spill. Os registros são gravados em
+// ordem em segmentos (seg-<n>.spill) de até segmentBytes, cada um em um quadro com tamanho e
+// CRC-32C do conteúdo codificado com encoding/gob, que preserva também os campos sem
+// representação JSON. O segmento de escrita é sincronizado com o disco a cada spillSyncFrames
+// quadros e ao ser fechado.
+//
+// pop lê os registros em ordem, mas só ack os confirma: o arquivo cursor guarda o segmento e a
+// posição seguinte ao último registro confirmado, e um segmento só é apagado quando o cursor
+// passa dele. Registros lidos e ainda não confirmados, como os que estavam na fila em memória
+// de um canal abortado, são lidos de novo pela próxima execução.
+//
+// Os segmentos sobrevivem ao fim da execução: ao ser aberta, a fila retoma os registros
+// deixados por uma execução anterior, descartando um quadro final incompleto ou corrompido.
+type spillQueue[T any] struct {
+	dir          string
+	segmentBytes int64
+	segments     []uint64 // Segmentos existentes, em ordem; o último pode ser o de escrita
+
+	w     *os.File // Segmento de escrita; nil até a primeira gravação desta execução
+	wID   uint64
+	wSize int64
+
+	r    *os.File // Segmento de leitura
+	rbuf *bufio.Reader
+	rID  uint64
+	rOff int64
+
+	cursor    *os.File
+	unacked   []spillPos // Posição seguinte a cada registro lido e ainda não confirmado
+	unsynced  int        // Quadros gravados desde a última sincronização do segmento de escrita
+	n         int        // Registros gravados e ainda não lidos
+	recovered int        // Registros retomados de uma execução anterior
+	corrupt   int        // Registros perdidos em quadros corrompidos
+}
+
+// spillPos é uma posição na fila: um segmento e um deslocamento nele.
+type spillPos struct {
+	segment uint64
+	offset  int64
+}
+
+// openSpillQueue abre, criando se preciso, a fila do diretório dir.
+func openSpillQueue[T any](dir string, segmentBytes int64) (*spillQueue[T], error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	q := &spillQueue[T]{dir: dir, segmentBytes: segmentBytes}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, spillSegmentPrefix) || !strings.HasSuffix(name, spillSegmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, spillSegmentPrefix), spillSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		q.segments = append(q.segments, id)
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i] < q.segments[j] })
+
+	q.cursor, err = os.OpenFile(filepath.Join(dir, spillCursorFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	var pos [16]byte
+	if n, _ := q.cursor.ReadAt(pos[:], 0); n == len(pos) {
+		q.rID = binary.LittleEndian.Uint64(pos[:8])
+		q.rOff = int64(binary.LittleEndian.Uint64(pos[8:]))
+	}
+
+	// Apaga os segmentos já lidos e conta os registros pendentes dos demais
+	for len(q.segments) > 0 && q.segments[0] < q.rID {
+		os.Remove(q.segmentPath(q.segments[0]))
+		q.segments = q.segments[1:]
+	}
+	if len(q.segments) == 0 || q.segments[0] != q.rID {
+		q.rOff = 0
+	}
+	for _, id := range q.segments {
+		offset := int64(0)
+		if id == q.rID {
+			offset = q.rOff
+		}
+		records, err := q.scan(id, offset)
+		if err != nil {
+			q.close()
+			return nil, err
+		}
+		q.n += records
+	}
+	if len(q.segments) > 0 {
+		q.rID = q.segments[0]
+		q.wID = q.segments[len(q.segments)-1]
+	}
+	q.recovered = q.n
+	return q, nil
+}
+
+func (q *spillQueue[T]) segmentPath(id uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%s%020d%s", spillSegmentPrefix, id, spillSegmentSuffix))
+}
+
+// scan conta os quadros válidos do segmento id a partir de offset e trunca o segmento no
+// primeiro quadro incompleto ou corrompido, deixado por uma execução interrompida.
+func (q *spillQueue[T]) scan(id uint64, offset int64) (int, error) {
+	f, err := os.OpenFile(q.segmentPath(id), os.O_RDWR, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(f)
+	records := 0
+	for {
+		payload, err := readSpillFrame(r)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			errorf("Spill: %s: registros a partir da posição %d descartados: %v", q.segmentPath(id), offset, err)
+			q.corrupt++
+			return records, f.Truncate(offset)
+		}
+		offset += spillFrameHeader + int64(len(payload))
+		records++
+	}
+}
+
+// readSpillFrame lê um quadro de r, retornando io.EOF apenas no limite entre quadros.
+func readSpillFrame(r *bufio.Reader) ([]byte, error) {
+	var header [spillFrameHeader]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, errors.New("quadro incompleto")
+		}
+		return nil, err
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header[:4]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errors.New("quadro incompleto")
+	}
+	if crc32.Checksum(payload, spillCRC) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, errors.New("checksum inválido")
+	}
+	return payload, nil
+}
+
+func (q *spillQueue[T]) len() int { return q.n }
+
+// retained informa quantos registros ficam em disco para a próxima execução se a fila for
+// fechada agora: os ainda não lidos e os lidos sem confirmação.
+func (q *spillQueue[T]) retained() int { return q.n + len(q.unacked) }
+
+// push grava v no fim da fila. Cada execução grava em segmentos novos, sem acrescentar a
+// segmentos de execuções anteriores.
+func (q *spillQueue[T]) push(v T) error {
+	var payload bytes.Buffer
+	if err := gob.NewEncoder(&payload).Encode(&v); err != nil {
+		return err
+	}
+	frame := make([]byte, spillFrameHeader, spillFrameHeader+payload.Len())
+	binary.LittleEndian.PutUint32(frame[:4], uint32(payload.Len()))
+	binary.LittleEndian.PutUint32(frame[4:], crc32.Checksum(payload.Bytes(), spillCRC))
+	frame = append(frame, payload.Bytes()...)
+
+	if q.w == nil || (q.wSize > 0 && q.wSize+int64(len(frame)) > q.segmentBytes) {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := q.w.Write(frame); err != nil {
+		return err
+	}
+	q.wSize += int64(len(frame))
+	q.n++
+	q.unsynced++
+	if q.unsynced >= spillSyncFrames {
+		if err := q.w.Sync(); err != nil {
+			return err
+		}
+		q.unsynced = 0
+	}
+	return nil
+}
+
+// rotate sincroniza e fecha o segmento de escrita e cria o seguinte.
+func (q *spillQueue[T]) rotate() error {
+	if q.w != nil {
+		if err := q.w.Sync(); err != nil {
+			return err
+		}
+		if err := q.w.Close(); err != nil {
+			return err
+		}
+		q.unsynced = 0
+	}
+	q.wID++
+	w, err := os.OpenFile(q.segmentPath(q.wID), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	q.w, q.wSize = w, 0
+	q.segments = append(q.segments, q.wID)
+	return nil
+}
+
+// pop lê o registro mais antigo ainda não lido. O cursor só avança quando ack o confirma.
+func (q *spillQueue[T]) pop() (T, error) {
+	var v T
+	for {
+		if q.r == nil {
+			// Primeiro segmento a partir de rID; os anteriores já foram lidos
+			i := sort.Search(len(q.segments), func(i int) bool { return q.segments[i] >= q.rID })
+			if i == len(q.segments) {
+				return v, errors.New("fila vazia")
+			}
+			if q.segments[i] != q.rID {
+				q.rID, q.rOff = q.segments[i], 0
+			}
+			r, err := os.Open(q.segmentPath(q.rID))
+			if err != nil {
+				return v, err
+			}
+			if _, err := r.Seek(q.rOff, io.SeekStart); err != nil {
+				r.Close()
+				return v, err
+			}
+			q.r, q.rbuf = r, bufio.NewReader(r)
+		}
+		payload, err := readSpillFrame(q.rbuf)
+		if err == io.EOF && (q.w == nil || q.rID != q.wID) {
+			// Segmento lido até o fim: passa ao seguinte. Ele é apagado por ack
+			q.r.Close()
+			q.r = nil
+			q.rID, q.rOff = q.rID+1, 0
+			continue
+		}
+		if err != nil {
+			return v, fmt.Errorf("%s: %w", q.segmentPath(q.rID), err)
+		}
+		if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&v); err != nil {
+			return v, fmt.Errorf("%s: %w", q.segmentPath(q.rID), err)
+		}
+		q.rOff += spillFrameHeader + int64(len(payload))
+		q.n--
+		q.unacked = append(q.unacked, spillPos{q.rID, q.rOff})
+		if q.recovered > 0 {
+			q.recovered--
+			clearSeq(&v)
+		}
+		return v, nil
+	}
+}
+
+// ack confirma a entrega do registro mais antigo lido por pop e ainda não confirmado,
+// avançando o cursor para depois dele. Os segmentos que o cursor deixou para trás são
+// apagados depois de o cursor ser sincronizado com o disco.
+func (q *spillQueue[T]) ack() {
+	if len(q.unacked) == 0 {
+		return
+	}
+	pos := q.unacked[0]
+	q.unacked = q.unacked[1:]
+	q.saveCursor(pos)
+	if len(q.segments) == 0 || q.segments[0] >= pos.segment {
+		return
+	}
+	if err := q.cursor.Sync(); err != nil {
+		errorf("Spill: %s: falha ao sincronizar o cursor: %v", q.dir, err)
+		return
+	}
+	for len(q.segments) > 0 && q.segments[0] < pos.segment {
+		os.Remove(q.segmentPath(q.segments[0]))
+		q.segments = q.segments[1:]
+	}
+}
+
+// saveCursor grava pos como a posição de leitura, para que uma nova execução não repita os
+// registros já entregues.
+func (q *spillQueue[T]) saveCursor(pos spillPos) {
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], pos.segment)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(pos.offset))
+	if _, err := q.cursor.WriteAt(buf[:], 0); err != nil {
+		errorf("Spill: %s: falha ao gravar o cursor: %v", q.dir, err)
+	}
+}
+
+// discard abandona os registros pendentes e apaga os segmentos.
+func (q *spillQueue[T]) discard() {
+	q.n = 0
+	q.unacked = nil
+	q.recovered = 0
+	q.close()
+}
+
+// close sincroniza e fecha os arquivos. Os segmentos com registros não lidos ou não
+// confirmados são mantidos para a próxima execução; sem eles, os segmentos e o cursor são
+// apagados.
+func (q *spillQueue[T]) close() {
+	retained := q.retained() > 0
+	if q.w != nil {
+		if retained {
+			q.w.Sync()
+		}
+		q.w.Close()
+		q.w = nil
+	}
+	if q.r != nil {
+		q.r.Close()
+		q.r = nil
+	}
+	if q.cursor != nil {
+		if retained {
+			q.cursor.Sync()
+		}
+		q.cursor.Close()
+		q.cursor = nil
+	}
+	if retained {
+		return
+	}
+	for _, id := range q.segments {
+		os.Remove(q.segmentPath(id))
+	}
+	q.segments = nil
+	os.Remove(filepath.Join(q.dir, spillCursorFile))
+}
+
+// clearSeq zera a sequência de um registro retomado de uma execução anterior, que não tem
+// significado para o resequenciador desta execução.
+func clearSeq(v any) {
+	switch r := v.(type) {
+	case *DataRecord:
+		r.Seq = 0
+	case *ProcessedRecord:
+		r.Seq = 0
+	}
+}
+
+// memoryBudget limita a memória ocupada pelas filas dos canais com a política spill
+// (advanced.max_memory_mb); acima do limite, os registros vão para o disco.
+type memoryBudget struct {
+	limit int64 // Bytes; 0 para ilimitado
+	used  atomic.Int64
+}
+
+// full informa se o limite foi atingido; um orçamento nil nunca está cheio.
+func (b *memoryBudget) full() bool {
+	return b != nil && b.limit > 0 && b.used.Load() >= b.limit
+}
+
+func (b *memoryBudget) add(n int64) {
+	if b != nil {
+		b.used.Add(n)
+	}
+}
+
+// recordBytes estima a memória ocupada por um registro.
+func recordBytes(v any) int64 {
+	switch r := v.(type) {
+	case ProcessedRecord:
+		return int64(reflect.TypeOf(r).Size()) + dataRecordBytes(r.DataRecord) + int64(len(r.AnomalyDetector))
+	case DataRecord:
+		return int64(reflect.TypeOf(r).Size()) + dataRecordBytes(r)
+	}
+	return int64(reflect.TypeOf(v).Size())
+}
+
+// dataRecordBytes soma o conteúdo referenciado pelos campos de r.
+func dataRecordBytes(r DataRecord) int64 {
+	n := len(r.ID) + len(r.SensorID) + len(r.Unit) + len(r.Location) + len(r.Status) + len(r.Error) + len(r.Raw)
+	for _, code := range r.ErrorCodes {
+		n += len(code) + 16
+	}
+	for _, attempt := range r.ErrorHistory {
+		n += int(reflect.TypeOf(attempt).Size()) + len(attempt.Stage) + len(attempt.Error)
+	}
+	return int64(n)
+}
@@ -0,0 +1,466 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/go-sqlite3" // Driver "sqlite3"; exige cgo
+)
+
+// SQLiteConfig controla o banco SQLite gravado por SQLiteSink e SQLiteErrorSink.
+type SQLiteConfig struct {
+	Path           string        `yaml:"path"`            // Arquivo do banco; vazio desativa
+	ProcessedTable string        `yaml:"processed_table"` // Tabela de ProcessedRecord
+	FailedTable    string        `yaml:"failed_table"`    // Tabela dos registros com erro
+	BatchSize      int           `yaml:"batch_size"`      // Registros por transação
+	FlushInterval  time.Duration `yaml:"flush_interval"`  // Prazo máximo de um lote incompleto; 0 espera o lote encher
+	Retry          RetryConfig   `yaml:"retry"`           // Novas tentativas de um lote com o banco ocupado (busy/locked)
+}
+
+// sqlIdentifier restringe nomes de tabela configuráveis, que não podem ser passados como parâmetro.
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sqliteSetup serializa a abertura dos bancos: SQLiteSink e SQLiteErrorSink abrem o mesmo
+// arquivo ao mesmo tempo, e a troca para WAL e o CREATE TABLE podem falhar com "database is
+// locked" sem esperar pelo busy_timeout.
+var sqliteSetup sync.Mutex
+
+// columnKind classifica o tipo Go de um campo para escolher o tipo da coluna.
+type columnKind int
+
+const (
+	columnText columnKind = iota
+	columnReal
+	columnInteger
+	columnBool
+	columnTime
+	columnJSON // Slices e structs, gravados como JSON
+)
+
+// recordColumn é uma coluna derivada de um campo de DataRecord ou ProcessedRecord.
+type recordColumn struct {
+	name  string // Chave JSON do campo
+	index []int  // Caminho para reflect.Value.FieldByIndex
+	kind  columnKind
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// recordColumns deriva as colunas de t a partir das tags JSON dos campos, incluindo os das
+// structs embutidas, de modo que novos campos viram colunas sem mudanças nos sinks SQL.
+func recordColumns(t reflect.Type) []recordColumn {
+	var columns []recordColumn
+	var walk func(t reflect.Type, prefix []int)
+	walk = func(t reflect.Type, prefix []int) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			index := append(append([]int{}, prefix...), i)
+			if field.Anonymous {
+				walk(field.Type, index)
+				continue
+			}
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			columns = append(columns, recordColumn{name: name, index: index, kind: columnKindOf(field.Type)})
+		}
+	}
+	walk(t, nil)
+	return columns
+}
+
+func columnKindOf(t reflect.Type) columnKind {
+	switch {
+	case t == timeType:
+		return columnTime
+	case t.Kind() == reflect.String:
+		return columnText
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return columnReal
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return columnInteger
+	case t.Kind() == reflect.Bool:
+		return columnBool
+	default:
+		return columnJSON
+	}
+}
+
+// value retorna o valor da coluna em record. Datas zeradas e slices vazios viram NULL;
+// slices e structs são serializados em JSON.
+func (c recordColumn) value(record reflect.Value) interface{} {
+	v := record.FieldByIndex(c.index)
+	switch c.kind {
+	case columnTime:
+		t := v.Interface().(time.Time)
+		if t.IsZero() {
+			return nil
+		}
+		return t.UTC()
+	case columnJSON:
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() == 0 {
+			return nil
+		}
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return nil
+		}
+		return string(data)
+	default:
+		return v.Interface()
+	}
+}
+
+// sqliteTypes são os tipos das colunas no SQLite. Datas são gravadas como texto RFC 3339,
+// que as funções de data do SQLite aceitam.
+var sqliteTypes = map[columnKind]string{
+	columnText:    "TEXT",
+	columnReal:    "REAL",
+	columnInteger: "INTEGER",
+	columnBool:    "INTEGER",
+	columnTime:    "TEXT",
+	columnJSON:    "TEXT",
+}
+
+// SQLiteSink é o Sink que grava os registros processados na tabela ProcessedTable do banco
+// SQLite em Path, com upsert por id: reprocessar os mesmos registros atualiza as linhas
+// existentes em vez de duplicá-las.
+type SQLiteSink struct {
+	SQLite SQLiteConfig
+}
+
+// Name identifica a etapa nos logs.
+func (s SQLiteSink) Name() string { return "SQLiteLoader" }
+
+// CheckHealth reporta se o banco pode receber escrita (ver HealthChecker).
+func (s SQLiteSink) CheckHealth() error { return checkWritable(s.SQLite.Path) }
+
+// Consume grava os registros de in em lotes até o canal ser fechado ou ctx ser cancelado.
+func (s SQLiteSink) Consume(ctx context.Context, in <-chan ProcessedRecord) {
+	s.ConsumeObserved(ctx, in, nil)
+}
+
+// ConsumeObserved é Consume reportando cada transação concluída a observe (ver ObservedSink).
+func (s SQLiteSink) ConsumeObserved(ctx context.Context, in <-chan ProcessedRecord, observe WriteObserver) {
+	consumeSQLite(ctx, s.Name(), s.SQLite, s.SQLite.ProcessedTable, s.SQLite.FailedTable, in, observe)
+}
+
+// SQLiteErrorSink é o ErrorSink que grava os registros com erro na tabela FailedTable do
+// banco SQLite em Path, também com upsert por id.
+type SQLiteErrorSink struct {
+	SQLite SQLiteConfig
+}
+
+// Name identifica a etapa nos logs.
+func (s SQLiteErrorSink) Name() string { return "SQLiteErrorHandler" }
+
+// CheckHealth reporta se o banco pode receber escrita (ver HealthChecker).
+func (s SQLiteErrorSink) CheckHealth() error { return checkWritable(s.SQLite.Path) }
+
+// ConsumeErrors grava os registros de in em lotes até o canal ser fechado ou ctx ser cancelado.
+func (s SQLiteErrorSink) ConsumeErrors(ctx context.Context, in <-chan DataRecord) {
+	consumeSQLite(ctx, s.Name(), s.SQLite, s.SQLite.FailedTable, "", in, nil)
+}
+
+// consumeSQLite grava os registros de in na tabela, em uma transação por lote. O lote é
+// gravado ao atingir BatchSize, quando FlushInterval expira e ao final, inclusive quando ctx
+// é cancelado, para que nenhum registro já recebido se perca; nesse caso, sem novas
+// tentativas. Registros que a tabela recusar vão para failedTable, se não for vazia (ver
+// sqliteWriter.flush). Se observe não for nil, recebe cada transação concluída.
+func consumeSQLite[T any](ctx context.Context, name string, cfg SQLiteConfig, table, failedTable string, in <-chan T, observe WriteObserver) {
+	infof("%s: Iniciando carregamento em %s (tabela %s)...", name, cfg.Path, table)
+	w, err := openSQLiteWriter(cfg.Path, table, reflect.TypeOf(*new(T)))
+	if err != nil {
+		log.Fatalf("%s: Falha ao abrir banco de dados: %v", name, err)
+	}
+	w.name = name
+	w.retry = cfg.Retry
+	w.failedTable = failedTable
+	w.observe = observe
+	defer func() {
+		if err := w.flush(ctx); err != nil {
+			errorf("%s: Erro ao gravar lote final: %v", name, err)
+		}
+		if err := w.close(); err != nil {
+			errorf("%s: Erro ao fechar %s: %v", name, cfg.Path, err)
+		}
+		infof("%s: %d registros gravados em %s.", name, w.written, table)
+	}()
+
+	var tick <-chan time.Time
+	if cfg.FlushInterval > 0 {
+		ticker := time.NewTicker(cfg.FlushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case record, ok := <-in:
+			if !ok {
+				infof("%s: Carregamento de dados finalizado.", name)
+				return
+			}
+			if ctx.Err() != nil {
+				warnf("%s: Gravação abortada (%v)", name, ctx.Err())
+				return
+			}
+			w.add(reflect.ValueOf(record))
+			if len(w.pending) < cfg.BatchSize {
+				continue
+			}
+		case <-tick:
+		}
+		if err := w.flush(ctx); err != nil {
+			errorf("%s: Erro ao gravar lote em %s: %v", name, table, err)
+		}
+	}
+}
+
+// sqliteWriter acumula linhas e as grava com upsert em uma transação.
+type sqliteWriter struct {
+	db      *sql.DB
+	path    string
+	name    string
+	columns []recordColumn
+	upsert  string
+	retry   RetryConfig
+	pending [][]interface{}
+	records []reflect.Value // Registros de pending, na mesma ordem
+	written int
+	observe WriteObserver
+
+	failedTable string        // Tabela dos registros recusados; vazia apenas os registra no log
+	failed      *sqliteWriter // Aberto na primeira recusa
+}
+
+func openSQLiteWriter(path, table string, t reflect.Type) (*sqliteWriter, error) {
+	// WAL, busy_timeout e transações IMMEDIATE permitem que SQLiteSink e SQLiteErrorSink
+	// escrevam no mesmo banco
+	db, err := sql.Open("sqlite3", "file:"+path+"?_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	columns := recordColumns(t)
+	sqliteSetup.Lock()
+	err = ensureSQLiteTable(db, table, columns)
+	sqliteSetup.Unlock()
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	names := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	var updates []string
+	for i, column := range columns {
+		names[i] = column.name
+		placeholders[i] = "?"
+		if column.name != "id" {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", column.name, column.name))
+		}
+	}
+	upsert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (id) DO UPDATE SET %s",
+		table, strings.Join(names, ", "), strings.Join(placeholders, ", "), strings.Join(updates, ", "))
+	return &sqliteWriter{db: db, path: path, columns: columns, upsert: upsert}, nil
+}
+
+// ensureSQLiteTable cria a tabela ou, se já existir, acrescenta as colunas que faltam.
+func ensureSQLiteTable(db *sql.DB, table string, columns []recordColumn) error {
+	definitions := make([]string, len(columns))
+	for i, column := range columns {
+		definitions[i] = column.name + " " + sqliteTypes[column.kind]
+		if column.name == "id" {
+			definitions[i] += " PRIMARY KEY"
+		}
+	}
+	if _, err := db.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", table, strings.Join(definitions, ", "))); err != nil {
+		return err
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s')", table))
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for i, column := range columns {
+		if existing[column.name] || column.name == "id" {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, definitions[i])); err != nil {
+			return err
+		}
+	}
+	_, err = db.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_sensor_time ON %s (sensor_id, timestamp)", table, table))
+	return err
+}
+
+func (w *sqliteWriter) add(record reflect.Value) {
+	row := make([]interface{}, len(w.columns))
+	for i, column := range w.columns {
+		value := column.value(record)
+		if t, ok := value.(time.Time); ok {
+			value = t.Format(time.RFC3339Nano)
+		}
+		row[i] = value
+	}
+	w.pending = append(w.pending, row)
+	w.records = append(w.records, record)
+}
+
+// flush grava as linhas pendentes em uma transação, repetindo-a conforme Retry enquanto o
+// banco estiver ocupado; esgotadas as tentativas, o lote é descartado. Se a transação falhar
+// por outro motivo, como uma restrição violada por um registro, as linhas são gravadas uma a
+// uma e as recusadas vão para reject.
+func (w *sqliteWriter) flush(ctx context.Context) error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	rows, records := w.pending, w.records
+	w.pending, w.records = nil, nil
+	start := time.Now()
+	attempts, err := w.withBusyRetry(ctx, func() error { return w.write(rows) })
+	if err != nil && isSQLiteBusy(err) {
+		return fmt.Errorf("%d registros descartados após %d tentativa(s): %w", len(rows), attempts, err)
+	}
+	written, rejected := len(rows), 0
+	if err != nil {
+		warnf("%s: Lote de %d registros recusado, gravando um a um: %v", w.name, len(rows), err)
+		for i := range rows {
+			if _, err := w.withBusyRetry(ctx, func() error { return w.write(rows[i : i+1]) }); err != nil {
+				written--
+				rejected++
+				w.reject(records[i], err)
+			}
+		}
+		if err := w.flushFailed(ctx); err != nil {
+			errorf("%s: Erro ao gravar registros recusados em %s: %v", w.name, w.failedTable, err)
+		}
+	}
+	w.written += written
+	if w.observe != nil && written > 0 {
+		w.observe(written, time.Since(start))
+	}
+	if rejected > 0 {
+		return fmt.Errorf("%d de %d registros recusados", rejected, len(rows))
+	}
+	return nil
+}
+
+// write grava rows com upsert em uma transação.
+func (w *sqliteWriter) write(rows [][]interface{}) (err error) {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	stmt, err := tx.Prepare(w.upsert)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			_ = stmt.Close()
+			return err
+		}
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// withBusyRetry executa op, repetindo-a conforme Retry enquanto o banco estiver ocupado (ver
+// isSQLiteBusy). Retorna o número de tentativas feitas e o último erro.
+func (w *sqliteWriter) withBusyRetry(ctx context.Context, op func() error) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || !isSQLiteBusy(err) || attempt >= w.retry.MaxAttempts {
+			return attempt, err
+		}
+		wait := backoff(w.retry, attempt)
+		warnf("%s: Banco ocupado (tentativa %d/%d), nova tentativa em %s: %v",
+			w.name, attempt, w.retry.MaxAttempts, wait, err)
+		if !sleepContext(ctx, wait) {
+			return attempt, err
+		}
+	}
+}
+
+// isSQLiteBusy reporta se err indica que outra conexão mantinha o banco ou a tabela
+// bloqueados além do busy_timeout, caso em que a transação pode ser repetida.
+func isSQLiteBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// reject encaminha um registro processado que a tabela recusou para failedTable, com status
+// load_error. Sem failedTable, ou se o próprio registro com erro foi recusado, ele é apenas
+// registrado no log.
+func (w *sqliteWriter) reject(record reflect.Value, err error) {
+	processed, ok := record.Interface().(ProcessedRecord)
+	if !ok || w.failedTable == "" {
+		errorf("%s: Registro %s descartado: %v", w.name, record.FieldByName("ID").String(), err)
+		return
+	}
+	if w.failed == nil {
+		failed, openErr := openSQLiteWriter(w.path, w.failedTable, reflect.TypeOf(DataRecord{}))
+		if openErr != nil {
+			errorf("%s: Registro %s descartado, falha ao abrir %s: %v", w.name, processed.ID, w.failedTable, openErr)
+			return
+		}
+		failed.name = w.name
+		failed.retry = w.retry
+		w.failed = failed
+	}
+	failed := processed.DataRecord
+	failed.Status = "load_error"
+	failed.Error = fmt.Sprintf("%s: %v", w.name, err)
+	w.failed.add(reflect.ValueOf(failed))
+}
+
+// flushFailed grava os registros encaminhados por reject.
+func (w *sqliteWriter) flushFailed(ctx context.Context) error {
+	if w.failed == nil {
+		return nil
+	}
+	return w.failed.flush(ctx)
+}
+
+// close fecha o banco e o da tabela de recusados, se aberto.
+func (w *sqliteWriter) close() error {
+	err := w.db.Close()
+	if w.failed != nil {
+		err = errors.Join(err, w.failed.db.Close())
+	}
+	return err
+}
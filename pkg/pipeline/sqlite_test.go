@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func openTestSQLite(t *testing.T, path string) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err == nil {
+		err = db.Ping()
+	}
+	if err != nil {
+		t.Skipf("SQLite driver unavailable (requires cgo): %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func countRows(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&n); err != nil {
+		t.Fatalf("Failed to count %s: %v", table, err)
+	}
+	return n
+}
+
+func testSQLiteConfig(path string) SQLiteConfig {
+	cfg := DefaultConfig().Output.SQLite
+	cfg.Path = path
+	cfg.BatchSize = 2
+	return cfg
+}
+
+func TestSQLiteSinkUpsert(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.db")
+	db := openTestSQLite(t, path)
+	cfg := testSQLiteConfig(path)
+	ts := time.Date(2025, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	run := func(score float64) {
+		in := make(chan ProcessedRecord, 3)
+		for i, id := range []string{"r1", "r2", "r3"} {
+			in <- ProcessedRecord{
+				DataRecord:   DataRecord{ID: id, Timestamp: ts.Add(time.Duration(i) * time.Second), SensorID: "sensor-1", Value: float64(i), Location: "North", ErrorCodes: []string{"x"}},
+				ProcessedAt:  ts.Add(time.Minute),
+				AnomalyScore: score,
+				IsAnomaly:    i == 2,
+			}
+		}
+		close(in)
+		SQLiteSink{SQLite: cfg}.Consume(context.Background(), in)
+	}
+	run(1)
+	run(2.5)
+
+	if n := countRows(t, db, cfg.ProcessedTable); n != 3 {
+		t.Fatalf("Expected reruns to upsert 3 rows, got %d", n)
+	}
+	var (
+		score     float64
+		anomaly   bool
+		timestamp string
+		codes     sql.NullString
+	)
+	err := db.QueryRow("SELECT anomaly_score, is_anomaly, timestamp, error_codes FROM processed_records WHERE id = 'r3'").
+		Scan(&score, &anomaly, &timestamp, &codes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != 2.5 || !anomaly || timestamp != "2025-01-15T10:30:02Z" || codes.String != `["x"]` {
+		t.Errorf("Unexpected row: score=%g anomaly=%t timestamp=%s codes=%v", score, anomaly, timestamp, codes)
+	}
+}
+
+func TestSQLiteSinkFlushesOnAbort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.db")
+	db := openTestSQLite(t, path)
+	cfg := testSQLiteConfig(path)
+	cfg.BatchSize = 100
+	cfg.FlushInterval = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan ProcessedRecord)
+	done := make(chan struct{})
+	go func() {
+		SQLiteSink{SQLite: cfg}.Consume(ctx, in)
+		close(done)
+	}()
+	in <- ProcessedRecord{DataRecord: DataRecord{ID: "kept"}}
+	cancel()
+	in <- ProcessedRecord{DataRecord: DataRecord{ID: "dropped"}}
+	<-done
+
+	if n := countRows(t, db, cfg.ProcessedTable); n != 1 {
+		t.Errorf("Expected the pending batch to be written on abort, got %d rows", n)
+	}
+}
+
+func TestSQLiteSinkRoutesRejectedRowsToFailedTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.db")
+	db := openTestSQLite(t, path)
+	cfg := testSQLiteConfig(path)
+	cfg.BatchSize = 10
+	if err := ensureSQLiteTable(db, cfg.ProcessedTable, recordColumns(reflect.TypeOf(ProcessedRecord{}))); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.Exec(`CREATE TRIGGER reject_r2 BEFORE INSERT ON processed_records WHEN NEW.id = 'r2'
+		BEGIN SELECT RAISE(ABORT, 'r2 rejected'); END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := make(chan ProcessedRecord, 3)
+	for _, id := range []string{"r1", "r2", "r3"} {
+		in <- ProcessedRecord{DataRecord: DataRecord{ID: id, SensorID: "sensor-1", Status: "processed"}}
+	}
+	close(in)
+	SQLiteSink{SQLite: cfg}.Consume(context.Background(), in)
+
+	if n := countRows(t, db, cfg.ProcessedTable); n != 2 {
+		t.Errorf("Expected the rows of the rejected batch to be written one by one, got %d rows", n)
+	}
+	var id, status, message string
+	if err := db.QueryRow("SELECT id, status, error FROM failed_records").Scan(&id, &status, &message); err != nil {
+		t.Fatalf("Expected the rejected row in the failed table: %v", err)
+	}
+	if id != "r2" || status != "load_error" || !strings.Contains(message, "r2 rejected") {
+		t.Errorf("Unexpected failed row: id=%s status=%s error=%s", id, status, message)
+	}
+}
+
+func TestIsSQLiteBusy(t *testing.T) {
+	if !isSQLiteBusy(fmt.Errorf("commit: %w", sqlite3.Error{Code: sqlite3.ErrBusy})) ||
+		!isSQLiteBusy(sqlite3.Error{Code: sqlite3.ErrLocked}) {
+		t.Error("Expected busy and locked errors to be retried")
+	}
+	if isSQLiteBusy(sqlite3.Error{Code: sqlite3.ErrConstraint}) || isSQLiteBusy(errors.New("other")) {
+		t.Error("Expected other errors not to be retried")
+	}
+}
+
+func TestSQLiteSinksInPipeline(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultConfig()
+	cfg.Pipeline.NumRecords = 40
+	cfg.Producer.ErrorInjectionRate = 0.3
+	cfg.Output.ProcessedFile = filepath.Join(dir, "processed.jsonl")
+	cfg.Output.FailedFile = filepath.Join(dir, "failed.jsonl")
+	cfg.Output.SQLite = testSQLiteConfig(filepath.Join(dir, "pipeline.db"))
+	db := openTestSQLite(t, cfg.Output.SQLite.Path)
+
+	p, err := DefaultBuilder(cfg).Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	metrics, _ := p.Run(context.Background())
+
+	if n := countRows(t, db, "processed_records"); n != metrics.ProcessedCount {
+		t.Errorf("Expected %d processed rows, got %d", metrics.ProcessedCount, n)
+	}
+	if n := countRows(t, db, "failed_records"); n != metrics.ErrorCount || n == 0 {
+		t.Errorf("Expected %d failed rows, got %d", metrics.ErrorCount, n)
+	}
+	var status string
+	if err := db.QueryRow("SELECT status FROM failed_records LIMIT 1").Scan(&status); err != nil || status == "" {
+		t.Errorf("Expected failed rows to keep their status, got %q (%v)", status, err)
+	}
+}
+
+func TestSQLiteConfigValidation(t *testing.T) {
+	for yaml, want := range map[string]string{
+		"output:\n  sqlite:\n    path: out.db\n    processed_table: \"x; DROP\"\n":    "output.sqlite.processed_table",
+		"output:\n  sqlite:\n    path: out.db\n    failed_table: processed_records\n": "devem ser diferentes",
+		"output:\n  sqlite:\n    path: out.db\n    batch_size: 0\n":                   "output.sqlite.batch_size",
+		"output:\n  sqlite:\n    path: out.db\n    flush_interval: -1s\n":             "output.sqlite.flush_interval",
+		"output:\n  sqlite:\n    path: out.db\n    retry:\n      max_attempts: 0\n":   "output.sqlite.retry.max_attempts",
+		"output:\n  sqlite:\n    path: failed_data.jsonl\n":                           "output.sqlite.path",
+	} {
+		if _, err := ParseConfig([]byte(yaml)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resultados registrados em pipeline_stage_records_total.
+const (
+	resultIn    = "in"
+	resultOut   = "out"
+	resultError = "error"
+	resultRetry = "retry" // Nova tentativa após falha transitória
+)
+
+// latencyBuckets são os limites (em segundos) do histograma de latência por etapa.
+var latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Telemetry acumula métricas da pipeline em tempo real e as expõe no formato de texto
+// do Prometheus. É seguro para uso concorrente e pode ser servido como http.Handler.
+type Telemetry struct {
+	mu           sync.Mutex
+	stageRecords map[[2]string]uint64 // {etapa, resultado}
+	latencies    map[string]*histogram
+	anomalies    map[[2]string]uint64 // {sensor_id, location}
+	channels     map[string]func() (int, int)
+	sinkWrites   map[string]*histogram // Duração das escritas por ObservedSink
+	sinkRecords  map[string]uint64     // Registros gravados por ObservedSink
+	dropped      map[[2]string]uint64  // Registros descartados pela política de backpressure, por canal e política
+	spilled      map[string]uint64     // Registros enviados ao disco pela política spill, por canal
+	late         map[string]uint64     // Registros atrasados, por destino (ver WatermarkConfig)
+	watermark    time.Time             // Watermark do tempo dos eventos; zero quando desativado
+	// Resequenciador (ver ResequenceConfig); resequenceWait é nil quando desativado
+	resequenceWait     *histogram
+	resequenceBuffered int
+	resequenceForced   uint64 // Lacunas abandonadas com o buffer cheio
+	resequenceLate     uint64 // Registros emitidos fora de ordem após o abandono de sua lacuna
+}
+
+// Ocorrências registradas por recordResequence.
+const (
+	resequenceForced = "forced"
+	resequenceLate   = "late"
+)
+
+// histogram acumula observações em latencyBuckets.
+type histogram struct {
+	counts []uint64 // Contagem por bucket (não cumulativa)
+	sum    float64
+	count  uint64
+	max    float64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(latencyBuckets))}
+}
+
+func (h *histogram) observe(seconds float64) {
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+	if seconds > h.max {
+		h.max = seconds
+	}
+}
+
+// writeHistogram escreve h no formato de texto do Prometheus, com o label label=value ou,
+// se label for vazio, sem labels além de le.
+func writeHistogram(w io.Writer, metric, label, value string, h *histogram) {
+	labels, prefix := "", ""
+	if label != "" {
+		labels = "{" + label + "=" + quoteLabel(value) + "}"
+		prefix = label + "=" + quoteLabel(value) + ","
+	}
+	var cumulative uint64
+	for i, bound := range latencyBuckets {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%sle=\"%g\"} %d\n", metric, prefix, bound, cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", metric, prefix, h.count)
+	fmt.Fprintf(w, "%s_sum%s %g\n", metric, labels, h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", metric, labels, h.count)
+}
+
+// NewTelemetry cria um Telemetry vazio.
+func NewTelemetry() *Telemetry {
+	return &Telemetry{
+		stageRecords: make(map[[2]string]uint64),
+		latencies:    make(map[string]*histogram),
+		anomalies:    make(map[[2]string]uint64),
+		channels:     make(map[string]func() (int, int)),
+		sinkWrites:   make(map[string]*histogram),
+		sinkRecords:  make(map[string]uint64),
+		dropped:      make(map[[2]string]uint64),
+		spilled:      make(map[string]uint64),
+		late:         make(map[string]uint64),
+	}
+}
+
+// recordStage incrementa o contador de registros de uma etapa.
+func (t *Telemetry) recordStage(stage, result string) {
+	t.mu.Lock()
+	t.stageRecords[[2]string{stage, result}]++
+	t.mu.Unlock()
+}
+
+// observeLatency registra o tempo de processamento de um registro em uma etapa.
+func (t *Telemetry) observeLatency(stage string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.latencies[stage]
+	if !ok {
+		h = newHistogram()
+		t.latencies[stage] = h
+	}
+	h.observe(d.Seconds())
+}
+
+// observeSinkWrite registra uma escrita de records registros concluída por um ObservedSink.
+func (t *Telemetry) observeSinkWrite(sink string, records int, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.sinkWrites[sink]
+	if !ok {
+		h = newHistogram()
+		t.sinkWrites[sink] = h
+	}
+	h.observe(d.Seconds())
+	t.sinkRecords[sink] += uint64(records)
+}
+
+// observeResequenceWait registra quanto tempo um registro ficou retido no resequenciador e
+// quantos continuam retidos.
+func (t *Telemetry) observeResequenceWait(d time.Duration, buffered int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.resequenceWait == nil {
+		t.resequenceWait = newHistogram()
+	}
+	t.resequenceWait.observe(d.Seconds())
+	t.resequenceBuffered = buffered
+}
+
+// recordResequence conta uma lacuna abandonada (resequenceForced) ou um registro emitido
+// fora de ordem (resequenceLate).
+func (t *Telemetry) recordResequence(kind string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if kind == resequenceForced {
+		t.resequenceForced++
+	} else {
+		t.resequenceLate++
+	}
+}
+
+// recordDrop conta um registro descartado no canal channel pela política policy.
+func (t *Telemetry) recordDrop(channel, policy string) {
+	t.mu.Lock()
+	t.dropped[[2]string{channel, policy}]++
+	t.mu.Unlock()
+}
+
+// recordSpill conta um registro enviado ao disco no canal channel.
+func (t *Telemetry) recordSpill(channel string) {
+	t.mu.Lock()
+	t.spilled[channel]++
+	t.mu.Unlock()
+}
+
+// recordLate conta um registro atrasado enviado a destination: lateAllowed ou uma das políticas
+// de watermark.late_policy.
+func (t *Telemetry) recordLate(destination string) {
+	t.mu.Lock()
+	t.late[destination]++
+	t.mu.Unlock()
+}
+
+// observeWatermark registra o watermark atual do tempo dos eventos.
+func (t *Telemetry) observeWatermark(watermark time.Time) {
+	t.mu.Lock()
+	t.watermark = watermark
+	t.mu.Unlock()
+}
+
+// lateRecords retorna os registros atrasados registrados por recordLate por destino, ou nil
+// se nenhum registro chegou atrasado.
+func (t *Telemetry) lateRecords() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.late) == 0 {
+		return nil
+	}
+	late := make(map[string]int, len(t.late))
+	for destination, n := range t.late {
+		late[destination] = int(n)
+	}
+	return late
+}
+
+// droppedRecords soma os descartes registrados por recordDrop por canal, ou retorna nil se
+// nenhum registro foi descartado.
+func (t *Telemetry) droppedRecords() map[string]int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.dropped) == 0 {
+		return nil
+	}
+	dropped := make(map[string]int)
+	for key, n := range t.dropped {
+		dropped[key[0]] += int(n)
+	}
+	return dropped
+}
+
+// sinkWriteStats resume as escritas registradas por observeSinkWrite, ou retorna nil se
+// nenhum ObservedSink gravou.
+func (t *Telemetry) sinkWriteStats() map[string]SinkWriteStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.sinkWrites) == 0 {
+		return nil
+	}
+	stats := make(map[string]SinkWriteStats, len(t.sinkWrites))
+	for sink, h := range t.sinkWrites {
+		stats[sink] = SinkWriteStats{
+			Writes:  int(h.count),
+			Records: int(t.sinkRecords[sink]),
+			Total:   time.Duration(h.sum * float64(time.Second)),
+			Max:     time.Duration(h.max * float64(time.Second)),
+		}
+	}
+	return stats
+}
+
+// recordAnomaly incrementa o contador de anomalias de um sensor e localização.
+func (t *Telemetry) recordAnomaly(sensorID, location string) {
+	t.mu.Lock()
+	t.anomalies[[2]string{sensorID, location}]++
+	t.mu.Unlock()
+}
+
+// registerChannel passa a expor a ocupação e a capacidade de um canal.
+func registerChannel[T any](t *Telemetry, name string, ch chan T) {
+	t.registerQueue(name, func() (int, int) { return len(ch), cap(ch) })
+}
+
+// registerQueue passa a expor a ocupação e a capacidade informadas por depth, para filas que
+// não são um canal com buffer (ver edge).
+func (t *Telemetry) registerQueue(name string, depth func() (int, int)) {
+	t.mu.Lock()
+	t.channels[name] = depth
+	t.mu.Unlock()
+}
+
+// ServeHTTP escreve as métricas no formato de texto do Prometheus.
+func (t *Telemetry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	t.writeMetrics(bw)
+	_ = bw.Flush()
+}
+
+// writeMetrics escreve as métricas atuais no formato de texto do Prometheus.
+func (t *Telemetry) writeMetrics(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP pipeline_stage_records_total Registros recebidos (in), emitidos (out), com erro (error) e novas tentativas (retry) por etapa.")
+	fmt.Fprintln(w, "# TYPE pipeline_stage_records_total counter")
+	for _, key := range sortedPairs(t.stageRecords) {
+		fmt.Fprintf(w, "pipeline_stage_records_total{stage=%s,result=%s} %d\n",
+			quoteLabel(key[0]), quoteLabel(key[1]), t.stageRecords[key])
+	}
+
+	fmt.Fprintln(w, "# HELP pipeline_stage_duration_seconds Tempo de processamento de um registro por etapa.")
+	fmt.Fprintln(w, "# TYPE pipeline_stage_duration_seconds histogram")
+	stages := make([]string, 0, len(t.latencies))
+	for stage := range t.latencies {
+		stages = append(stages, stage)
+	}
+	sort.Strings(stages)
+	for _, stage := range stages {
+		writeHistogram(w, "pipeline_stage_duration_seconds", "stage", stage, t.latencies[stage])
+	}
+
+	fmt.Fprintln(w, "# HELP pipeline_sink_write_duration_seconds Duração de cada escrita em lote por Sink.")
+	fmt.Fprintln(w, "# TYPE pipeline_sink_write_duration_seconds histogram")
+	sinks := make([]string, 0, len(t.sinkWrites))
+	for sink := range t.sinkWrites {
+		sinks = append(sinks, sink)
+	}
+	sort.Strings(sinks)
+	for _, sink := range sinks {
+		writeHistogram(w, "pipeline_sink_write_duration_seconds", "sink", sink, t.sinkWrites[sink])
+	}
+	fmt.Fprintln(w, "# HELP pipeline_sink_written_records_total Registros gravados por Sink em escritas em lote.")
+	fmt.Fprintln(w, "# TYPE pipeline_sink_written_records_total counter")
+	for _, sink := range sinks {
+		fmt.Fprintf(w, "pipeline_sink_written_records_total{sink=%s} %d\n", quoteLabel(sink), t.sinkRecords[sink])
+	}
+
+	if t.resequenceWait != nil {
+		fmt.Fprintln(w, "# HELP pipeline_resequencer_wait_seconds Tempo que cada registro ficou retido no resequenciador.")
+		fmt.Fprintln(w, "# TYPE pipeline_resequencer_wait_seconds histogram")
+		writeHistogram(w, "pipeline_resequencer_wait_seconds", "", "", t.resequenceWait)
+		fmt.Fprintln(w, "# HELP pipeline_resequencer_buffered Registros retidos à espera de um registro anterior.")
+		fmt.Fprintln(w, "# TYPE pipeline_resequencer_buffered gauge")
+		fmt.Fprintf(w, "pipeline_resequencer_buffered %d\n", t.resequenceBuffered)
+		fmt.Fprintln(w, "# HELP pipeline_resequencer_abandoned_gaps_total Lacunas abandonadas porque o buffer encheu.")
+		fmt.Fprintln(w, "# TYPE pipeline_resequencer_abandoned_gaps_total counter")
+		fmt.Fprintf(w, "pipeline_resequencer_abandoned_gaps_total %d\n", t.resequenceForced)
+		fmt.Fprintln(w, "# HELP pipeline_resequencer_late_records_total Registros emitidos fora de ordem após o abandono de sua lacuna.")
+		fmt.Fprintln(w, "# TYPE pipeline_resequencer_late_records_total counter")
+		fmt.Fprintf(w, "pipeline_resequencer_late_records_total %d\n", t.resequenceLate)
+	}
+
+	if !t.watermark.IsZero() {
+		fmt.Fprintln(w, "# HELP pipeline_event_time_watermark_seconds Watermark do tempo dos eventos, em segundos desde a época Unix.")
+		fmt.Fprintln(w, "# TYPE pipeline_event_time_watermark_seconds gauge")
+		fmt.Fprintf(w, "pipeline_event_time_watermark_seconds %g\n", float64(t.watermark.UnixNano())/1e9)
+	}
+	if len(t.late) > 0 {
+		fmt.Fprintln(w, "# HELP pipeline_late_records_total Registros anteriores ao watermark, por destino (allowed segue para os Sinks).")
+		fmt.Fprintln(w, "# TYPE pipeline_late_records_total counter")
+		for _, destination := range sortedKeys(t.late) {
+			fmt.Fprintf(w, "pipeline_late_records_total{destination=%s} %d\n", quoteLabel(destination), t.late[destination])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP pipeline_channel_depth Registros aguardando em cada canal entre etapas.")
+	fmt.Fprintln(w, "# TYPE pipeline_channel_depth gauge")
+	names := make([]string, 0, len(t.channels))
+	for name := range t.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		depth, _ := t.channels[name]()
+		fmt.Fprintf(w, "pipeline_channel_depth{channel=%s} %d\n", quoteLabel(name), depth)
+	}
+	fmt.Fprintln(w, "# HELP pipeline_channel_capacity Capacidade do buffer de cada canal entre etapas.")
+	fmt.Fprintln(w, "# TYPE pipeline_channel_capacity gauge")
+	for _, name := range names {
+		_, capacity := t.channels[name]()
+		fmt.Fprintf(w, "pipeline_channel_capacity{channel=%s} %d\n", quoteLabel(name), capacity)
+	}
+
+	fmt.Fprintln(w, "# HELP pipeline_channel_dropped_total Registros descartados pela política de backpressure de cada canal.")
+	fmt.Fprintln(w, "# TYPE pipeline_channel_dropped_total counter")
+	for _, key := range sortedPairs(t.dropped) {
+		fmt.Fprintf(w, "pipeline_channel_dropped_total{channel=%s,policy=%s} %d\n",
+			quoteLabel(key[0]), quoteLabel(key[1]), t.dropped[key])
+	}
+	fmt.Fprintln(w, "# HELP pipeline_channel_spilled_total Registros enviados ao disco pela política spill de cada canal.")
+	fmt.Fprintln(w, "# TYPE pipeline_channel_spilled_total counter")
+	for _, name := range sortedKeys(t.spilled) {
+		fmt.Fprintf(w, "pipeline_channel_spilled_total{channel=%s} %d\n", quoteLabel(name), t.spilled[name])
+	}
+
+	fmt.Fprintln(w, "# HELP pipeline_anomalies_total Registros anômalos por sensor e localização.")
+	fmt.Fprintln(w, "# TYPE pipeline_anomalies_total counter")
+	for _, key := range sortedPairs(t.anomalies) {
+		fmt.Fprintf(w, "pipeline_anomalies_total{sensor_id=%s,location=%s} %d\n",
+			quoteLabel(key[0]), quoteLabel(key[1]), t.anomalies[key])
+	}
+}
+
+// sortedPairs retorna as chaves de m em ordem estável.
+func sortedPairs(m map[[2]string]uint64) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+// labelEscaper aplica o escape exigido pelo formato de texto em valores de labels.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+// channelName deriva o nome do canal de entrada de uma etapa (ex.: Loader -> loaderCh).
+func channelName(stage string) string {
+	if stage == "" {
+		return "ch"
+	}
+	return strings.ToLower(stage[:1]) + stage[1:] + "Ch"
+}
+
+// splitEndpoint separa um endpoint no formato "host:porta/caminho" em endereço e caminho.
+// Sem caminho, defaultPath é usado.
+func splitEndpoint(endpoint, defaultPath string) (string, string) {
+	if i := strings.Index(endpoint, "/"); i >= 0 {
+		return endpoint[:i], endpoint[i:]
+	}
+	return endpoint, defaultPath
+}
+
+// StartMetricsServer expõe telemetry em endpoint (ex.: ":9090/metrics", como em
+// metrics.prometheus_endpoint). A porta é aberta antes do retorno, para que erros de bind
+// sejam reportados; o servidor roda em segundo plano até Shutdown.
+func StartMetricsServer(endpoint string, telemetry *Telemetry) (*http.Server, error) {
+	addr, path := splitEndpoint(endpoint, "/metrics")
+	mux := http.NewServeMux()
+	mux.Handle(path, telemetry)
+	return startServer("Metrics", addr, mux)
+}
+
+// startServer abre addr e serve handler em segundo plano.
+func startServer(name, addr string, handler http.Handler) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("%s: falha ao abrir %s: %w", name, addr, err)
+	}
+	// Addr guarda o endereço efetivo, útil quando a porta é escolhida pelo sistema (":0")
+	srv := &http.Server{Addr: listener.Addr().String(), Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errorf("%s: Servidor HTTP finalizado com erro: %v", name, err)
+		}
+	}()
+	infof("%s: Servindo em %s", name, listener.Addr())
+	return srv, nil
+}
@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// anomalyFlag marca como anômalos os registros com Value acima de 100.
+type anomalyFlag struct{}
+
+func (anomalyFlag) Name() string { return "AnomalyFlag" }
+
+func (anomalyFlag) Process(_ context.Context, record ProcessedRecord) (ProcessedRecord, error) {
+	record.IsAnomaly = record.Value > 100
+	return record, nil
+}
+
+func scrape(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Expected text/plain content type, got %q", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read metrics: %v", err)
+	}
+	return string(body)
+}
+
+func TestTelemetryDuringRun(t *testing.T) {
+	source := sliceSource{records: []DataRecord{
+		{ID: "t-1", SensorID: "sensor-1", Location: "north", Value: 10},
+		{ID: "t-2", SensorID: "sensor-1", Location: "north", Value: 150},
+		{ID: "t-3", SensorID: "sensor-2", Location: "", Value: 200},
+		{ID: "t-4", SensorID: "sensor-2", Location: "south", Value: 300},
+	}}
+	sink := &memorySink{}
+	telemetry := NewTelemetry()
+
+	p, err := NewBuilder(DefaultConfig()).
+		WithSource(source).
+		AddProcessor(upperLocation{}).
+		AddProcessor(anomalyFlag{}).
+		AddSink(sink).
+		AddErrorSink(sink).
+		WithTelemetry(telemetry).
+		Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	if p.Telemetry() != telemetry {
+		t.Fatal("Expected pipeline to use the provided telemetry")
+	}
+	p.Run(context.Background())
+
+	body := scrape(t, telemetry)
+	for _, want := range []string{
+		`pipeline_stage_records_total{stage="SliceSource",result="out"} 4`,
+		`pipeline_stage_records_total{stage="UpperLocation",result="in"} 4`,
+		`pipeline_stage_records_total{stage="UpperLocation",result="out"} 3`,
+		`pipeline_stage_records_total{stage="UpperLocation",result="error"} 1`,
+		`pipeline_stage_records_total{stage="AnomalyFlag",result="in"} 3`,
+		`pipeline_stage_records_total{stage="MemorySink",result="in"} 4`,
+		`pipeline_stage_duration_seconds_count{stage="UpperLocation"} 4`,
+		`pipeline_stage_duration_seconds_bucket{stage="AnomalyFlag",le="+Inf"} 3`,
+		`pipeline_channel_capacity{channel="dataCh"} 100`,
+		`pipeline_channel_depth{channel="anomalyFlagCh"} 0`,
+		`pipeline_channel_depth{channel="memorySinkCh"} 0`,
+		`pipeline_channel_depth{channel="errorCh"} 0`,
+		`pipeline_anomalies_total{sensor_id="sensor-1",location="NORTH"} 1`,
+		`pipeline_anomalies_total{sensor_id="sensor-2",location="SOUTH"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestTelemetryHistogramAndLabels(t *testing.T) {
+	telemetry := NewTelemetry()
+	telemetry.observeLatency("Slow", 2*time.Millisecond)
+	telemetry.observeLatency("Slow", 2*time.Second)
+	telemetry.recordAnomaly(`sensor-"1"`, "a\\b\nc")
+
+	body := scrape(t, telemetry)
+	for _, want := range []string{
+		`pipeline_stage_duration_seconds_bucket{stage="Slow",le="0.001"} 0`,
+		`pipeline_stage_duration_seconds_bucket{stage="Slow",le="0.0025"} 1`,
+		`pipeline_stage_duration_seconds_bucket{stage="Slow",le="2.5"} 2`,
+		`pipeline_stage_duration_seconds_count{stage="Slow"} 2`,
+		`pipeline_anomalies_total{sensor_id="sensor-\"1\"",location="a\\b\nc"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected metrics to contain %q, got:\n%s", want, body)
+		}
+	}
+}
+
+func TestStartMetricsServer(t *testing.T) {
+	telemetry := NewTelemetry()
+	telemetry.recordStage("Validator", resultIn)
+
+	srv, err := StartMetricsServer("127.0.0.1:0/custom", telemetry)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer srv.Close()
+
+	resp, err := http.Get("http://" + srv.Addr + "/custom")
+	if err != nil {
+		t.Fatalf("Failed to scrape metrics: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), `pipeline_stage_records_total{stage="Validator",result="in"} 1`) {
+		t.Errorf("Unexpected metrics body:\n%s", body)
+	}
+
+	if addr, path := splitEndpoint(":9090", "/metrics"); addr != ":9090" || path != "/metrics" {
+		t.Errorf("Expected default path, got %q %q", addr, path)
+	}
+	if _, err := StartMetricsServer("invalid-address/metrics", telemetry); err == nil {
+		t.Error("Expected error for invalid address")
+	}
+}
@@ -0,0 +1,150 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// txnWriter é o writer dos TransactionalSinks. Os registros vão para um segmento temporário
+// (<nome>-<seq><ext>.tmp); a cada Transaction.Interval e ao fechar, o segmento é sincronizado
+// e entregue a Transaction.Prepare, que o publica como <nome>-<seq><ext> no próximo commit.
+// Implementa recordWriter e sinkCommitter.
+type txnWriter struct {
+	name string // Etapa, para os logs
+	base string // path sem a extensão
+	ext  string
+	txn  Transaction
+	next int // Número do próximo segmento
+
+	file   *os.File // Segmento temporário aberto; nil até a primeira escrita
+	temp   string
+	seqs   []uint64
+	ticker *time.Ticker
+}
+
+// openTxnWriter descarta os segmentos temporários deixados por commits não concluídos e
+// continua a numeração após os segmentos já publicados de path.
+func openTxnWriter(name, path string, txn Transaction) (*txnWriter, error) {
+	ext := filepath.Ext(path)
+	w := &txnWriter{name: name, base: strings.TrimSuffix(path, ext), ext: ext, txn: txn, next: 1}
+	stale, err := filepath.Glob(w.base + "-*" + ext + ".tmp")
+	if err != nil {
+		return nil, err
+	}
+	for _, temp := range stale {
+		if err := os.Remove(temp); err != nil {
+			return nil, err
+		}
+	}
+	if len(stale) > 0 {
+		infof("%s: Descartados %d segmentos temporários sem commit", name, len(stale))
+	}
+	published, err := filepath.Glob(w.base + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range published {
+		var n int
+		if _, err := fmt.Sscanf(strings.TrimPrefix(segment, w.base+"-"), "%d", &n); err == nil && n >= w.next {
+			w.next = n + 1
+		}
+	}
+	if txn.Interval > 0 {
+		w.ticker = time.NewTicker(txn.Interval)
+	}
+	return w, nil
+}
+
+// segmentPath retorna o nome publicado do segmento n.
+func (w *txnWriter) segmentPath(n int) string {
+	return fmt.Sprintf("%s-%06d%s", w.base, n, w.ext)
+}
+
+func (w *txnWriter) Write(line []byte) error {
+	if w.file == nil {
+		w.temp = w.segmentPath(w.next) + ".tmp"
+		file, err := os.Create(w.temp)
+		if err != nil {
+			return err
+		}
+		w.file = file
+	}
+	if _, err := w.file.Write(line); err != nil {
+		// Uma linha parcial não pode ser publicada: o segmento é descartado e seus registros
+		// ficam sem confirmação, para serem lidos novamente na próxima execução
+		w.discard()
+		return err
+	}
+	return nil
+}
+
+func (w *txnWriter) Tick() error { return nil }
+
+// Sync prepara o segmento atual.
+func (w *txnWriter) Sync() error {
+	w.sync()
+	return nil
+}
+
+// Close prepara o segmento atual e libera o ticker.
+func (w *txnWriter) Close() error {
+	if w.ticker != nil {
+		w.ticker.Stop()
+	}
+	w.sync()
+	return nil
+}
+
+func (w *txnWriter) written(seq uint64) { w.seqs = append(w.seqs, seq) }
+
+func (w *txnWriter) ticks() <-chan time.Time {
+	if w.ticker == nil {
+		return nil
+	}
+	return w.ticker.C
+}
+
+// sync sincroniza e fecha o segmento temporário e o entrega a Transaction.Prepare; a próxima
+// escrita abre o segmento seguinte.
+func (w *txnWriter) sync() {
+	if w.file == nil {
+		if len(w.seqs) > 0 { // Apenas registros que não puderam ser serializados
+			w.txn.Prepare(PreparedSegment{Seqs: w.seqs})
+			w.seqs = nil
+		}
+		return
+	}
+	if err := w.file.Sync(); err != nil {
+		errorf("%s: Erro ao sincronizar %s, descartando o segmento: %v", w.name, w.temp, err)
+		w.discard()
+		return
+	}
+	if err := w.file.Close(); err != nil {
+		errorf("%s: Erro ao fechar %s, descartando o segmento: %v", w.name, w.temp, err)
+		w.file = nil
+		w.discard()
+		return
+	}
+	w.txn.Prepare(PreparedSegment{Temp: w.temp, Final: w.segmentPath(w.next), Seqs: w.seqs})
+	w.file, w.seqs = nil, nil
+	w.next++
+}
+
+func (w *txnWriter) closed(error) {}
+
+// discard remove o segmento temporário atual sem confirmar seus registros.
+func (w *txnWriter) discard() {
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	_ = os.Remove(w.temp)
+	w.seqs = nil
+}
@@ -5,39 +5,83 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
 	"log"
 	"math/rand"
 	"time"
 )
 
-// Transformer transforma os registros de dados válidos.
-func Transformer(in <-chan DataRecord, out chan<- ProcessedRecord, errCh chan<- DataRecord) {
+// Transformer transforma os registros de dados válidos conforme cfg.
+// Abandona o processamento quando ctx é cancelado.
+func Transformer(ctx context.Context, in <-chan DataRecord, out chan<- ProcessedRecord, errCh chan<- DataRecord, cfg TransformerConfig) {
+	transformer, err := NewTransformerProcessor(cfg)
+	if err != nil {
+		log.Fatalf("Transformer: Detector de anomalias inválido: %v", err)
+	}
 	for record := range in {
-		log.Printf("Transformer: Transformando registro %s", record.ID)
-		// Simular uma transformação mais complexa: cálculo de score de anomalia
-		anomalyScore := record.Value * 0.1 // Exemplo simples
-		isAnomaly := anomalyScore > 8.0   // Se o valor original for > 80
-
-		// Simular erro de transformação
-		if record.Unit == "INVALID_UNIT" {
-			record.Status = "transformation_error"
-			record.Error = "Invalid unit for transformation"
-			errCh <- record
-			log.Printf("Transformer: Erro ao transformar registro %s (Invalid Unit)", record.ID)
-			continue
+		if ctx.Err() != nil {
+			warnf("Transformer: Transformação abortada (%v)", ctx.Err())
+			return
 		}
-
-		processedRecord := ProcessedRecord{
-			DataRecord:  record,
-			ProcessedAt: time.Now(),
-			AnomalyScore: anomalyScore,
-			IsAnomaly:    isAnomaly,
+		result, err := transformer.Process(ctx, ProcessedRecord{DataRecord: record})
+		if err != nil {
+			if !send(ctx, errCh, result.DataRecord) {
+				return
+			}
+		} else if !send(ctx, out, result) {
+			return
 		}
-		processedRecord.Status = "processed"
-		out <- processedRecord
-		log.Printf("Transformer: Registro %s transformado (AnomalyScore: %.2f)", record.ID, anomalyScore)
-		time.Sleep(time.Duration(rand.Intn(30)) * time.Millisecond)
 	}
-	log.Println("Transformer: Transformação de dados finalizada.")
+	infof("Transformer: Transformação de dados finalizada.")
+}
+
+// TransformerProcessor é o Processor padrão, que calcula o score de anomalia.
+// O estado dos detectores estatísticos é compartilhado entre todos os workers.
+type TransformerProcessor struct {
+	cfg      TransformerConfig
+	detector AnomalyDetector
+}
+
+// NewTransformerProcessor cria o Transformer com o detector selecionado em cfg.Detector.
+func NewTransformerProcessor(cfg TransformerConfig) (*TransformerProcessor, error) {
+	detector, err := NewAnomalyDetector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &TransformerProcessor{cfg: cfg, detector: detector}, nil
 }
 
+// Name identifica a etapa nos logs.
+func (t *TransformerProcessor) Name() string { return "Transformer" }
+
+// Process transforma um registro válido em um registro processado.
+func (t *TransformerProcessor) Process(_ context.Context, in ProcessedRecord) (ProcessedRecord, error) {
+	cfg := t.cfg
+	record := in.DataRecord
+	debugf("Transformer: Transformando registro %s", record.ID)
+
+	// Unidades fora de cfg.ValidUnits não podem ser transformadas
+	if len(cfg.ValidUnits) > 0 && !containsString(cfg.ValidUnits, record.Unit) {
+		record.Status = "transformation_error"
+		record.Error = "Invalid unit for transformation"
+		record.ErrorCodes = []string{ErrCodeInvalidUnit}
+		debugf("Transformer: Erro ao transformar registro %s (Invalid Unit)", record.ID)
+		return ProcessedRecord{DataRecord: record}, errors.New(record.Error)
+	}
+
+	// Cálculo do score de anomalia; registros com erro não entram no histórico do sensor
+	anomalyScore, isAnomaly := t.detector.Observe(record.SensorID, record.Value)
+
+	processedRecord := ProcessedRecord{
+		DataRecord:  record,
+		ProcessedAt: time.Now(),
+		AnomalyScore: anomalyScore,
+		IsAnomaly:    isAnomaly,
+		AnomalyDetector: t.detector.Name(),
+	}
+	processedRecord.Status = "processed"
+	debugf("Transformer: Registro %s transformado (AnomalyScore: %.2f)", record.ID, anomalyScore)
+	time.Sleep(time.Duration(rand.Intn(30)) * time.Millisecond)
+	return processedRecord, nil
+}
@@ -18,14 +18,32 @@ type DataRecord struct {
 	Location  string    `json:"location"`
 	Status    string    `json:"status"` // Adicionado para indicar status após processamento
 	Error     string    `json:"error,omitempty"` // Para registrar erros específicos
+	ErrorCodes []string `json:"error_codes,omitempty"` // Códigos estáveis das falhas (ver rules.go)
+	SourceLine int      `json:"source_line,omitempty"` // Linha de origem quando lido de arquivo
+	Raw        string   `json:"raw,omitempty"`         // Texto original de linhas que não puderam ser interpretadas
+	SourceFormat string         `json:"source_format,omitempty"` // Formato de Raw (source.type jsonl ou csv); vazio é jsonl
+	Attempts     int            `json:"attempts,omitempty"`      // Tentativas feitas na etapa que falhou por último
+	ErrorHistory []AttemptError `json:"error_history,omitempty"` // Falhas de cada tentativa, em ordem
+	ReplayLine   int            `json:"replay_line,omitempty"`   // Linha do arquivo de dead-letter quando reprocessado (ver RunReplay)
+	Seq          uint64         `json:"-"`                       // Posição na saída da Source, a partir de 1; atribuída por Run (ver resequencer)
 }
 
 // ProcessedRecord representa um registro após a transformação.
+//
+// O significado de AnomalyScore depende de AnomalyDetector (transformer.detector):
+//   - threshold: Value * anomaly_score_multiplier; IsAnomaly quando o score excede anomaly_threshold
+//   - zscore: |Value - média| / desvio padrão dos últimos window_size valores do mesmo SensorID
+//   - ewma: |Value - média exponencial| / desvio padrão exponencial do mesmo SensorID
+//   - mad: 0.6745 * |Value - mediana| / desvio absoluto mediano dos últimos window_size valores
+//
+// Nos detectores estatísticos o score compara o valor ao histórico anterior do sensor (sem incluí-lo),
+// vale 0 enquanto o sensor tem menos de min_samples observações, e IsAnomaly indica score > z_threshold.
 type ProcessedRecord struct {
 	DataRecord
 	ProcessedAt time.Time `json:"processed_at"`
 	AnomalyScore float64   `json:"anomaly_score"`
 	IsAnomaly    bool      `json:"is_anomaly"`
+	AnomalyDetector string `json:"anomaly_detector,omitempty"`
 }
 
 // Metrics representa as métricas coletadas da pipeline.
@@ -34,5 +52,36 @@ type Metrics struct {
 	ErrorCount     int
 	AnomalyCount   int
 	TotalValue     float64
+	SinkWrites     map[string]SinkWriteStats // Escritas por ObservedSink, pelo nome do Sink
+	Dropped        map[string]int            // Registros descartados pela política de backpressure, pelo nome do canal
+	Late           map[string]int            // Registros anteriores ao watermark, por destino (ver WatermarkConfig)
 }
 
+// SinkWriteStats resume as escritas em lote de um ObservedSink.
+type SinkWriteStats struct {
+	Writes  int           // Escritas concluídas
+	Records int           // Registros gravados
+	Total   time.Duration // Soma das durações
+	Max     time.Duration // Escrita mais lenta
+}
+
+// Mean retorna a duração média de uma escrita.
+func (s SinkWriteStats) Mean() time.Duration {
+	if s.Writes == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Writes)
+}
+
+
+// StopReason indica por que RunPipeline terminou.
+type StopReason string
+
+const (
+	// StopCompleted indica que todos os registros da fonte foram processados.
+	StopCompleted StopReason = "completed"
+	// StopCancelled indica que o contexto foi cancelado e os registros em trânsito foram drenados.
+	StopCancelled StopReason = "cancelled"
+	// StopDrainTimeout indica que a drenagem excedeu advanced.shutdown_timeout e foi abortada.
+	StopDrainTimeout StopReason = "drain_timeout"
+)
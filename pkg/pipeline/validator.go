@@ -5,26 +5,70 @@
 package pipeline
 
 import (
+	"context"
+	"errors"
 	"log"
 	"math/rand"
 	"time"
 )
 
-// Validator valida os registros de dados.
-func Validator(in <-chan DataRecord, validCh chan<- DataRecord, errorCh chan<- DataRecord) {
+// Validator valida os registros de dados conforme cfg.
+// Abandona o processamento quando ctx é cancelado.
+func Validator(ctx context.Context, in <-chan DataRecord, validCh chan<- DataRecord, errorCh chan<- DataRecord, cfg ValidatorConfig) {
+	validator, err := NewValidatorProcessor(cfg)
+	if err != nil {
+		log.Fatalf("Validator: Regras de validação inválidas: %v", err)
+	}
 	for record := range in {
-		log.Printf("Validator: Validando registro %s", record.ID)
-		if record.Value < 0 || record.Value > 1000 { // Exemplo de regra de validação
-			record.Status = "invalid"
-			record.Error = "Value out of expected range (0-1000)"
-			errorCh <- record
-			log.Printf("Validator: Registro %s inválido (Value: %.2f)", record.ID, record.Value)
-		} else {
-			validCh <- record
-			log.Printf("Validator: Registro %s válido", record.ID)
+		if ctx.Err() != nil {
+			warnf("Validator: Validação abortada (%v)", ctx.Err())
+			return
+		}
+		result, err := validator.Process(ctx, ProcessedRecord{DataRecord: record})
+		if err != nil {
+			if !send(ctx, errorCh, result.DataRecord) {
+				return
+			}
+		} else if !send(ctx, validCh, result.DataRecord) {
+			return
 		}
-		time.Sleep(time.Duration(rand.Intn(20)) * time.Millisecond)
 	}
-	log.Println("Validator: Validação de dados finalizada.")
+	infof("Validator: Validação de dados finalizada.")
+}
+
+// ValidatorProcessor é o Processor padrão de validação, que aplica um conjunto de regras
+// e reporta todas as que falharem.
+type ValidatorProcessor struct {
+	rules []Rule
+}
+
+// NewValidatorProcessor compila as regras de cfg, acrescidas de regras personalizadas em extra.
+func NewValidatorProcessor(cfg ValidatorConfig, extra ...Rule) (*ValidatorProcessor, error) {
+	rules, err := CompileRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &ValidatorProcessor{rules: append(rules, extra...)}, nil
 }
 
+// Name identifica a etapa nos logs.
+func (v *ValidatorProcessor) Name() string { return "Validator" }
+
+// Process valida um registro, marcando-o como inválido quando alguma regra falha.
+func (v *ValidatorProcessor) Process(_ context.Context, record ProcessedRecord) (ProcessedRecord, error) {
+	debugf("Validator: Validando registro %s", record.ID)
+	defer time.Sleep(time.Duration(rand.Intn(20)) * time.Millisecond)
+
+	var violations []Violation
+	for _, rule := range v.rules {
+		violations = append(violations, rule.Check(record.DataRecord)...)
+	}
+	if len(violations) > 0 {
+		record.Status = "invalid"
+		applyViolations(&record.DataRecord, violations)
+		debugf("Validator: Registro %s inválido (%s)", record.ID, record.Error)
+		return record, errors.New(record.Error)
+	}
+	debugf("Validator: Registro %s válido", record.ID)
+	return record, nil
+}
@@ -0,0 +1,149 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Destinos dos registros que chegam depois do prazo de watermark.allowed_lateness (ver
+// WatermarkConfig).
+const (
+	LateDrop       = "drop"        // O registro é descartado e contado
+	LateSideOutput = "side_output" // O registro vai para a saída lateral (watermark.late_file)
+	LateError      = "error"       // O registro vai para os ErrorSinks com status "late"
+)
+
+// latePolicies lista os destinos aceitos, na ordem usada nas mensagens de validação.
+var latePolicies = []string{LateDrop, LateSideOutput, LateError}
+
+// lateAllowed é o resultado registrado na telemetria para os registros atrasados dentro do
+// prazo, que seguem para os Sinks.
+const lateAllowed = "allowed"
+
+// WatermarkConfig ativa o tempo dos eventos (DataRecord.Timestamp) na pipeline.
+//
+// O watermark é o maior Timestamp recebido menos MaxOutOfOrderness: a pipeline assume que
+// nenhum registro anterior a ele ainda está a caminho. Um registro anterior ao watermark é
+// atrasado; se o atraso não passar de AllowedLateness ele segue para os Sinks e atualiza as
+// janelas já emitidas (ver WindowSink), senão vai para LatePolicy.
+type WatermarkConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	MaxOutOfOrderness time.Duration `yaml:"max_out_of_orderness"`
+	AllowedLateness   time.Duration `yaml:"allowed_lateness"`
+	LatePolicy        string        `yaml:"late_policy"` // drop, side_output ou error
+	LateFile          string        `yaml:"late_file"`   // JSONL da saída lateral (late_policy: side_output)
+}
+
+// eventClock acompanha o watermark de uma sequência de registros.
+type eventClock struct {
+	maxOutOfOrderness time.Duration
+	latest            time.Time // Maior Timestamp recebido
+}
+
+// observe avança o relógio até ts, se ts for o maior Timestamp recebido.
+func (c *eventClock) observe(ts time.Time) {
+	if ts.After(c.latest) {
+		c.latest = ts
+	}
+}
+
+// watermark retorna o instante antes do qual um registro é considerado atrasado, ou o
+// instante zero antes do primeiro registro.
+func (c *eventClock) watermark() time.Time {
+	if c.latest.IsZero() {
+		return time.Time{}
+	}
+	return c.latest.Add(-c.maxOutOfOrderness)
+}
+
+// watermarkName identifica a etapa de watermark nos logs, na telemetria e nas sondas de saúde.
+const watermarkName = "Watermark"
+
+// watermarkStage separa, antes do fan-out, os registros que chegam depois do prazo de
+// watermark.allowed_lateness. Os demais seguem na ordem em que chegaram, inclusive os sem
+// Timestamp, que não podem ser avaliados.
+type watermarkStage struct {
+	cfg       WatermarkConfig
+	telemetry *Telemetry
+	health    *Health
+	late      chan<- DataRecord       // Destino dos atrasados com side_output e error
+	release   func(record DataRecord) // Chamado para cada atrasado descartado ou enviado à saída lateral
+}
+
+// run lê os registros de in e envia os que estão no prazo para out, fechando out ao terminar.
+func (s watermarkStage) run(ctx context.Context, in <-chan ProcessedRecord, out chan<- ProcessedRecord) {
+	defer close(out)
+	clock := eventClock{maxOutOfOrderness: s.cfg.MaxOutOfOrderness}
+	var allowed, late int
+	for record := range in {
+		if ctx.Err() != nil {
+			warnf("%s: Processamento abortado (%v)", watermarkName, ctx.Err())
+			return
+		}
+		s.telemetry.recordStage(watermarkName, resultIn)
+		s.health.touch(watermarkName)
+		ts := record.Timestamp
+		watermark := clock.watermark()
+		if !ts.IsZero() && ts.Before(watermark) {
+			if !ts.Before(watermark.Add(-s.cfg.AllowedLateness)) {
+				allowed++
+				s.telemetry.recordLate(lateAllowed)
+			} else {
+				late++
+				s.telemetry.recordLate(s.cfg.LatePolicy)
+				if !s.divert(ctx, record.DataRecord, watermark) {
+					return
+				}
+				continue
+			}
+		}
+		clock.observe(ts)
+		s.telemetry.observeWatermark(clock.watermark())
+		s.telemetry.recordStage(watermarkName, resultOut)
+		if !send(ctx, out, record) {
+			return
+		}
+	}
+	infof("%s: %d registros atrasados dentro do prazo e %d além dele (%s); watermark final %s",
+		watermarkName, allowed, late, s.cfg.LatePolicy, clock.watermark().Format(time.RFC3339))
+}
+
+// divert envia record, que passou do prazo, para o destino de watermark.late_policy.
+// Retorna false se ctx foi cancelado antes.
+func (s watermarkStage) divert(ctx context.Context, record DataRecord, watermark time.Time) bool {
+	switch s.cfg.LatePolicy {
+	case LateDrop:
+		s.release(record)
+		return true
+	case LateSideOutput:
+		s.release(record) // A saída lateral não participa do checkpoint
+	}
+	record.Status = "late"
+	record.Error = fmt.Sprintf("timestamp %s anterior ao watermark %s além do atraso permitido de %s",
+		record.Timestamp.Format(time.RFC3339Nano), watermark.Format(time.RFC3339Nano), s.cfg.AllowedLateness)
+	record.ErrorCodes = append(record.ErrorCodes, ErrCodeLateRecord)
+	return send(ctx, s.late, record)
+}
+
+// LateFileSink é o ErrorSink padrão da saída lateral (watermark.late_policy: side_output), que
+// grava os registros atrasados em JSONL no arquivo em Path, sobrescrito a cada execução.
+type LateFileSink struct {
+	Path string
+}
+
+// Name identifica a etapa nos logs.
+func (s LateFileSink) Name() string { return "LateWriter" }
+
+// CheckHealth reporta se Path pode receber escrita (ver HealthChecker).
+func (s LateFileSink) CheckHealth() error { return checkWritable(s.Path) }
+
+// ConsumeErrors grava cada registro de in até o canal ser fechado ou ctx ser cancelado.
+func (s LateFileSink) ConsumeErrors(ctx context.Context, in <-chan DataRecord) {
+	written := writeJSONL(ctx, s.Name(), s.Path, in)
+	infof("LateWriter: %d registros atrasados gravados em %s", written, s.Path)
+}
@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// lateSink recebe a saída lateral com um nome próprio, distinto do ErrorSink de memorySink.
+type lateSink struct {
+	*memorySink
+}
+
+func (s lateSink) Name() string { return "LateSink" }
+
+// eventRecords retorna registros do sensor s1 com os instantes dados, em segundos após
+// windowEpoch, e IDs iguais aos instantes.
+func eventRecords(seconds ...int) []DataRecord {
+	records := make([]DataRecord, len(seconds))
+	for i, second := range seconds {
+		record := timedRecord("s1", second, float64(second)).DataRecord
+		record.ID = time.Duration(second * int(time.Second)).String()
+		records[i] = record
+	}
+	return records
+}
+
+// runWatermark executa a pipeline sobre records com o watermark de cfg, resequenciada para
+// preservar a ordem, e retorna os destinos dos registros e as métricas.
+func runWatermark(t *testing.T, wm WatermarkConfig, records []DataRecord) (*memorySink, *memorySink, Metrics) {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Pipeline.Workers = 4
+	cfg.Pipeline.Resequence.Enabled = true
+	cfg.Watermark = wm
+	sink, late := &memorySink{}, &memorySink{}
+	b := NewBuilder(cfg).WithSource(sliceSource{records: records}).AddSink(sink).AddErrorSink(sink)
+	if wm.LatePolicy == LateSideOutput {
+		b.WithLateSink(lateSink{late})
+	}
+	p, err := b.Build()
+	if err != nil {
+		t.Fatalf("Unexpected build error: %v", err)
+	}
+	metrics, _ := p.Run(context.Background())
+	return sink, late, metrics
+}
+
+func processedIDs(records []ProcessedRecord) string {
+	var ids []string
+	for _, record := range records {
+		ids = append(ids, record.ID)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestWatermarkRoutesLateRecords(t *testing.T) {
+	// Watermark = maior instante - 10s: 55s chega no prazo, 42s atrasado dentro do atraso
+	// permitido de 10s, 20s e 5s além dele
+	records := eventRecords(0, 30, 60, 55, 42, 20, 70, 5)
+	wm := WatermarkConfig{Enabled: true, MaxOutOfOrderness: 10 * time.Second, AllowedLateness: 10 * time.Second}
+
+	wm.LatePolicy = LateError
+	sink, _, metrics := runWatermark(t, wm, records)
+	if got := processedIDs(sink.processed); got != "0s,30s,1m0s,55s,42s,1m10s" {
+		t.Errorf("Expected the timely and allowed records in order, got %s", got)
+	}
+	if len(sink.failed) != 2 {
+		t.Fatalf("Expected 2 late records in the error sink, got %+v", sink.failed)
+	}
+	for _, record := range sink.failed {
+		if record.Status != "late" || len(record.ErrorCodes) != 1 || record.ErrorCodes[0] != ErrCodeLateRecord ||
+			!strings.Contains(record.Error, "watermark") {
+			t.Errorf("Expected a late status, code and reason, got %+v", record)
+		}
+	}
+	if metrics.Late[lateAllowed] != 1 || metrics.Late[LateError] != 2 || metrics.ErrorCount != 2 {
+		t.Errorf("Expected 1 allowed and 2 late records, got %v (errors %d)", metrics.Late, metrics.ErrorCount)
+	}
+
+	wm.LatePolicy = LateSideOutput
+	sink, late, metrics := runWatermark(t, wm, records)
+	if len(sink.processed) != 6 || len(sink.failed) != 0 || len(late.failed) != 2 || late.failed[0].ID != "20s" {
+		t.Errorf("Expected the late records in the side output only, got %d processed, %d failed and %+v",
+			len(sink.processed), len(sink.failed), late.failed)
+	}
+	if metrics.Late[LateSideOutput] != 2 {
+		t.Errorf("Expected 2 records sent to the side output, got %v", metrics.Late)
+	}
+
+	wm.LatePolicy = LateDrop
+	sink, _, metrics = runWatermark(t, wm, records)
+	if len(sink.processed) != 6 || len(sink.failed) != 0 || metrics.Late[LateDrop] != 2 {
+		t.Errorf("Expected the late records to be dropped and counted, got %d processed, %d failed and %v",
+			len(sink.processed), len(sink.failed), metrics.Late)
+	}
+}
+
+func TestWatermarkUpdatesEmittedWindows(t *testing.T) {
+	wm := WatermarkConfig{Enabled: true, MaxOutOfOrderness: 5 * time.Second, AllowedLateness: 30 * time.Second}
+	out := &memoryOutput[WindowAggregate]{}
+	in := make(chan ProcessedRecord, 10)
+	for _, record := range []ProcessedRecord{
+		timedRecord("s1", 10, 1),
+		timedRecord("s1", 62, 2),  // Watermark 57s: a primeira janela continua aberta
+		timedRecord("s1", 50, 3),  // Ainda no prazo, sem atraso
+		timedRecord("s1", 70, 4),  // Watermark 65s: emite a primeira janela
+		timedRecord("s1", 40, 5),  // Atrasado no prazo: revisão 1
+		timedRecord("s1", 100, 6), // Watermark 95s: a primeira janela é descartada
+		timedRecord("s1", 30, 7),  // Janela descartada
+	} {
+		in <- record
+	}
+	close(in)
+	WindowSink{Window: WindowConfig{Size: time.Minute}, Watermark: wm, Output: out}.Consume(context.Background(), in)
+
+	got := out.all()
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 aggregates, got %d: %+v", len(got), got)
+	}
+	if got[0].Revision != 0 || got[0].Count != 2 || got[0].Max != 3 {
+		t.Errorf("Expected the first window with the records up to the watermark, got %+v", got[0])
+	}
+	if got[1].Revision != 1 || !got[1].WindowStart.Equal(windowEpoch) || got[1].Count != 3 || got[1].Max != 5 {
+		t.Errorf("Expected a revision of the first window with the late record, got %+v", got[1])
+	}
+	if got[2].Revision != 0 || got[2].Count != 3 || got[2].Mean != 4 {
+		t.Errorf("Expected the second window flushed at the end, got %+v", got[2])
+	}
+}
+
+func TestWatermarkConfigValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Watermark = WatermarkConfig{Enabled: true, MaxOutOfOrderness: -time.Second, LatePolicy: "ignore"}
+	err := cfg.Validate()
+	for _, field := range []string{"watermark.max_out_of_orderness", "watermark.late_policy"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected an error for %s, got %v", field, err)
+		}
+	}
+
+	cfg = DefaultConfig()
+	cfg.Watermark.Enabled = true
+	cfg.Watermark.LatePolicy = LateSideOutput
+	cfg.Watermark.LateFile = cfg.Output.FailedFile
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "watermark.late_file") {
+		t.Errorf("Expected the side output to need its own file, got %v", err)
+	}
+	cfg.Watermark.LateFile = "late.jsonl"
+	if _, err := NewBuilder(cfg).WithSource(sliceSource{}).Build(); err == nil {
+		t.Error("Expected side_output without a LateSink to be rejected")
+	}
+}
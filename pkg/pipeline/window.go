@@ -0,0 +1,315 @@
+// Go Concurrent Data Pipeline
+// Author: Gabriel Demetrios Lafis
+// Year: 2025
+
+package pipeline
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// WindowConfig controla as agregações de Value por SensorID e Location em janelas de
+// DataRecord.Timestamp (ver WindowSink).
+type WindowConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	Size    time.Duration `yaml:"size"`
+	// Slide é o deslocamento entre o início de janelas consecutivas; 0 ou igual a Size gera
+	// janelas fixas (tumbling), menor que Size gera janelas deslizantes sobrepostas
+	Slide       time.Duration `yaml:"slide"`
+	Percentiles []float64     `yaml:"percentiles"` // Entre 0 e 100
+	OutputFile  string        `yaml:"output_file"` // JSONL com um WindowAggregate por janela, sensor e local
+}
+
+// slide retorna o deslocamento efetivo entre janelas.
+func (c WindowConfig) slide() time.Duration {
+	if c.Slide <= 0 {
+		return c.Size
+	}
+	return c.Slide
+}
+
+// WindowAggregate resume os valores de um sensor em um local durante uma janela
+// [WindowStart, WindowEnd). StdDev é o desvio padrão populacional; os percentis são
+// interpolados linearmente entre os valores ordenados e indexados por "p<percentil>"
+// (ex.: p50, p99.9). Revision é 0 na primeira emissão da janela; cada registro atrasado
+// aceito depois dela emite o agregado atualizado com a revisão seguinte, que substitui as
+// anteriores.
+type WindowAggregate struct {
+	SensorID    string             `json:"sensor_id"`
+	Location    string             `json:"location"`
+	WindowStart time.Time          `json:"window_start"`
+	WindowEnd   time.Time          `json:"window_end"`
+	Count       int                `json:"count"`
+	Min         float64            `json:"min"`
+	Max         float64            `json:"max"`
+	Mean        float64            `json:"mean"`
+	StdDev      float64            `json:"stddev"`
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+	Revision    int                `json:"revision,omitempty"`
+}
+
+// AggregateSink consome os agregados emitidos por um WindowSink.
+// ConsumeAggregates retorna quando in é fechado ou ctx é cancelado, liberando seus recursos.
+type AggregateSink interface {
+	Name() string
+	ConsumeAggregates(ctx context.Context, in <-chan WindowAggregate)
+}
+
+// WindowSink é o Sink que agrega os registros processados em janelas de tempo e envia um
+// WindowAggregate por janela encerrada para Output.
+//
+// As janelas seguem o tempo dos eventos: cada janela é emitida quando o watermark alcança seu
+// fim e mantida por mais Watermark.AllowedLateness, período em que registros atrasados a
+// atualizam e geram uma nova revisão. Sem Watermark.Enabled, o watermark do WindowSink ainda
+// é o maior Timestamp recebido menos Watermark.MaxOutOfOrderness, para tolerar a reordenação
+// entre workers, mas não há atraso permitido. Registros de janelas já descartadas são ignorados e
+// contados; ao fim da entrada, as janelas ainda não emitidas são encerradas. Registros sem
+// Timestamp são ignorados.
+type WindowSink struct {
+	Window    WindowConfig
+	Watermark WatermarkConfig
+	Output    AggregateSink
+}
+
+// Name identifica a etapa nos logs.
+func (s WindowSink) Name() string { return "Windower" }
+
+// CheckHealth repassa a verificação de Output, se ele a implementar (ver HealthChecker).
+func (s WindowSink) CheckHealth() error {
+	if checker, ok := s.Output.(HealthChecker); ok {
+		return checker.CheckHealth()
+	}
+	return nil
+}
+
+// Consume agrega cada registro de in até o canal ser fechado ou ctx ser cancelado.
+func (s WindowSink) Consume(ctx context.Context, in <-chan ProcessedRecord) {
+	infof("Windower: Iniciando agregação em janelas de %s (deslocamento %s)...", s.Window.Size, s.Window.slide())
+	out := make(chan WindowAggregate)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Output.ConsumeAggregates(ctx, out)
+	}()
+	defer func() {
+		close(out)
+		<-done
+	}()
+
+	w := newWindower(s.Window, s.Watermark)
+	emit := func(aggregates []WindowAggregate) bool {
+		for _, aggregate := range aggregates {
+			if !send(ctx, out, aggregate) {
+				return false
+			}
+		}
+		return true
+	}
+	for record := range in {
+		if ctx.Err() != nil {
+			warnf("Windower: Agregação abortada (%v)", ctx.Err())
+			return
+		}
+		if !emit(w.add(record.DataRecord)) {
+			return
+		}
+	}
+	emit(w.flush())
+	if w.late > 0 || w.untimed > 0 {
+		warnf("Windower: %d registros chegaram após o descarte de suas janelas e %d não tinham timestamp; ambos foram ignorados",
+			w.late, w.untimed)
+	}
+	infof("Windower: Agregação finalizada (%d agregados, %d revisões).", w.emitted, w.revisions)
+}
+
+// windowKey identifica uma janela aberta de um sensor em um local.
+type windowKey struct {
+	start    int64 // UnixNano
+	sensorID string
+	location string
+}
+
+// timeWindow guarda os valores de uma janela mantida pelo windower.
+type timeWindow struct {
+	values   []float64
+	revision int // Próxima revisão a emitir; > 0 depois da primeira emissão
+}
+
+// windower mantém as janelas abertas, emite as que o watermark alcançou e as descarta
+// depois do atraso permitido.
+type windower struct {
+	size, slide time.Duration
+	lateness    time.Duration
+	percentiles []float64
+
+	open  map[windowKey]*timeWindow
+	clock eventClock
+
+	late, untimed, emitted, revisions int
+}
+
+func newWindower(cfg WindowConfig, watermark WatermarkConfig) *windower {
+	w := &windower{
+		size:        cfg.Size,
+		slide:       cfg.slide(),
+		percentiles: cfg.Percentiles,
+		open:        make(map[windowKey]*timeWindow),
+	}
+	// Mesmo sem watermark.enabled, os workers paralelos entregam os registros fora de ordem:
+	// as janelas esperam max_out_of_orderness antes de serem emitidas
+	w.clock.maxOutOfOrderness = watermark.MaxOutOfOrderness
+	if watermark.Enabled {
+		w.lateness = watermark.AllowedLateness
+	}
+	return w
+}
+
+// add acrescenta o valor de record às janelas que contêm seu Timestamp e retorna as revisões
+// das janelas já emitidas que ele atualizou, seguidas dos agregados das janelas que o novo
+// watermark alcançou.
+func (w *windower) add(record DataRecord) []WindowAggregate {
+	ts := record.Timestamp
+	if ts.IsZero() {
+		w.untimed++
+		return nil
+	}
+	watermark := w.clock.watermark()
+	var aggregates []WindowAggregate
+	// Janelas alinhadas à época Unix: a última que contém ts começa em ts truncado ao
+	// deslocamento, e as anteriores a cada deslocamento enquanto ainda contiverem ts
+	for start := ts.Truncate(w.slide); start.Add(w.size).After(ts); start = start.Add(-w.slide) {
+		if !start.Add(w.size + w.lateness).After(watermark) {
+			w.late++
+			break // As janelas anteriores foram descartadas ainda mais cedo
+		}
+		key := windowKey{start: start.UnixNano(), sensorID: record.SensorID, location: record.Location}
+		state, ok := w.open[key]
+		if !ok {
+			state = &timeWindow{}
+			w.open[key] = state
+		}
+		state.values = append(state.values, record.Value)
+		if state.revision > 0 {
+			aggregates = append(aggregates, w.emit(key, state))
+		}
+	}
+	w.clock.observe(ts)
+	watermark = w.clock.watermark()
+	return append(aggregates, w.close(func(end time.Time) (bool, bool) {
+		return !end.After(watermark), !end.Add(w.lateness).After(watermark)
+	})...)
+}
+
+// flush emite e descarta todas as janelas.
+func (w *windower) flush() []WindowAggregate {
+	return w.close(func(time.Time) (bool, bool) { return true, true })
+}
+
+// close emite, em ordem de início, sensor e local, as janelas ainda não emitidas cujo fim é
+// ready e descarta, depois de emitidas, as que expired.
+func (w *windower) close(due func(end time.Time) (ready, expired bool)) []WindowAggregate {
+	var keys, expiredKeys []windowKey
+	for key, state := range w.open {
+		ready, expired := due(time.Unix(0, key.start).Add(w.size))
+		if ready && state.revision == 0 {
+			keys = append(keys, key)
+		}
+		if expired {
+			expiredKeys = append(expiredKeys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		if a.sensorID != b.sensorID {
+			return a.sensorID < b.sensorID
+		}
+		return a.location < b.location
+	})
+	aggregates := make([]WindowAggregate, 0, len(keys))
+	for _, key := range keys {
+		aggregates = append(aggregates, w.emit(key, w.open[key]))
+	}
+	for _, key := range expiredKeys {
+		delete(w.open, key)
+	}
+	return aggregates
+}
+
+// emit retorna o agregado atual da janela key e avança sua revisão.
+func (w *windower) emit(key windowKey, state *timeWindow) WindowAggregate {
+	start := time.Unix(0, key.start).UTC()
+	result := aggregate(key.sensorID, key.location, start, start.Add(w.size), state.values, w.percentiles)
+	result.Revision = state.revision
+	if state.revision > 0 {
+		w.revisions++
+	} else {
+		w.emitted++
+	}
+	state.revision++
+	return result
+}
+
+// aggregate calcula as estatísticas de values, que não pode ser vazio.
+func aggregate(sensorID, location string, start, end time.Time, values []float64, percentiles []float64) WindowAggregate {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	mean := sum / float64(len(sorted))
+	var squares float64
+	for _, v := range sorted {
+		squares += (v - mean) * (v - mean)
+	}
+	result := WindowAggregate{
+		SensorID:    sensorID,
+		Location:    location,
+		WindowStart: start,
+		WindowEnd:   end,
+		Count:       len(sorted),
+		Min:         sorted[0],
+		Max:         sorted[len(sorted)-1],
+		Mean:        mean,
+		StdDev:      math.Sqrt(squares / float64(len(sorted))),
+	}
+	if len(percentiles) > 0 {
+		result.Percentiles = make(map[string]float64, len(percentiles))
+		for _, p := range percentiles {
+			result.Percentiles["p"+strconv.FormatFloat(p, 'f', -1, 64)] = percentile(sorted, p)
+		}
+	}
+	return result
+}
+
+// percentile interpola linearmente o percentil p (0 a 100) de sorted, em ordem crescente.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// AggregateFileSink é o AggregateSink padrão, que grava os agregados em JSONL no arquivo em
+// Path, sobrescrito a cada execução.
+type AggregateFileSink struct {
+	Path string
+}
+
+// Name identifica a etapa nos logs.
+func (s AggregateFileSink) Name() string { return "AggregateWriter" }
+
+// CheckHealth reporta se Path pode receber escrita (ver HealthChecker).
+func (s AggregateFileSink) CheckHealth() error { return checkWritable(s.Path) }
+
+// ConsumeAggregates grava cada agregado de in até o canal ser fechado ou ctx ser cancelado.
+func (s AggregateFileSink) ConsumeAggregates(ctx context.Context, in <-chan WindowAggregate) {
+	writeJSONL(ctx, s.Name(), s.Path, in)
+}
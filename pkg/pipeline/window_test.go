@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+var windowEpoch = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// timedRecord cria um registro do sensor em North com o valor e o instante dados, em segundos
+// após windowEpoch.
+func timedRecord(sensorID string, seconds int, value float64) ProcessedRecord {
+	return ProcessedRecord{DataRecord: DataRecord{
+		SensorID:  sensorID,
+		Location:  "North",
+		Value:     value,
+		Timestamp: windowEpoch.Add(time.Duration(seconds) * time.Second),
+	}}
+}
+
+// runWindow envia records a um WindowSink com cfg, sem tolerância a desordem, e retorna os
+// agregados emitidos.
+func runWindow(cfg WindowConfig, records ...ProcessedRecord) []WindowAggregate {
+	return runWindowWatermark(cfg, WatermarkConfig{}, records...)
+}
+
+func runWindowWatermark(cfg WindowConfig, watermark WatermarkConfig, records ...ProcessedRecord) []WindowAggregate {
+	out := &memoryOutput[WindowAggregate]{}
+	in := make(chan ProcessedRecord, len(records))
+	for _, record := range records {
+		in <- record
+	}
+	close(in)
+	WindowSink{Window: cfg, Watermark: watermark, Output: out}.Consume(context.Background(), in)
+	return out.all()
+}
+
+func TestWindowTumbling(t *testing.T) {
+	aggregates := runWindow(WindowConfig{Size: time.Minute},
+		timedRecord("s1", 0, 10),
+		timedRecord("s2", 5, 100),
+		timedRecord("s1", 30, 20),
+		timedRecord("s1", 59, 30),
+		timedRecord("s1", 60, 40), // Encerra a primeira janela
+		timedRecord("s1", 10, 99), // Atrasado: a janela já foi emitida
+		timedRecord("s1", 90, 50),
+		ProcessedRecord{DataRecord: DataRecord{SensorID: "s1", Value: 1}}, // Sem timestamp
+	)
+	if len(aggregates) != 3 {
+		t.Fatalf("Expected 3 aggregates, got %d: %+v", len(aggregates), aggregates)
+	}
+	first := aggregates[0]
+	if first.SensorID != "s1" || !first.WindowStart.Equal(windowEpoch) || !first.WindowEnd.Equal(windowEpoch.Add(time.Minute)) {
+		t.Errorf("Expected the first aggregate to be s1 in the first minute, got %+v", first)
+	}
+	if first.Count != 3 || first.Min != 10 || first.Max != 30 || first.Mean != 20 {
+		t.Errorf("Expected count 3, min 10, max 30 and mean 20, got %+v", first)
+	}
+	if want := math.Sqrt(200.0 / 3); math.Abs(first.StdDev-want) > 1e-9 {
+		t.Errorf("Expected population stddev %f, got %f", want, first.StdDev)
+	}
+	if aggregates[1].SensorID != "s2" || aggregates[1].Count != 1 {
+		t.Errorf("Expected s2 to have its own aggregate, got %+v", aggregates[1])
+	}
+	if last := aggregates[2]; last.Count != 2 || last.Mean != 45 || !last.WindowStart.Equal(windowEpoch.Add(time.Minute)) {
+		t.Errorf("Expected the open window to be flushed at the end, got %+v", last)
+	}
+}
+
+func TestWindowSliding(t *testing.T) {
+	aggregates := runWindow(WindowConfig{Size: time.Minute, Slide: 30 * time.Second},
+		timedRecord("s1", 10, 1),
+		timedRecord("s1", 40, 2),
+		timedRecord("s1", 70, 3),
+	)
+	// Janelas que contêm algum registro: [-30s,30s), [0,60s), [30s,90s), [60s,120s)
+	want := []struct {
+		start int
+		count int
+		mean  float64
+	}{{-30, 1, 1}, {0, 2, 1.5}, {30, 2, 2.5}, {60, 1, 3}}
+	if len(aggregates) != len(want) {
+		t.Fatalf("Expected %d aggregates, got %d: %+v", len(want), len(aggregates), aggregates)
+	}
+	for i, w := range want {
+		got := aggregates[i]
+		if !got.WindowStart.Equal(windowEpoch.Add(time.Duration(w.start)*time.Second)) || got.Count != w.count || got.Mean != w.mean {
+			t.Errorf("Window %d: expected start %ds, count %d and mean %g, got %+v", i, w.start, w.count, w.mean, got)
+		}
+	}
+}
+
+func TestWindowToleratesReorderingWithoutWatermark(t *testing.T) {
+	// Workers paralelos entregam 58s depois de 61s; o watermark desativado mantém o padrão de 5s
+	watermark := DefaultConfig().Watermark
+	if watermark.Enabled {
+		t.Fatal("Expected the watermark to be disabled by default")
+	}
+	aggregates := runWindowWatermark(WindowConfig{Size: time.Minute}, watermark,
+		timedRecord("s1", 10, 1),
+		timedRecord("s1", 61, 2),
+		timedRecord("s1", 58, 3),
+		timedRecord("s1", 70, 4), // O watermark passa de 60s: emite a primeira janela
+		timedRecord("s1", 40, 5), // Além da tolerância: ignorado
+	)
+	if len(aggregates) != 2 {
+		t.Fatalf("Expected 2 aggregates, got %+v", aggregates)
+	}
+	if first := aggregates[0]; first.Count != 2 || first.Mean != 2 || first.Revision != 0 {
+		t.Errorf("Expected the reordered record in the first window, got %+v", first)
+	}
+	if second := aggregates[1]; second.Count != 2 || second.Mean != 3 {
+		t.Errorf("Expected the second window to hold 61s and 70s, got %+v", second)
+	}
+}
+
+func TestWindowPercentiles(t *testing.T) {
+	values := []float64{15, 20, 35, 40, 50}
+	got := aggregate("s1", "North", windowEpoch, windowEpoch, values, []float64{0, 40, 50, 99.9, 100})
+	want := map[string]float64{"p0": 15, "p40": 29, "p50": 35, "p99.9": 49.96, "p100": 50}
+	for key, value := range want {
+		if math.Abs(got.Percentiles[key]-value) > 1e-9 {
+			t.Errorf("Expected %s = %g, got %g", key, value, got.Percentiles[key])
+		}
+	}
+	if single := aggregate("s1", "North", windowEpoch, windowEpoch, []float64{7}, []float64{90}); single.Percentiles["p90"] != 7 || single.StdDev != 0 {
+		t.Errorf("Expected a single value to be every percentile with no deviation, got %+v", single)
+	}
+}
+
+func TestWindowConfigValidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Window = WindowConfig{Enabled: true, Size: time.Minute, Slide: 2 * time.Minute, Percentiles: []float64{101}, OutputFile: cfg.Output.ProcessedFile}
+	err := cfg.Validate()
+	for _, field := range []string{"window.slide", "window.percentiles[0]", "window.output_file"} {
+		if err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("Expected an error for %s, got %v", field, err)
+		}
+	}
+
+	cfg = DefaultConfig()
+	cfg.Window.Enabled = true
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected the default window to be valid, got %v", err)
+	}
+}
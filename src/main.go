@@ -5,43 +5,187 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 	"go-concurrent-data-pipeline/pkg/pipeline"
 )
 
 func main() {
+	configPath := flag.String("config", "", "Caminho do arquivo YAML de configuração (ex.: config/config.example.yaml)")
+	replayPath := flag.String("replay", "", "Reprocessa um arquivo de dead-letter (ex.: failed_data.jsonl) em vez de executar a pipeline")
+	replayStatus := flag.String("replay-status", "", "Replay: status a reprocessar, separados por vírgula (ex.: invalid,transformation_error)")
+	replayCode := flag.String("replay-code", "", "Replay: códigos de erro a reprocessar, separados por vírgula (ex.: VALUE_OUT_OF_RANGE)")
+	replaySince := flag.String("replay-since", "", "Replay: apenas registros com timestamp a partir deste instante (RFC 3339)")
+	replayUntil := flag.String("replay-until", "", "Replay: apenas registros com timestamp anterior a este instante (RFC 3339)")
+	replayProcessed := flag.String("replay-processed", "replayed_data.jsonl", "Replay: arquivo para os registros que agora passam")
+	replayFailed := flag.String("replay-failed", "replay_failed_data.jsonl", "Replay: arquivo para os registros que falham novamente")
+	replayReport := flag.String("replay-report", "replay_report.json", "Replay: arquivo do relatório")
+	flag.Parse()
+
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 	fmt.Println("===========================================")
 	fmt.Println("Go Concurrent Data Pipeline")
 	fmt.Println("===========================================")
 
-	// Limpar arquivos de saída anteriores
-	_ = os.Remove("processed_data.jsonl")
-	_ = os.Remove("failed_data.jsonl")
+	// Carregar a configuração; sem -config, usa os valores padrão
+	cfg := pipeline.DefaultConfig()
+	if *configPath != "" {
+		loaded, err := pipeline.LoadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("Falha ao carregar configuração: %v", err)
+		}
+		cfg = loaded
+	}
+	if err := pipeline.SetLogLevel(cfg.Output.LogLevel); err != nil {
+		log.Fatalf("Falha ao configurar o nível de log: %v", err)
+	}
+
+	// Duplicar os logs para o arquivo configurado, se houver
+	if cfg.Output.LogFile != "" {
+		if err := os.MkdirAll(filepath.Dir(cfg.Output.LogFile), 0o755); err != nil {
+			log.Fatalf("Falha ao criar diretório de logs: %v", err)
+		}
+		logFile, err := os.OpenFile(cfg.Output.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			log.Fatalf("Falha ao abrir arquivo de log: %v", err)
+		}
+		defer func() { _ = logFile.Close() }()
+		log.SetOutput(io.MultiWriter(os.Stderr, logFile))
+	}
+
+	// SIGINT/SIGTERM encerram a pipeline de forma graciosa, drenando os registros em trânsito
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Executar a pipeline com 50 registros e 3 workers para validação/transformação
+	if *replayPath != "" {
+		opts := pipeline.ReplayOptions{
+			Input: *replayPath,
+			Filter: pipeline.ReplayFilter{
+				Statuses: splitList(*replayStatus),
+				Codes:    splitList(*replayCode),
+				Since:    parseTime("replay-since", *replaySince),
+				Until:    parseTime("replay-until", *replayUntil),
+			},
+			ProcessedFile: *replayProcessed,
+			FailedFile:    *replayFailed,
+			ReportFile:    *replayReport,
+		}
+		runReplay(ctx, cfg, opts)
+		return
+	}
+
+	// Limpar arquivos de saída anteriores; com rotação, os segmentos de execuções
+	// anteriores são preservados e um segmento ativo interrompido é recuperado. Com
+	// source.checkpoint, os Sinks decidem: continuam os arquivos ao retomar um checkpoint
+	if !cfg.Output.Rotation.Enabled() && cfg.Source.Checkpoint.Path == "" {
+		_ = os.Remove(cfg.Output.ProcessedFile)
+		_ = os.Remove(cfg.Output.FailedFile)
+	}
+
+	// Executar a pipeline conforme a configuração
 	// Os logs detalhados serão exibidos no console e as métricas no final.
-	metrics := pipeline.RunAdvancedPipeline(50, 3)
+	p, err := pipeline.DefaultBuilder(cfg).Build()
+	if err != nil {
+		log.Fatalf("Pipeline: Configuração inválida: %v", err)
+	}
+
+	// Expor as métricas em tempo real para o Prometheus enquanto a pipeline executa
+	if cfg.Metrics.Enabled && cfg.Metrics.PrometheusEndpoint != "" {
+		srv, err := pipeline.StartMetricsServer(cfg.Metrics.PrometheusEndpoint, p.Telemetry())
+		if err != nil {
+			log.Printf("Aviso: métricas do Prometheus indisponíveis: %v", err)
+		} else {
+			defer func() { _ = srv.Close() }()
+		}
+	}
+
+	// Sondas de liveness (/healthz) e readiness (/readyz) para orquestradores como o Kubernetes
+	if cfg.Monitoring.HealthCheckEnabled && cfg.Monitoring.HealthCheckAddress != "" {
+		srv, err := pipeline.StartHealthServer(cfg.Monitoring.HealthCheckAddress, p.Health())
+		if err != nil {
+			log.Printf("Aviso: health check indisponível: %v", err)
+		} else {
+			defer func() { _ = srv.Close() }()
+		}
+	}
+
+	metrics, reason := p.Run(ctx)
 
 	fmt.Println("===========================================")
-	fmt.Println("Pipeline completed!")
+	fmt.Printf("Pipeline completed! (motivo: %s)\n", reason)
 	fmt.Printf("Final Metrics: Processed=%d, Errors=%d, Anomalies=%d\n", 
 		metrics.ProcessedCount, metrics.ErrorCount, metrics.AnomalyCount)
 	fmt.Println("===========================================")
 
+	if cfg.Output.Partition.Enabled() {
+		fmt.Printf("\nRegistros processados particionados em %s\n", cfg.Output.Partition.Dir)
+	}
+	if cfg.Output.Parquet.Path != "" {
+		fmt.Printf("\nRegistros processados também gravados em Parquet em %s\n", cfg.Output.Parquet.Path)
+	}
+	if cfg.Output.Postgres.DSN != "" {
+		fmt.Printf("\nRegistros processados também gravados na tabela Postgres %s\n", cfg.Output.Postgres.Table)
+	}
+	for sink, stats := range metrics.SinkWrites {
+		fmt.Printf("%s: %d registros em %d escritas (média %s, máxima %s)\n",
+			sink, stats.Records, stats.Writes, stats.Mean(), stats.Max)
+	}
+	for channel, dropped := range metrics.Dropped {
+		fmt.Printf("%s: %d registros descartados pela política de backpressure\n", channel, dropped)
+	}
+	if db := cfg.Output.SQLite; db.Path != "" {
+		fmt.Printf("\nRegistros gravados nas tabelas %s e %s de %s\n", db.ProcessedTable, db.FailedTable, db.Path)
+	}
+	if cfg.Window.Enabled {
+		fmt.Printf("\nAgregados por janela de %s gravados em %s\n", cfg.Window.Size, cfg.Window.OutputFile)
+	}
+	if cfg.Session.Enabled {
+		fmt.Printf("\nSessões por sensor (intervalo de %s) gravadas em %s\n", cfg.Session.Gap, cfg.Session.OutputFile)
+	}
+	for destination, late := range metrics.Late {
+		fmt.Printf("%d registros anteriores ao watermark (%s)\n", late, destination)
+	}
+	if wm := cfg.Watermark; wm.Enabled && wm.LatePolicy == pipeline.LateSideOutput {
+		fmt.Printf("\nRegistros atrasados gravados em %s\n", wm.LateFile)
+	}
+	if cfg.Output.Rotation.Enabled() {
+		fmt.Printf("\nSegmentos listados em %s e %s\n",
+			pipeline.ManifestPath(cfg.Output.ProcessedFile), pipeline.ManifestPath(cfg.Output.FailedFile))
+		return
+	}
+	if cfg.Source.Checkpoint.ExactlyOnce {
+		fmt.Printf("\nSegmentos publicados ao lado de %s e %s a cada checkpoint\n",
+			cfg.Output.ProcessedFile, cfg.Output.FailedFile)
+		return
+	}
+	if cfg.Output.Partition.Enabled() {
+		return
+	}
+
 	// Opcional: Ler os arquivos de saída para verificar o conteúdo
-	fmt.Println("\nConteúdo de processed_data.jsonl:")
-	processedContent, err := os.ReadFile("processed_data.jsonl")
-	if err == nil {
-		fmt.Println(string(processedContent))
+	if cfg.Output.Format == pipeline.FormatParquet {
+		fmt.Printf("\nRegistros processados gravados em Parquet em %s\n", cfg.Output.ProcessedFile)
 	} else {
-		fmt.Println("  (Arquivo não encontrado ou vazio)")
+		fmt.Printf("\nConteúdo de %s:\n", cfg.Output.ProcessedFile)
+		processedContent, err := os.ReadFile(cfg.Output.ProcessedFile)
+		if err == nil {
+			fmt.Println(string(processedContent))
+		} else {
+			fmt.Println("  (Arquivo não encontrado ou vazio)")
+		}
 	}
 
-	fmt.Println("\nConteúdo de failed_data.jsonl:")
-	failedContent, err := os.ReadFile("failed_data.jsonl")
+	fmt.Printf("\nConteúdo de %s:\n", cfg.Output.FailedFile)
+	failedContent, err := os.ReadFile(cfg.Output.FailedFile)
 	if err == nil {
 		fmt.Println(string(failedContent))
 	} else {
@@ -49,3 +193,49 @@ func main() {
 	}
 }
 
+// runReplay reprocessa um arquivo de dead-letter e imprime o resumo do relatório.
+func runReplay(ctx context.Context, cfg pipeline.Config, opts pipeline.ReplayOptions) {
+	report, reason, err := pipeline.RunReplay(ctx, cfg, opts)
+	if err != nil {
+		log.Fatalf("Falha no replay: %v", err)
+	}
+
+	fmt.Println("===========================================")
+	fmt.Printf("Replay completed! (motivo: %s)\n", reason)
+	fmt.Printf("Read=%d, Matched=%d, Succeeded=%d, Failed=%d, Pending=%d\n",
+		report.Read, report.Matched, report.Succeeded, report.Failed, report.Pending)
+	fmt.Println("===========================================")
+	for _, result := range report.Records {
+		fmt.Printf("  linha %d %s: %s -> %s", result.Line, result.ID, result.PreviousStatus, result.Outcome)
+		if result.Error != "" {
+			fmt.Printf(" (%s)", result.Error)
+		}
+		fmt.Println()
+	}
+	if opts.ReportFile != "" {
+		fmt.Printf("\nRelatório gravado em %s\n", opts.ReportFile)
+	}
+}
+
+// splitList separa uma lista separada por vírgulas, ignorando itens vazios.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseTime interpreta o valor RFC 3339 da flag name; vazio retorna o instante zero.
+func parseTime(name, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Fatalf("-%s inválido: %v", name, err)
+	}
+	return t
+}